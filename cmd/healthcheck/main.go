@@ -20,44 +20,285 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/localnerve/jam-build-propsdb/internal/config"
 	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"github.com/localnerve/jam-build-propsdb/internal/logging"
 	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"gorm.io/gorm"
 )
 
+// Exit codes, 2 is left to flag usage errors and is reserved by Docker
+const (
+	exitHealthy   = 0
+	exitUnhealthy = 1
+	exitDegraded  = 3
+)
+
+// options are the command line flags
+type options struct {
+	mode         string
+	url          string
+	insecure     bool
+	components   []string
+	format       string
+	output       string
+	timeout      time.Duration
+	degradedExit int
+	configFile   string
+}
+
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	opts, err := parseFlags(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	os.Exit(run(opts))
+}
+
+// parseFlags parses and validates the command line
+func parseFlags(args []string) (options, error) {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3000"
+	}
+
+	var opts options
+	var components string
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	flags.StringVar(&opts.mode, "mode", "direct", "direct checks the dependencies, http probes the running server's readiness")
+	flags.StringVar(&opts.url, "url", "http://127.0.0.1:"+port+"/readyz", "readiness URL probed in http mode")
+	flags.BoolVar(&opts.insecure, "insecure", false, "skip verification of the server certificate when the -url is https")
+	flags.StringVar(&components, "components", strings.Join(services.Components, ","), "comma-separated components checked in direct mode")
+	flags.StringVar(&opts.format, "format", "json", "output format: json, text, or prometheus")
+	flags.StringVar(&opts.output, "output", "", "file replaced with the output, e.g. for a Prometheus textfile collector, default stdout")
+	flags.DurationVar(&opts.timeout, "timeout", 5*time.Second, "timeout of each check, or of the http probe")
+	flags.IntVar(&opts.degradedExit, "degraded-exit", exitDegraded, "exit code when degraded, e.g. 0 to pass")
+	flags.StringVar(&opts.configFile, "config", "", "YAML or TOML config file for direct mode, overrides CONFIG_FILE")
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if opts.mode != "direct" && opts.mode != "http" {
+		return opts, fmt.Errorf("-mode must be direct or http")
+	}
+	switch opts.format {
+	case "json", "text", "prometheus":
+	default:
+		return opts, fmt.Errorf("-format must be json, text, or prometheus")
+	}
+	for _, component := range strings.Split(components, ",") {
+		component = strings.TrimSpace(component)
+		if component == "" {
+			continue
+		}
+		if !slices.Contains(services.Components, component) {
+			return opts, fmt.Errorf("unknown component %q, expected %s", component, strings.Join(services.Components, ", "))
+		}
+		opts.components = append(opts.components, component)
+	}
+	if len(opts.components) == 0 {
+		return opts, fmt.Errorf("-components must list at least one component")
 	}
 
-	// Connect to database (app pool)
-	appDB, err := database.Connect(cfg)
+	return opts, nil
+}
+
+// run performs the health check, writes the report and returns the exit code
+func run(opts options) int {
+	var rep report
+	if opts.mode == "http" {
+		rep = probe(opts)
+	} else {
+		rep = checkDirect(opts)
+	}
+
+	if err := writeReport(rep, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write health check output: %v\n", err)
+		return exitUnhealthy
+	}
+
+	if rep.Status == "degraded" {
+		return opts.degradedExit
+	}
+	return statusCode(rep.Status)
+}
+
+// statusCode returns the exit code of a status
+func statusCode(status string) int {
+	switch status {
+	case "healthy":
+		return exitHealthy
+	case "degraded":
+		return exitDegraded
+	}
+	return exitUnhealthy
+}
+
+// checkDirect connects to the pools the components need and runs their checks
+func checkDirect(opts options) report {
+	rep := report{Mode: "direct", Components: make(map[string]string)}
+
+	var configArgs []string
+	if opts.configFile != "" {
+		configArgs = []string{"--config", opts.configFile}
+	}
+	cfg, err := config.Load(configArgs...)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		rep.Status = "unhealthy"
+		rep.Error = fmt.Sprintf("Failed to load configuration: %v", err)
+		return rep
+	}
+
+	// Warnings and errors only, on stderr, so stdout holds just the report
+	slog.SetDefault(logging.New(os.Stderr, slog.LevelWarn, cfg.LogFormat))
+
+	checker := &services.HealthChecker{Config: cfg, Components: opts.components, Timeout: opts.timeout}
+	connectErrs := make(map[string]error)
+	wants := func(component string) bool {
+		return slices.Contains(opts.components, component)
+	}
+
+	if wants(services.ComponentAppDatabase) || wants(services.ComponentSchema) {
+		checker.AppDB, err = database.Connect(cfg)
+		if err != nil {
+			connectErrs[services.ComponentAppDatabase] = err
+			connectErrs[services.ComponentSchema] = err
+		} else {
+			defer closeDB(checker.AppDB)
+		}
+	}
+	if wants(services.ComponentUserDatabase) {
+		checker.UserDB, err = database.ConnectUser(cfg)
+		if err != nil {
+			connectErrs[services.ComponentUserDatabase] = err
+		} else {
+			defer closeDB(checker.UserDB)
+		}
 	}
-	defer database.Close(appDB)
+	if wants(services.ComponentReplicas) {
+		// Only replica health is used here, so no primaries are needed
+		appReplicas := database.ConnectReplicas(cfg, "app", nil, cfg.DBAppReplicas, 1)
+		defer appReplicas.Close()
+		userReplicas := database.ConnectReplicas(cfg, "user", nil, cfg.DBUserReplicas, 1)
+		defer userReplicas.Close()
+		checker.Replicas = []*database.ReplicaSet{appReplicas, userReplicas}
+	}
+
+	result := checker.Health(context.Background())
+	rep.Status = result.Status
+	rep.Error = result.ErrorMessage
+	rep.result = &result
+	for _, component := range opts.components {
+		rep.Components[component] = componentStatus(result, component)
+	}
+
+	// A pool that could not be opened fails its components
+	for component, err := range connectErrs {
+		if !wants(component) {
+			continue
+		}
+		rep.Status = "unhealthy"
+		rep.Components[component] = err.Error()
+		if rep.Error == "" {
+			rep.Error = err.Error()
+		}
+	}
+
+	return rep
+}
 
-	// Perform health check
-	result := services.HealthCheck(cfg, appDB)
+// probe requests the readiness URL of the running server
+func probe(opts options) report {
+	rep := report{Mode: "http", Status: "unhealthy", Components: make(map[string]string)}
 
-	// Output result as JSON
-	output, err := json.MarshalIndent(result, "", "  ")
+	client := &http.Client{Timeout: opts.timeout}
+	if opts.insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Get(opts.url)
 	if err != nil {
-		log.Fatalf("Failed to marshal health check result: %v", err)
+		rep.Error = fmt.Sprintf("Readiness probe failed: %v", err)
+		return rep
 	}
+	defer resp.Body.Close()
 
-	fmt.Println(string(output))
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		rep.Error = fmt.Sprintf("Failed to read readiness response: %v", err)
+		return rep
+	}
 
-	// Exit with appropriate code
-	if result.Status != "healthy" {
-		os.Exit(1)
+	var readiness services.ReadinessResult
+	if err := json.Unmarshal(body, &readiness); err == nil {
+		rep.Components = readiness.Checks
+		rep.readiness = &readiness
+	}
+	if rep.Components == nil {
+		rep.Components = make(map[string]string)
 	}
-	os.Exit(0)
+
+	if resp.StatusCode == http.StatusOK {
+		rep.Status = "healthy"
+		if rep.readiness != nil && rep.readiness.Degraded {
+			rep.Status = "degraded"
+		}
+	} else {
+		rep.Error = fmt.Sprintf("Readiness probe returned %d", resp.StatusCode)
+	}
+	return rep
+}
+
+// componentStatus returns "ok" or the problem of a component in a detailed result
+func componentStatus(result services.HealthCheckResult, component string) string {
+	problem := func(status, detail string) string {
+		if status == "ok" {
+			return status
+		}
+		if message := result.Details[detail]; message != "" {
+			return status + ": " + message
+		}
+		return status
+	}
+
+	switch component {
+	case services.ComponentAppDatabase:
+		return problem(result.Database, "database_error")
+	case services.ComponentUserDatabase:
+		return problem(result.UserDatabase, "user_database_error")
+	case services.ComponentAuthorizer:
+		return problem(result.Authorizer, "authorizer_error")
+	case services.ComponentSchema:
+		return problem(result.Migrations, "migrations_error")
+	case services.ComponentReplicas:
+		var ejected []string
+		for name, status := range result.Replicas {
+			if status != "ok" {
+				ejected = append(ejected, name)
+			}
+		}
+		if len(ejected) > 0 {
+			return "ejected: " + strings.Join(ejected, ", ")
+		}
+		return "ok"
+	}
+	return ""
+}
+
+// closeDB closes a pool opened for the check
+func closeDB(db *gorm.DB) {
+	_ = database.Close(db)
 }
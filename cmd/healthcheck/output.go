@@ -0,0 +1,161 @@
+// output.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// report is the outcome of a health check in either mode
+type report struct {
+	Mode       string            `json:"mode"`
+	Status     string            `json:"status"`     // healthy, degraded, or unhealthy
+	Components map[string]string `json:"components"` // component to "ok" or its problem
+	Error      string            `json:"error,omitempty"`
+
+	result    *services.HealthCheckResult // direct mode details
+	readiness *services.ReadinessResult   // http mode response
+}
+
+// writeReport renders the report in the chosen format to stdout, or replaces the output file
+func writeReport(rep report, opts options) error {
+	var buf bytes.Buffer
+	var err error
+	switch opts.format {
+	case "text":
+		renderText(&buf, rep)
+	case "prometheus":
+		err = renderPrometheus(&buf, rep)
+	default:
+		err = renderJSON(&buf, rep)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	// Write then rename, so a textfile collector never reads a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(opts.output), ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), opts.output)
+}
+
+// renderJSON writes the detailed result in direct mode, the report otherwise
+func renderJSON(buf *bytes.Buffer, rep report) error {
+	var value interface{} = rep
+	if rep.result != nil {
+		value = rep.result
+	}
+	output, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal health check result: %w", err)
+	}
+	buf.Write(output)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// renderText writes the status and a line per component
+func renderText(buf *bytes.Buffer, rep report) {
+	fmt.Fprintf(buf, "status: %s (%s)\n", rep.Status, rep.Mode)
+	for _, component := range sortedComponents(rep) {
+		fmt.Fprintf(buf, "%s: %s\n", component, rep.Components[component])
+	}
+	if rep.Error != "" {
+		fmt.Fprintf(buf, "error: %s\n", rep.Error)
+	}
+}
+
+// renderPrometheus writes the report as gauges in the Prometheus text format
+func renderPrometheus(buf *bytes.Buffer, rep report) error {
+	registry := prometheus.NewRegistry()
+
+	status := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "propsdb_healthcheck_status",
+		Help: "Health check outcome, 0 healthy, 1 unhealthy, 3 degraded",
+	}, []string{"mode", "status"})
+	componentUp := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "propsdb_healthcheck_component_up",
+		Help: "Whether a checked component passed, 1 or 0",
+	}, []string{"component"})
+	timestamp := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "propsdb_healthcheck_timestamp_seconds",
+		Help: "Unix time the health check ran",
+	})
+	registry.MustRegister(status, componentUp, timestamp)
+
+	status.WithLabelValues(rep.Mode, rep.Status).Set(float64(statusCode(rep.Status)))
+	for component, value := range rep.Components {
+		up := 0.0
+		if value == "ok" {
+			up = 1
+		}
+		componentUp.WithLabelValues(component).Set(up)
+	}
+	timestamp.Set(float64(time.Now().Unix()))
+
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(buf, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedComponents returns the report components in a stable order
+func sortedComponents(rep report) []string {
+	components := make([]string, 0, len(rep.Components))
+	for component := range rep.Components {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components
+}
@@ -0,0 +1,64 @@
+// config.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+)
+
+// configCommand runs "config print [--redacted] [config flags]", writing the effective configuration
+// as YAML, and returns the exit code
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: config print [--redacted] [--config file] [setting flags]")
+		return 2
+	}
+
+	// --redacted belongs to print, the other flags to the configuration
+	redact := false
+	var settingArgs []string
+	for _, arg := range args[1:] {
+		if arg == "--redacted" || arg == "-redacted" {
+			redact = true
+			continue
+		}
+		settingArgs = append(settingArgs, arg)
+	}
+
+	cfg, err := config.Load(settingArgs...)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+
+	if err := cfg.Print(os.Stdout, redact); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -20,7 +20,11 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"log/slog"
 	"os"
 	"os/signal"
 	"runtime/coverage"
@@ -30,14 +34,20 @@ import (
 	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	swagger "github.com/gofiber/swagger"
+	"github.com/localnerve/jam-build-propsdb/internal/cache"
 	"github.com/localnerve/jam-build-propsdb/internal/config"
 	"github.com/localnerve/jam-build-propsdb/internal/database"
 	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/logging"
 	"github.com/localnerve/jam-build-propsdb/internal/middleware"
+	"github.com/localnerve/jam-build-propsdb/internal/server"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/internal/telemetry"
 	"github.com/localnerve/jam-build-propsdb/internal/types"
+	"github.com/localnerve/jam-build-propsdb/internal/utils"
 
 	_ "github.com/localnerve/jam-build-propsdb/docs/api" // Swagger docs
 )
@@ -63,42 +73,114 @@ import (
 // @name cookie_session
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+
+	// Load configuration once, from defaults, the config file, environment and flags
+	cfg, err := config.Load(os.Args[1:]...)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
 
-	// Connect to database (app pool)
-	appDB, err := database.Connect(cfg)
+	// Structured logs at LOG_LEVEL in LOG_FORMAT, the standard log package included
+	logging.Setup(cfg)
+
+	// Tracing, spans are exported only when TRACING_EXPORTER is set
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to app database at startup: %v", err)
+		fatal("Failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	// Connect to database (app pool), retrying while it starts
+	appDB, err := database.ConnectWithRetry(cfg, database.Connect)
+	if err != nil {
+		fatal("Failed to connect to app database at startup", err)
 	}
 	defer database.Close(appDB)
 
-	// Connect to database (user pool)
-	userDB, err := database.ConnectUser(cfg)
+	// Connect to database (user pool), retrying while it starts
+	userDB, err := database.ConnectWithRetry(cfg, database.ConnectUser)
 	if err != nil {
-		log.Fatalf("Failed to connect to user database at startup: %v", err)
+		fatal("Failed to connect to user database at startup", err)
 	}
 	defer database.Close(userDB)
 
+	// Connection pool gauges for each pool
+	if err := database.RegisterPoolMetrics(appDB, "app"); err != nil {
+		fatal("Failed to register app pool metrics", err)
+	}
+	if err := database.RegisterPoolMetrics(userDB, "user"); err != nil {
+		fatal("Failed to register user pool metrics", err)
+	}
+
+	// Connect to read replicas, if any, for each pool
+	appReplicas := database.ConnectReplicas(cfg, "app", appDB, cfg.DBAppReplicas, cfg.DBAppConnectionLimit)
+	defer appReplicas.Close()
+	userReplicas := database.ConnectReplicas(cfg, "user", userDB, cfg.DBUserReplicas, cfg.DBConnectionLimit)
+	defer userReplicas.Close()
+	checkInterval := time.Duration(cfg.DBReplicaCheckSeconds) * time.Second
+	appReplicas.StartHealthChecks(checkInterval)
+	userReplicas.StartHealthChecks(checkInterval)
+
+	// Read your own writes: route a writer's reads to the primary for a short while after a commit.
+	// App reads are public, so any app write sends all app reads to the primary for the window.
+	services.OnMutation(func(event services.MutationEvent) {
+		if event.Scope == services.ScopeUser {
+			userReplicas.MarkWrite(event.UserID)
+		} else {
+			appReplicas.MarkWrite("")
+		}
+	})
+
 	// Run auto-migrations
 	if err := database.AutoMigrate(appDB); err != nil {
-		log.Fatalf("Failed to run migrations at startup: %v", err)
+		fatal("Failed to run migrations at startup", err)
+	}
+
+	// Purge deleted documents and collections past the trash retention
+	trashPurger := &services.TrashPurger{
+		Store:     services.GormStore{DB: appDB},
+		Retention: time.Duration(cfg.TrashRetentionHours) * time.Hour,
 	}
+	trashPurger.Start(time.Duration(cfg.TrashPurgeIntervalHours) * time.Hour)
+	defer trashPurger.Stop()
+
+	// Remove expired documents, collections and properties
+	expirySweeper := &services.ExpirySweeper{Store: services.GormStore{DB: appDB}}
+	expirySweeper.Start(time.Duration(cfg.ExpirySweepSeconds) * time.Second)
+	defer expirySweeper.Stop()
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: customErrorHandler,
-		// Disable startup message for cleaner logs
-		DisableStartupMessage: false,
+		// Disable startup message, the logs are structured lines
+		DisableStartupMessage: true,
 	})
 
-	// Global middleware
+	// Global middleware, requests are tracked for draining first so every context derives from theirs
+	drainer := middleware.NewDrainer()
+	app.Use(drainer.Handler())
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(middleware.RequestLogger("/healthz", "/readyz", "/metrics"))
 	app.Use(compress.New())
+	if cfg.SecurityHeaders {
+		// HSTS is only sent on TLS connections
+		app.Use(helmet.New(helmet.Config{
+			HSTSMaxAge:         cfg.HSTSMaxAge,
+			HSTSPreloadEnabled: cfg.HSTSPreload,
+		}))
+	}
 
 	// Prometheus metrics
 	prometheus := fiberprometheus.New("propsdb")
@@ -108,18 +190,65 @@ func main() {
 	// Swagger documentation
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
+	// Liveness and readiness probes, and detailed health for admins
+	healthHandler := &handlers.HealthHandler{Checker: &services.HealthChecker{
+		Config:     cfg,
+		AppDB:      appDB,
+		UserDB:     userDB,
+		Replicas:   []*database.ReplicaSet{appReplicas, userReplicas},
+		Timeout:    time.Duration(cfg.HealthTimeoutMS) * time.Millisecond,
+		CacheTTL:   time.Duration(cfg.HealthCacheMS) * time.Millisecond,
+		Migrations: true,
+	}}
+	app.Get("/healthz", healthHandler.Liveness)
+	app.Get("/readyz", healthHandler.Readiness)
+
+	// Request spans, after the metrics, swagger and probe routes so scrapes, docs and probes are not traced
+	app.Use(middleware.Tracing())
+
+	app.Get("/health", middleware.AuthAdmin(cfg), healthHandler.Health)
+
 	// API routes under /api
 	api := app.Group("/api")
 
 	// Version middleware
 	api.Use(middleware.VersionMiddleware())
 
-	// Data routes
-	data := api.Group("/data")
+	// Data routes, bounded by the read or write deadline
+	data := api.Group("/data", middleware.Deadline(
+		time.Duration(cfg.RequestReadTimeoutMS)*time.Millisecond,
+		time.Duration(cfg.RequestWriteTimeoutMS)*time.Millisecond,
+	))
 
 	// Create handlers
-	appHandler := &handlers.AppDataHandler{DB: appDB}
-	userHandler := &handlers.UserDataHandler{DB: userDB}
+	queryTimeout := time.Duration(cfg.DBQueryTimeoutMS) * time.Millisecond
+	appHandler := &handlers.AppDataHandler{DB: appDB, Replicas: appReplicas, Timeout: queryTimeout}
+	userHandler := &handlers.UserDataHandler{DB: userDB, Replicas: userReplicas, Timeout: queryTimeout}
+
+	// Idempotency-Key replay for mutations, mounted after authentication so keys are per user
+	idempotent := func(c *fiber.Ctx) error { return c.Next() }
+	if cfg.IdempotencyStore != "none" {
+		idempotencyStore, err := cache.New(cache.Options{
+			Backend:    cfg.IdempotencyStore,
+			MaxEntries: cfg.IdempotencySize,
+			RedisURL:   cfg.RedisURL,
+			Prefix:     "propsdb:idempotency",
+		})
+		if err != nil {
+			fatal("Failed to create idempotency store", err)
+		}
+		defer idempotencyStore.Close()
+
+		idempotent = middleware.Idempotency(middleware.IdempotencyConfig{
+			Cache: idempotencyStore,
+			TTL:   time.Duration(cfg.IdempotencyTTL) * time.Second,
+		})
+	}
+
+	// Application trash routes (admin only), ahead of the public app routes and their response cache
+	appTrashRoutes := data.Group("/app/_trash", middleware.AuthAdmin(cfg), idempotent)
+	appTrashRoutes.Get("/", appHandler.GetAppTrash)
+	appTrashRoutes.Post("/:document/restore", appHandler.RestoreAppTrash)
 
 	// Application data routes (public GET, admin POST/DELETE)
 	appRoutes := data.Group("/app")
@@ -128,21 +257,46 @@ func main() {
 		if c.Method() == fiber.MethodGet {
 			return c.Next()
 		}
-		return middleware.AuthAdmin()(c)
+		return middleware.AuthAdmin(cfg)(c)
 	})
+	// Response cache for public app reads
+	if cfg.AppCache != "none" {
+		appCache, err := cache.New(cache.Options{
+			Backend:    cfg.AppCache,
+			MaxEntries: cfg.AppCacheSize,
+			RedisURL:   cfg.RedisURL,
+			Prefix:     "propsdb:app",
+		})
+		if err != nil {
+			fatal("Failed to create app cache", err)
+		}
+		defer appCache.Close()
+
+		appRoutes.Use(middleware.AppCache(middleware.AppCacheConfig{
+			Cache:  appCache,
+			TTL:    time.Duration(cfg.AppCacheTTL) * time.Second,
+			MaxAge: cfg.AppCacheMaxAge,
+		}))
+	}
+	appRoutes.Use(idempotent)
 	appRoutes.Get("/:document/:collection", appHandler.GetAppProperties)
 	appRoutes.Get("/:document", appHandler.GetAppCollectionsAndProperties)
 	appRoutes.Get("/", appHandler.GetAppDocumentsCollectionsAndProperties)
 	appRoutes.Post("/:document", appHandler.SetAppProperties)
+	appRoutes.Post("/:document/copy", appHandler.CopyAppDocument)
+	appRoutes.Post("/:document/rename", appHandler.RenameAppDocument)
 	appRoutes.Delete("/:document/:collection", appHandler.DeleteAppCollection)
 	appRoutes.Delete("/:document", appHandler.DeleteAppProperties)
 
 	// User data routes (all require user authentication)
-	userRoutes := data.Group("/user", middleware.AuthUser())
+	userRoutes := data.Group("/user", middleware.AuthUser(cfg), idempotent)
+	userRoutes.Get("/_trash", userHandler.GetUserTrash)
+	userRoutes.Post("/_trash/:document/restore", userHandler.RestoreUserTrash)
 	userRoutes.Get("/:document/:collection", userHandler.GetUserProperties)
 	userRoutes.Get("/:document", userHandler.GetUserCollectionsAndProperties)
 	userRoutes.Get("/", userHandler.GetUserDocumentsCollectionsAndProperties)
 	userRoutes.Post("/:document", userHandler.SetUserProperties)
+	userRoutes.Post("/:document/from-template/:appDocument", userHandler.CreateUserDocumentFromTemplate)
 	userRoutes.Delete("/:document/:collection", userHandler.DeleteUserCollection)
 	userRoutes.Delete("/:document", userHandler.DeleteUserProperties)
 
@@ -159,42 +313,82 @@ func main() {
 
 	// Initialize Authorizer (will be done on first auth request)
 	// This is a placeholder - actual initialization happens in middleware
-	log.Printf("Authorizer will be initialized on first authenticated request")
+	slog.Info("Authorizer will be initialized on first authenticated request")
 
-	// Graceful shutdown
+	// Graceful shutdown, draining requests in flight before the pools are closed
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	shutdown := make(chan struct{})
 
 	go func() {
+		defer close(shutdown)
 		<-c
-		log.Println("Gracefully shutting down...")
+		slog.Info("Gracefully shutting down", "drain_seconds", cfg.ShutdownDrainSeconds)
 
 		// Explicitly write coverage data before exiting
 		// This ensures data is flushed even when we catch the signal
 		if coverDir := os.Getenv("GOCOVERDIR"); coverDir != "" {
-			log.Printf("Flushing coverage data to %s...", coverDir)
+			slog.Info("Flushing coverage data", "dir", coverDir)
 			// Ensure the directory exists (it should, but just in case)
 			if err := os.MkdirAll(coverDir, 0755); err != nil {
-				log.Printf("Warning: failed to create coverage directory: %v", err)
+				slog.Warn("Failed to create coverage directory", "error", err)
 			}
 			if err := coverage.WriteCountersDir(coverDir); err != nil {
-				log.Printf("Warning: failed to write coverage counters: %v", err)
+				slog.Warn("Failed to write coverage counters", "error", err)
 			}
-			log.Println("Coverage flush complete. Waiting for orchestrator to extract...")
+			slog.Info("Coverage flush complete, waiting for orchestrator to extract")
 			time.Sleep(5 * time.Second) // Give the host time to extract files
 		}
 
-		_ = app.Shutdown()
+		// Stop accepting connections and wait for requests in flight, then cancel those still running
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownDrainSeconds)*time.Second)
+		defer cancel()
+		if err := app.ShutdownWithContext(ctx); err != nil {
+			slog.Warn("Failed to close connections before the drain timeout", "error", err)
+		}
+		if !drainer.Drain(ctx, 5*time.Second) {
+			slog.Warn("Canceled requests still in flight after the drain timeout")
+		}
 	}()
 
+	// TLS on the TCP addresses when a certificate is configured, reloaded when it is rotated
+	var tlsConfig *tls.Config
+	if cfg.TLSCert != "" {
+		certs, err := server.NewCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			fatal("Failed to load TLS certificate", err)
+		}
+		defer certs.Close()
+		if tlsConfig, err = server.TLSConfig(cfg, certs); err != nil {
+			fatal("Failed to configure TLS", err)
+		}
+	}
+
 	// Start server
-	port := cfg.Port
-	log.Printf("Starting server on port %s", port)
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	addresses := server.Addresses(cfg)
+	listeners, err := server.Listen(addresses, tlsConfig)
+	if err != nil {
+		fatal("Failed to start server", err)
+	}
+	slog.Info("Starting server", "addresses", addresses, "tls", tlsConfig != nil)
+	served := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() { served <- app.Listener(ln) }()
 	}
+	for range listeners {
+		if err := <-served; err != nil {
+			fatal("Failed to serve", err)
+		}
+	}
+	<-shutdown
+
+	slog.Info("Server stopped")
+}
 
-	log.Println("Server stopped")
+// fatal logs a startup failure and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 // customErrorHandler handles errors globally
@@ -204,26 +398,41 @@ func customErrorHandler(c *fiber.Ctx, err error) error {
 	errorType := "unknown"
 
 	// Check if it's a Fiber error
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
-		message = e.Message
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code = fiberErr.Code
+		message = fiberErr.Message
 	}
 
 	// Check for custom errors
-	if e, ok := err.(*types.CustomError); ok {
-		code = e.Code
-		message = e.Message
-		errorType = e.Type
+	var customErr *types.CustomError
+	if errors.As(err, &customErr) {
+		code = customErr.Code
+		message = customErr.Message
+		errorType = customErr.Type
 	}
 
 	// Check for version errors
 	versionError := false
-	if code == fiber.StatusConflict || (message != "" && len(message) >= 9 && message[:9] == "E_VERSION") {
+	if code == fiber.StatusConflict || errors.Is(err, services.ErrVersionConflict) {
 		versionError = true
 		errorType = "version"
 		code = fiber.StatusConflict
 	}
 
+	// Client errors are in the access log, server errors are logged with their cause
+	if code >= fiber.StatusInternalServerError {
+		slog.ErrorContext(c.UserContext(), "Request failed", "error", err)
+	}
+
+	if utils.WantsProblem(c) {
+		var extensions fiber.Map
+		if versionError {
+			extensions = fiber.Map{"versionError": true}
+		}
+		return utils.ProblemResponse(c, code, message, errorType, extensions)
+	}
+
 	return c.Status(code).JSON(fiber.Map{
 		"status":       code,
 		"message":      message,
@@ -35,7 +35,9 @@ CREATE TABLE IF NOT EXISTS application_documents (
     document_name VARCHAR(255) NOT NULL UNIQUE,
     document_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    expires_at DATETIME(3) NULL,
+    INDEX idx_application_documents_expires_at (expires_at)
 );
 
 -- Create the application_collections table
@@ -43,7 +45,9 @@ CREATE TABLE IF NOT EXISTS application_collections (
     collection_id SERIAL PRIMARY KEY,
     collection_name VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    expires_at DATETIME(3) NULL,
+    INDEX idx_application_collections_expires_at (expires_at)
 );
 
 -- Create the application_properties table
@@ -51,8 +55,12 @@ CREATE TABLE IF NOT EXISTS application_properties (
     property_id SERIAL PRIMARY KEY,
     property_name VARCHAR(255) NOT NULL,
     property_value JSON,
+    property_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    modified_by VARCHAR(255) NOT NULL DEFAULT '',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    expires_at DATETIME(3) NULL,
+    INDEX idx_application_properties_expires_at (expires_at),
     CHECK (JSON_VALID(property_value))
 );
 
@@ -82,6 +90,8 @@ CREATE TABLE IF NOT EXISTS user_documents (
     document_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    expires_at DATETIME(3) NULL,
+    INDEX idx_user_documents_expires_at (expires_at),
     PRIMARY KEY (user_id, document_name),
     UNIQUE KEY (document_id),
     FOREIGN KEY (user_id) REFERENCES authorizer.authorizer_users(id) ON DELETE CASCADE
@@ -92,7 +102,9 @@ CREATE TABLE IF NOT EXISTS user_collections (
     collection_id SERIAL PRIMARY KEY,
     collection_name VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    expires_at DATETIME(3) NULL,
+    INDEX idx_user_collections_expires_at (expires_at)
 );
 
 -- Create the user_properties table
@@ -100,8 +112,12 @@ CREATE TABLE IF NOT EXISTS user_properties (
     property_id SERIAL PRIMARY KEY,
     property_name VARCHAR(255) NOT NULL,
     property_value JSON,
+    property_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    modified_by VARCHAR(255) NOT NULL DEFAULT '',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    expires_at DATETIME(3) NULL,
+    INDEX idx_user_properties_expires_at (expires_at),
     CHECK (JSON_VALID(property_value))
 );
 
@@ -121,4 +137,34 @@ CREATE TABLE IF NOT EXISTS user_collections_properties (
     PRIMARY KEY (collection_id, property_id),
     FOREIGN KEY (collection_id) REFERENCES user_collections(collection_id) ON DELETE CASCADE,
     FOREIGN KEY (property_id) REFERENCES user_properties(property_id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+
+-- Create the application_trash table, deleted documents and collections kept for restore
+CREATE TABLE IF NOT EXISTS application_trash (
+    trash_id SERIAL PRIMARY KEY,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    content JSON,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_application_trash_document_name (document_name),
+    INDEX idx_application_trash_deleted_at (deleted_at),
+    CHECK (JSON_VALID(content))
+);
+
+-- Create the user_trash table, deleted documents and collections kept for restore
+CREATE TABLE IF NOT EXISTS user_trash (
+    trash_id SERIAL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    content JSON,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_trash (user_id, document_name),
+    INDEX idx_user_trash_deleted_at (deleted_at),
+    FOREIGN KEY (user_id) REFERENCES authorizer.authorizer_users(id) ON DELETE CASCADE,
+    CHECK (JSON_VALID(content))
+);
@@ -55,6 +55,14 @@ GRANT SELECT ON jam_build.application_collections_properties TO 'jbuser'@'%';
 GRANT SELECT, INSERT, UPDATE, DELETE ON jam_build.user_documents_collections TO 'jbuser'@'%';
 GRANT SELECT, INSERT, UPDATE, DELETE ON jam_build.user_collections_properties TO 'jbuser'@'%';
 
+-- Grant SELECT, INSERT, UPDATE, DELETE permissions on the trash tables to jbadmin
+-- Grant SELECT permissions on application_trash to jbuser
+-- Grant SELECT, INSERT, UPDATE, DELETE permissions on user_trash to jbuser
+GRANT SELECT, INSERT, UPDATE, DELETE ON jam_build.application_trash TO 'jbadmin'@'%';
+GRANT SELECT, INSERT, UPDATE, DELETE ON jam_build.user_trash TO 'jbadmin'@'%';
+GRANT SELECT ON jam_build.application_trash TO 'jbuser'@'%';
+GRANT SELECT, INSERT, UPDATE, DELETE ON jam_build.user_trash TO 'jbuser'@'%';
+
 -- Grant SELECT permissions on authorizer_users table to both jbadmin and jbuser
 -- This is required for foreign key validation on user_documents
 GRANT SELECT ON authorizer.authorizer_users TO 'jbadmin'@'%';
@@ -11,25 +11,33 @@ CREATE TABLE IF NOT EXISTS application_documents (
     document_name VARCHAR(255) NOT NULL UNIQUE,
     document_version BIGINT NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP
 );
+CREATE INDEX IF NOT EXISTS idx_application_documents_expires_at ON application_documents (expires_at);
 
 -- Create the application_collections table
 CREATE TABLE IF NOT EXISTS application_collections (
     collection_id SERIAL PRIMARY KEY,
     collection_name VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP
 );
+CREATE INDEX IF NOT EXISTS idx_application_collections_expires_at ON application_collections (expires_at);
 
 -- Create the application_properties table
 CREATE TABLE IF NOT EXISTS application_properties (
     property_id SERIAL PRIMARY KEY,
     property_name VARCHAR(255) NOT NULL,
     property_value JSONB,
+    property_version BIGINT NOT NULL DEFAULT 0,
+    modified_by VARCHAR(255) NOT NULL DEFAULT '',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP
 );
+CREATE INDEX IF NOT EXISTS idx_application_properties_expires_at ON application_properties (expires_at);
 
 -- Junction tables
 CREATE TABLE IF NOT EXISTS application_documents_collections (
@@ -52,24 +60,32 @@ CREATE TABLE IF NOT EXISTS user_documents (
     document_version BIGINT NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
     PRIMARY KEY (user_id, document_name),
     FOREIGN KEY (user_id) REFERENCES authorizer_users(id) ON DELETE CASCADE
 );
+CREATE INDEX IF NOT EXISTS idx_user_documents_expires_at ON user_documents (expires_at);
 
 CREATE TABLE IF NOT EXISTS user_collections (
     collection_id SERIAL PRIMARY KEY,
     collection_name VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP
 );
+CREATE INDEX IF NOT EXISTS idx_user_collections_expires_at ON user_collections (expires_at);
 
 CREATE TABLE IF NOT EXISTS user_properties (
     property_id SERIAL PRIMARY KEY,
     property_name VARCHAR(255) NOT NULL,
     property_value JSONB,
+    property_version BIGINT NOT NULL DEFAULT 0,
+    modified_by VARCHAR(255) NOT NULL DEFAULT '',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP
 );
+CREATE INDEX IF NOT EXISTS idx_user_properties_expires_at ON user_properties (expires_at);
 
 CREATE TABLE IF NOT EXISTS user_documents_collections (
     document_id INTEGER NOT NULL REFERENCES user_documents(document_id) ON DELETE CASCADE,
@@ -82,6 +98,32 @@ CREATE TABLE IF NOT EXISTS user_collections_properties (
     property_id INTEGER NOT NULL REFERENCES user_properties(property_id) ON DELETE CASCADE,
     PRIMARY KEY (collection_id, property_id)
 );
+
+CREATE TABLE IF NOT EXISTS application_trash (
+    trash_id SERIAL PRIMARY KEY,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT NOT NULL DEFAULT 0,
+    content JSONB,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_application_trash_document_name ON application_trash (document_name);
+CREATE INDEX IF NOT EXISTS idx_application_trash_deleted_at ON application_trash (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_trash (
+    trash_id SERIAL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT NOT NULL DEFAULT 0,
+    content JSONB,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES authorizer_users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_user_trash ON user_trash (user_id, document_name);
+CREATE INDEX IF NOT EXISTS idx_user_trash_deleted_at ON user_trash (deleted_at);
 EOSQL
 
 echo "002-ddl-tables.sh complete."
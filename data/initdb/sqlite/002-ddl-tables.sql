@@ -8,26 +8,34 @@ CREATE TABLE IF NOT EXISTS application_documents (
     document_name TEXT NOT NULL UNIQUE,
     document_version INTEGER NOT NULL DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME
 );
+CREATE INDEX IF NOT EXISTS idx_application_documents_expires_at ON application_documents (expires_at);
 
 -- Create the application_collections table
 CREATE TABLE IF NOT EXISTS application_collections (
     collection_id INTEGER PRIMARY KEY AUTOINCREMENT,
     collection_name TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME
 );
+CREATE INDEX IF NOT EXISTS idx_application_collections_expires_at ON application_collections (expires_at);
 
 -- Create the application_properties table
 CREATE TABLE IF NOT EXISTS application_properties (
     property_id INTEGER PRIMARY KEY AUTOINCREMENT,
     property_name TEXT NOT NULL,
     property_value TEXT,
+    property_version INTEGER NOT NULL DEFAULT 0,
+    modified_by TEXT NOT NULL DEFAULT '',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
     CHECK (json_valid(property_value))
 );
+CREATE INDEX IF NOT EXISTS idx_application_properties_expires_at ON application_properties (expires_at);
 
 -- Junction tables
 CREATE TABLE IF NOT EXISTS application_documents_collections (
@@ -54,24 +62,32 @@ CREATE TABLE IF NOT EXISTS user_documents (
     document_version INTEGER NOT NULL DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
     PRIMARY KEY (user_id, document_name)
 );
+CREATE INDEX IF NOT EXISTS idx_user_documents_expires_at ON user_documents (expires_at);
 
 CREATE TABLE IF NOT EXISTS user_collections (
     collection_id INTEGER PRIMARY KEY AUTOINCREMENT,
     collection_name TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME
 );
+CREATE INDEX IF NOT EXISTS idx_user_collections_expires_at ON user_collections (expires_at);
 
 CREATE TABLE IF NOT EXISTS user_properties (
     property_id INTEGER PRIMARY KEY AUTOINCREMENT,
     property_name TEXT NOT NULL,
     property_value TEXT,
+    property_version INTEGER NOT NULL DEFAULT 0,
+    modified_by TEXT NOT NULL DEFAULT '',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
     CHECK (json_valid(property_value))
 );
+CREATE INDEX IF NOT EXISTS idx_user_properties_expires_at ON user_properties (expires_at);
 
 CREATE TABLE IF NOT EXISTS user_documents_collections (
     document_id INTEGER NOT NULL,
@@ -88,3 +104,30 @@ CREATE TABLE IF NOT EXISTS user_collections_properties (
     FOREIGN KEY (collection_id) REFERENCES user_collections(collection_id) ON DELETE CASCADE,
     FOREIGN KEY (property_id) REFERENCES user_properties(property_id) ON DELETE CASCADE
 );
+
+CREATE TABLE IF NOT EXISTS application_trash (
+    trash_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_name TEXT NOT NULL,
+    collection_name TEXT NOT NULL DEFAULT '',
+    document_version INTEGER NOT NULL DEFAULT 0,
+    content TEXT,
+    deleted_by TEXT NOT NULL DEFAULT '',
+    deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (json_valid(content))
+);
+CREATE INDEX IF NOT EXISTS idx_application_trash_document_name ON application_trash (document_name);
+CREATE INDEX IF NOT EXISTS idx_application_trash_deleted_at ON application_trash (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_trash (
+    trash_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    document_name TEXT NOT NULL,
+    collection_name TEXT NOT NULL DEFAULT '',
+    document_version INTEGER NOT NULL DEFAULT 0,
+    content TEXT,
+    deleted_by TEXT NOT NULL DEFAULT '',
+    deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (json_valid(content))
+);
+CREATE INDEX IF NOT EXISTS idx_user_trash ON user_trash (user_id, document_name);
+CREATE INDEX IF NOT EXISTS idx_user_trash_deleted_at ON user_trash (deleted_at);
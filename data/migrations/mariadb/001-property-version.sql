@@ -0,0 +1,23 @@
+--
+-- Track the document version of each property's last change, used by mergeStrategy "merge" writes.
+-- Existing properties take their document's current version, so stale merge bases conflict instead of overwriting.
+--
+
+ALTER TABLE application_properties ADD COLUMN property_version BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER property_value;
+ALTER TABLE user_properties ADD COLUMN property_version BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER property_value;
+
+UPDATE application_properties SET property_version = (
+    SELECT COALESCE(MAX(d.document_version), 0)
+    FROM application_collections_properties cp
+    JOIN application_documents_collections dc ON dc.collection_id = cp.collection_id
+    JOIN application_documents d ON d.document_id = dc.document_id
+    WHERE cp.property_id = application_properties.property_id
+);
+
+UPDATE user_properties SET property_version = (
+    SELECT COALESCE(MAX(d.document_version), 0)
+    FROM user_collections_properties cp
+    JOIN user_documents_collections dc ON dc.collection_id = cp.collection_id
+    JOIN user_documents d ON d.document_id = dc.document_id
+    WHERE cp.property_id = user_properties.property_id
+);
@@ -0,0 +1,7 @@
+--
+-- Record the actor of each property's last change, returned with include=meta.
+-- Properties changed before this migration have no recorded actor.
+--
+
+ALTER TABLE application_properties ADD COLUMN modified_by VARCHAR(255) NOT NULL DEFAULT '' AFTER property_version;
+ALTER TABLE user_properties ADD COLUMN modified_by VARCHAR(255) NOT NULL DEFAULT '' AFTER property_version;
@@ -0,0 +1,37 @@
+--
+-- Keep deleted documents and collections in trash tables, restorable until purged after TRASH_RETENTION_HOURS.
+--
+
+CREATE TABLE IF NOT EXISTS application_trash (
+    trash_id SERIAL PRIMARY KEY,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    content JSON,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_application_trash_document_name (document_name),
+    INDEX idx_application_trash_deleted_at (deleted_at),
+    CHECK (JSON_VALID(content))
+);
+
+CREATE TABLE IF NOT EXISTS user_trash (
+    trash_id SERIAL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    content JSON,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_trash (user_id, document_name),
+    INDEX idx_user_trash_deleted_at (deleted_at),
+    FOREIGN KEY (user_id) REFERENCES authorizer.authorizer_users(id) ON DELETE CASCADE,
+    CHECK (JSON_VALID(content))
+);
+
+-- Databases initialized before the trash tables only grant the pools the earlier tables
+GRANT SELECT, INSERT, UPDATE, DELETE ON application_trash TO 'jbadmin'@'%';
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_trash TO 'jbadmin'@'%';
+GRANT SELECT ON application_trash TO 'jbuser'@'%';
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_trash TO 'jbuser'@'%';
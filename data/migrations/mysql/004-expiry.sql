@@ -0,0 +1,53 @@
+--
+-- Optional expiry of documents, collections and properties, set with ttl or expiresAt on writes.
+-- Expired rows are hidden from reads and removed every EXPIRY_SWEEP_SECONDS.
+-- MySQL has no ADD COLUMN IF NOT EXISTS, so each column and its index are added only when the column is missing.
+--
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'application_documents' AND column_name = 'expires_at') = 0,
+    'ALTER TABLE application_documents ADD COLUMN expires_at DATETIME(3) NULL, ADD INDEX idx_application_documents_expires_at (expires_at)',
+    'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'application_collections' AND column_name = 'expires_at') = 0,
+    'ALTER TABLE application_collections ADD COLUMN expires_at DATETIME(3) NULL, ADD INDEX idx_application_collections_expires_at (expires_at)',
+    'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'application_properties' AND column_name = 'expires_at') = 0,
+    'ALTER TABLE application_properties ADD COLUMN expires_at DATETIME(3) NULL, ADD INDEX idx_application_properties_expires_at (expires_at)',
+    'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'user_documents' AND column_name = 'expires_at') = 0,
+    'ALTER TABLE user_documents ADD COLUMN expires_at DATETIME(3) NULL, ADD INDEX idx_user_documents_expires_at (expires_at)',
+    'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'user_collections' AND column_name = 'expires_at') = 0,
+    'ALTER TABLE user_collections ADD COLUMN expires_at DATETIME(3) NULL, ADD INDEX idx_user_collections_expires_at (expires_at)',
+    'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'user_properties' AND column_name = 'expires_at') = 0,
+    'ALTER TABLE user_properties ADD COLUMN expires_at DATETIME(3) NULL, ADD INDEX idx_user_properties_expires_at (expires_at)',
+    'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,36 @@
+--
+-- Keep deleted documents and collections in trash tables, restorable until purged after TRASH_RETENTION_HOURS.
+--
+
+CREATE TABLE IF NOT EXISTS application_trash (
+    trash_id SERIAL PRIMARY KEY,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT NOT NULL DEFAULT 0,
+    content JSONB,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_application_trash_document_name ON application_trash (document_name);
+CREATE INDEX IF NOT EXISTS idx_application_trash_deleted_at ON application_trash (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_trash (
+    trash_id SERIAL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    document_name VARCHAR(255) NOT NULL,
+    collection_name VARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT NOT NULL DEFAULT 0,
+    content JSONB,
+    deleted_by VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES authorizer_users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_user_trash ON user_trash (user_id, document_name);
+CREATE INDEX IF NOT EXISTS idx_user_trash_deleted_at ON user_trash (deleted_at);
+
+-- Databases initialized before the trash tables only grant the pools the earlier tables
+GRANT SELECT, INSERT, UPDATE, DELETE ON application_trash, user_trash TO jbadmin;
+GRANT USAGE, SELECT ON SEQUENCE application_trash_trash_id_seq, user_trash_trash_id_seq TO jbadmin;
+GRANT SELECT ON application_trash TO jbuser;
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_trash TO jbuser;
+GRANT USAGE, SELECT ON SEQUENCE user_trash_trash_id_seq TO jbuser;
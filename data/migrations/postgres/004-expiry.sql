@@ -0,0 +1,17 @@
+--
+-- Optional expiry of documents, collections and properties, set with ttl or expiresAt on writes.
+-- Expired rows are hidden from reads and removed every EXPIRY_SWEEP_SECONDS.
+--
+
+ALTER TABLE application_documents ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+ALTER TABLE application_collections ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+ALTER TABLE application_properties ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+ALTER TABLE user_documents ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+ALTER TABLE user_collections ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+ALTER TABLE user_properties ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+CREATE INDEX IF NOT EXISTS idx_application_documents_expires_at ON application_documents (expires_at);
+CREATE INDEX IF NOT EXISTS idx_application_collections_expires_at ON application_collections (expires_at);
+CREATE INDEX IF NOT EXISTS idx_application_properties_expires_at ON application_properties (expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_documents_expires_at ON user_documents (expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_collections_expires_at ON user_collections (expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_properties_expires_at ON user_properties (expires_at);
@@ -0,0 +1,30 @@
+--
+-- Keep deleted documents and collections in trash tables, restorable until purged after TRASH_RETENTION_HOURS.
+--
+
+CREATE TABLE IF NOT EXISTS application_trash (
+    trash_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_name TEXT NOT NULL,
+    collection_name TEXT NOT NULL DEFAULT '',
+    document_version INTEGER NOT NULL DEFAULT 0,
+    content TEXT,
+    deleted_by TEXT NOT NULL DEFAULT '',
+    deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (json_valid(content))
+);
+CREATE INDEX IF NOT EXISTS idx_application_trash_document_name ON application_trash (document_name);
+CREATE INDEX IF NOT EXISTS idx_application_trash_deleted_at ON application_trash (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_trash (
+    trash_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    document_name TEXT NOT NULL,
+    collection_name TEXT NOT NULL DEFAULT '',
+    document_version INTEGER NOT NULL DEFAULT 0,
+    content TEXT,
+    deleted_by TEXT NOT NULL DEFAULT '',
+    deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (json_valid(content))
+);
+CREATE INDEX IF NOT EXISTS idx_user_trash ON user_trash (user_id, document_name);
+CREATE INDEX IF NOT EXISTS idx_user_trash_deleted_at ON user_trash (deleted_at);
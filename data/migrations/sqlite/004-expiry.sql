@@ -0,0 +1,19 @@
+--
+-- Optional expiry of documents, collections and properties, set with ttl or expiresAt on writes.
+-- Expired rows are hidden from reads and removed every EXPIRY_SWEEP_SECONDS.
+-- SQLite has no ADD COLUMN IF NOT EXISTS. Run again, each ALTER fails alone with "duplicate column name"
+-- and changes nothing, so apply this file without -bail, or skip it once expires_at exists.
+--
+
+ALTER TABLE application_documents ADD COLUMN expires_at DATETIME NULL;
+ALTER TABLE application_collections ADD COLUMN expires_at DATETIME NULL;
+ALTER TABLE application_properties ADD COLUMN expires_at DATETIME NULL;
+ALTER TABLE user_documents ADD COLUMN expires_at DATETIME NULL;
+ALTER TABLE user_collections ADD COLUMN expires_at DATETIME NULL;
+ALTER TABLE user_properties ADD COLUMN expires_at DATETIME NULL;
+CREATE INDEX IF NOT EXISTS idx_application_documents_expires_at ON application_documents (expires_at);
+CREATE INDEX IF NOT EXISTS idx_application_collections_expires_at ON application_collections (expires_at);
+CREATE INDEX IF NOT EXISTS idx_application_properties_expires_at ON application_properties (expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_documents_expires_at ON user_documents (expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_collections_expires_at ON user_collections (expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_properties_expires_at ON user_properties (expires_at);
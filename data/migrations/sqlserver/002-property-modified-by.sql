@@ -0,0 +1,8 @@
+--
+-- Record the actor of each property's last change, returned with include=meta.
+-- Properties changed before this migration have no recorded actor.
+--
+
+ALTER TABLE application_properties ADD modified_by NVARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE user_properties ADD modified_by NVARCHAR(255) NOT NULL DEFAULT '';
+GO
@@ -0,0 +1,37 @@
+--
+-- Keep deleted documents and collections in trash tables, restorable until purged after TRASH_RETENTION_HOURS.
+--
+
+CREATE TABLE application_trash (
+    trash_id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    document_name NVARCHAR(255) NOT NULL,
+    collection_name NVARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT NOT NULL DEFAULT 0,
+    content NVARCHAR(MAX),
+    deleted_by NVARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+);
+CREATE INDEX idx_application_trash_document_name ON application_trash (document_name);
+CREATE INDEX idx_application_trash_deleted_at ON application_trash (deleted_at);
+GO
+
+CREATE TABLE user_trash (
+    trash_id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    document_name NVARCHAR(255) NOT NULL,
+    collection_name NVARCHAR(255) NOT NULL DEFAULT '',
+    document_version BIGINT NOT NULL DEFAULT 0,
+    content NVARCHAR(MAX),
+    deleted_by NVARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+);
+CREATE INDEX idx_user_trash ON user_trash (user_id, document_name);
+CREATE INDEX idx_user_trash_deleted_at ON user_trash (deleted_at);
+GO
+
+-- Databases initialized before the trash tables only grant the pools the earlier tables
+GRANT SELECT, INSERT, UPDATE, DELETE ON application_trash TO jbadmin;
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_trash TO jbadmin;
+GRANT SELECT ON application_trash TO jbuser;
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_trash TO jbuser;
+GO
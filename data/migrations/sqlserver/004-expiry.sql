@@ -0,0 +1,32 @@
+--
+-- Optional expiry of documents, collections and properties, set with ttl or expiresAt on writes.
+-- Expired rows are hidden from reads and removed every EXPIRY_SWEEP_SECONDS.
+--
+
+IF COL_LENGTH('application_documents', 'expires_at') IS NULL
+    ALTER TABLE application_documents ADD expires_at DATETIME2 NULL;
+IF COL_LENGTH('application_collections', 'expires_at') IS NULL
+    ALTER TABLE application_collections ADD expires_at DATETIME2 NULL;
+IF COL_LENGTH('application_properties', 'expires_at') IS NULL
+    ALTER TABLE application_properties ADD expires_at DATETIME2 NULL;
+IF COL_LENGTH('user_documents', 'expires_at') IS NULL
+    ALTER TABLE user_documents ADD expires_at DATETIME2 NULL;
+IF COL_LENGTH('user_collections', 'expires_at') IS NULL
+    ALTER TABLE user_collections ADD expires_at DATETIME2 NULL;
+IF COL_LENGTH('user_properties', 'expires_at') IS NULL
+    ALTER TABLE user_properties ADD expires_at DATETIME2 NULL;
+GO
+
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'idx_application_documents_expires_at' AND object_id = OBJECT_ID('application_documents'))
+    CREATE INDEX idx_application_documents_expires_at ON application_documents (expires_at);
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'idx_application_collections_expires_at' AND object_id = OBJECT_ID('application_collections'))
+    CREATE INDEX idx_application_collections_expires_at ON application_collections (expires_at);
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'idx_application_properties_expires_at' AND object_id = OBJECT_ID('application_properties'))
+    CREATE INDEX idx_application_properties_expires_at ON application_properties (expires_at);
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'idx_user_documents_expires_at' AND object_id = OBJECT_ID('user_documents'))
+    CREATE INDEX idx_user_documents_expires_at ON user_documents (expires_at);
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'idx_user_collections_expires_at' AND object_id = OBJECT_ID('user_collections'))
+    CREATE INDEX idx_user_collections_expires_at ON user_collections (expires_at);
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'idx_user_properties_expires_at' AND object_id = OBJECT_ID('user_properties'))
+    CREATE INDEX idx_user_properties_expires_at ON user_properties (expires_at);
+GO
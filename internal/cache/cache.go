@@ -0,0 +1,74 @@
+// cache.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Cache is a byte cache with per-entry expiry, shared by the response caching and idempotency middleware
+type Cache interface {
+	// Get returns the value for key, and false if it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl, zero meaning no expiry
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add stores value under key for ttl only if key is missing or expired, and reports whether it did
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes key
+	Delete(ctx context.Context, key string) error
+	// Purge removes every entry
+	Purge(ctx context.Context) error
+	// Close releases any resources held by the cache
+	Close() error
+}
+
+// Generational is a Cache shared by instances, whose Purge starts a new generation of entries.
+// A value read in one generation and stored in it again is never seen after another instance's purge.
+type Generational interface {
+	Cache
+	// Generation returns the current generation
+	Generation(ctx context.Context) (string, error)
+	// GetIn returns the value for key in generation, and false if it is missing or expired
+	GetIn(ctx context.Context, generation, key string) ([]byte, bool, error)
+	// SetIn stores value under key in generation for ttl
+	SetIn(ctx context.Context, generation, key string, value []byte, ttl time.Duration) error
+}
+
+// Options selects and configures a Cache implementation
+type Options struct {
+	Backend    string // memory or redis
+	MaxEntries int    // memory only
+	RedisURL   string // redis only
+	Prefix     string // redis only, namespaces keys in a shared server
+}
+
+// New creates the Cache selected by opts.Backend
+func New(opts Options) (Cache, error) {
+	switch opts.Backend {
+	case "memory":
+		return NewLRU(opts.MaxEntries), nil
+	case "redis":
+		return NewRedis(opts.RedisURL, opts.Prefix)
+	default:
+		return nil, fmt.Errorf("unsupported cache backend: %s", opts.Backend)
+	}
+}
@@ -0,0 +1,147 @@
+// redis.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Cache stored in a Redis compatible server, shared by all service instances.
+// Purge increments a generation number that is part of every key, so all instances
+// stop seeing old entries at once and the old entries age out by their ttl.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis connects to the Redis server at url (redis://host:port/db) and namespaces keys with prefix
+func NewRedis(url, prefix string) (*Redis, error) {
+	if url == "" {
+		return nil, fmt.Errorf("redis cache requires a REDIS_URL")
+	}
+
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	if prefix == "" {
+		prefix = "propsdb"
+	}
+
+	return &Redis{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+// Get returns the value for key in the current generation, and false if it is missing or expired
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	generation, err := r.Generation(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return r.GetIn(ctx, generation, key)
+}
+
+// GetIn returns the value for key in generation, and false if it is missing or expired
+func (r *Redis) GetIn(ctx context.Context, generation, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.keyIn(generation, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+// Set stores value under key in the current generation for ttl
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	generation, err := r.Generation(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.SetIn(ctx, generation, key, value, ttl)
+}
+
+// SetIn stores value under key in generation for ttl, where a past generation is never read again
+func (r *Redis) SetIn(ctx context.Context, generation, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.keyIn(generation, key), value, ttl).Err()
+}
+
+// Add stores value under key in the current generation for ttl, only if key is missing
+func (r *Redis) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	fullKey, err := r.key(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.SetNX(ctx, fullKey, value, ttl).Result()
+}
+
+// Delete removes key from the current generation
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	fullKey, err := r.key(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Del(ctx, fullKey).Err()
+}
+
+// Purge starts a new generation, invalidating every entry for all instances
+func (r *Redis) Purge(ctx context.Context) error {
+	return r.client.Incr(ctx, r.prefix+":generation").Err()
+}
+
+// Close closes the connection pool
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// Generation returns the current generation, "0" before the first purge
+func (r *Redis) Generation(ctx context.Context) (string, error) {
+	generation, err := r.client.Get(ctx, r.prefix+":generation").Result()
+	if errors.Is(err, redis.Nil) {
+		return "0", nil
+	}
+	return generation, err
+}
+
+// key namespaces key with the prefix and current generation
+func (r *Redis) key(ctx context.Context, key string) (string, error) {
+	generation, err := r.Generation(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return r.keyIn(generation, key), nil
+}
+
+// keyIn namespaces key with the prefix and generation
+func (r *Redis) keyIn(generation, key string) string {
+	return r.prefix + ":" + generation + ":" + key
+}
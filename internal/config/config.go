@@ -20,88 +20,221 @@
 package config
 
 import (
-	"fmt"
+	"errors"
 	"os"
-	"strconv"
 )
 
-// Config holds all application configuration
+// Config holds all application configuration. Each setting is named by its environment variable in the
+// env tag, which also gives its config file key (lowercase) and flag (lowercase, dashed). Secret settings
+// are redacted when printed and can be read from the file named by <env>_FILE.
 type Config struct {
 	// Server configuration
-	Port string
+	Port   string   `env:"PORT" default:"3000"`
+	Listen []string `env:"LISTEN"` // addresses served, host:port or unix:/path, ":PORT" when empty
+
+	// TLS configuration, for TCP addresses
+	TLSCert     string `env:"TLS_CERT"`      // PEM certificate chain, reloaded when it changes
+	TLSKey      string `env:"TLS_KEY"`       // PEM key of the certificate, reloaded with it
+	TLSClientCA string `env:"TLS_CLIENT_CA"` // PEM CAs verifying client certificates, which admin routes then require
+
+	// Security header configuration
+	SecurityHeaders bool `env:"SECURITY_HEADERS" default:"false"` // send helmet security headers
+	HSTSMaxAge      int  `env:"HSTS_MAX_AGE" default:"0"`         // Strict-Transport-Security max-age seconds on HTTPS, 0 omits it
+	HSTSPreload     bool `env:"HSTS_PRELOAD" default:"false"`     // add preload to Strict-Transport-Security
 
 	// Database configuration
-	DBType               string // mysql, postgres, sqlite, sqlserver, etc.
-	DBHost               string
-	DBPort               string
-	DBAppDatabase        string
-	DBAppUser            string
-	DBAppPassword        string
-	DBAppConnectionLimit int
-	DBUser               string
-	DBPassword           string
-	DBConnectionLimit    int
+	DBType               string `env:"DB_TYPE" default:"mysql"` // mysql, postgres, sqlite, sqlserver, etc.
+	DBHost               string `env:"DB_HOST" default:"localhost"`
+	DBPort               string `env:"DB_PORT" default:"3306"`
+	DBAppDatabase        string `env:"DB_APP_DATABASE"`
+	DBAppUser            string `env:"DB_APP_USER"`
+	DBAppPassword        string `env:"DB_APP_PASSWORD" secret:"true"`
+	DBAppConnectionLimit int    `env:"DB_APP_CONNECTION_LIMIT" default:"5"`
+	DBUser               string `env:"DB_USER"`
+	DBPassword           string `env:"DB_PASSWORD" secret:"true"`
+	DBConnectionLimit    int    `env:"DB_CONNECTION_LIMIT" default:"5"`
+	DBDSN                string `env:"DB_DSN" secret:"true"`      // app pool DSN in the driver's format for DB_TYPE, used as is
+	DBUserDSN            string `env:"DB_USER_DSN" secret:"true"` // user pool DSN in the driver's format for DB_TYPE, used as is
+	DBTimezone           string `env:"DB_TIMEZONE"`               // session time zone, Local for mysql and UTC for postgres when empty
+
+	// Database TLS configuration, applied to the DSNs built from the settings above
+	DBTLSMode string `env:"DB_TLS_MODE" default:"disable"` // disable, require, verify-ca, or verify-full
+	DBTLSCA   string `env:"DB_TLS_CA"`                     // PEM file of the CAs verifying the server, system CAs when empty
+	DBTLSCert string `env:"DB_TLS_CERT"`                   // PEM file of the client certificate
+	DBTLSKey  string `env:"DB_TLS_KEY"`                    // PEM file of the client certificate's key
+
+	// Read replica configuration, DSNs in the driver's format for DB_TYPE
+	DBAppReplicas          []string `env:"DB_APP_REPLICAS" secret:"true"`
+	DBUserReplicas         []string `env:"DB_USER_REPLICAS" secret:"true"`
+	DBReplicaStickySeconds int      `env:"DB_REPLICA_STICKY_SECONDS" default:"5"` // read-your-writes window after a mutation
+	DBReplicaCheckSeconds  int      `env:"DB_REPLICA_CHECK_SECONDS" default:"10"` // replica health check interval
+
+	// Connection pool and resilience configuration
+	DBConnMaxLifetimeSeconds int `env:"DB_CONN_MAX_LIFETIME_SECONDS" default:"1800"` // seconds a connection is reused, 0 without limit
+	DBConnMaxIdleSeconds     int `env:"DB_CONN_MAX_IDLE_SECONDS" default:"300"`      // seconds an idle connection is kept, 0 without limit
+	DBConnectRetrySeconds    int `env:"DB_CONNECT_RETRY_SECONDS" default:"30"`       // seconds startup retries connecting, 0 fails at once
+	DBQueryTimeoutMS         int `env:"DB_QUERY_TIMEOUT_MS" default:"10000"`         // milliseconds each data service call may take, 0 without limit
+	DBBreakerThreshold       int `env:"DB_BREAKER_THRESHOLD" default:"5"`            // consecutive connection failures opening a pool's circuit breaker, 0 disables
+	DBBreakerCooldownSeconds int `env:"DB_BREAKER_COOLDOWN_SECONDS" default:"5"`     // seconds an open circuit breaker fails fast before probing
 
 	// Authorizer configuration
-	AuthzURL      string
-	AuthzClientID string
+	AuthzURL      string `env:"AUTHZ_URL"`
+	AuthzClientID string `env:"AUTHZ_CLIENT_ID"`
+
+	// App data response cache configuration
+	AppCache       string `env:"APP_CACHE" default:"memory"`    // memory, redis, or none
+	AppCacheSize   int    `env:"APP_CACHE_SIZE" default:"1000"` // memory cache entries
+	AppCacheTTL    int    `env:"APP_CACHE_TTL" default:"300"`   // seconds
+	AppCacheMaxAge int    `env:"APP_CACHE_MAX_AGE" default:"0"` // Cache-Control max-age seconds
+	RedisURL       string `env:"REDIS_URL" secret:"true"`
+
+	// Mutation Idempotency-Key store configuration
+	IdempotencyStore string `env:"IDEMPOTENCY_STORE" default:"memory"` // memory, redis, or none
+	IdempotencySize  int    `env:"IDEMPOTENCY_SIZE" default:"10000"`   // memory store entries
+	IdempotencyTTL   int    `env:"IDEMPOTENCY_TTL" default:"86400"`    // seconds a completed response replays
+
+	// Deleted document and collection trash configuration
+	TrashRetentionHours     int `env:"TRASH_RETENTION_HOURS" default:"720"`    // hours before deleted data is purged, 0 keeps it until restored
+	TrashPurgeIntervalHours int `env:"TRASH_PURGE_INTERVAL_HOURS" default:"1"` // hours between purges
+
+	// Expired data configuration
+	ExpirySweepSeconds int `env:"EXPIRY_SWEEP_SECONDS" default:"60"` // seconds between sweeps removing expired data
+
+	// Tracing configuration
+	TracingExporter     string  `env:"TRACING_EXPORTER" default:"none"`      // otlp, stdout, file, or none
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`                // OTLP collector URL, OTEL_EXPORTER_OTLP_* variables apply when empty
+	TracingOTLPProtocol string  `env:"TRACING_OTLP_PROTOCOL" default:"http"` // http or grpc
+	TracingFile         string  `env:"TRACING_FILE" default:"traces.jsonl"`  // file receiving spans as JSON lines for the file exporter
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" default:"1"`     // fraction of new traces sampled, incoming sampling decisions are kept
+
+	// Logging configuration
+	LogLevel       string `env:"LOG_LEVEL" default:"info"`        // debug, info, warn, or error
+	LogFormat      string `env:"LOG_FORMAT" default:"json"`       // json or text
+	LogSlowQueryMS int    `env:"LOG_SLOW_QUERY_MS" default:"200"` // milliseconds before a statement is logged as slow, 0 disables
+
+	// Request deadline and shutdown configuration
+	RequestReadTimeoutMS  int `env:"REQUEST_READ_TIMEOUT_MS" default:"10000"`  // milliseconds a data GET may take, 0 without limit
+	RequestWriteTimeoutMS int `env:"REQUEST_WRITE_TIMEOUT_MS" default:"30000"` // milliseconds a data mutation may take, 0 without limit
+	ShutdownDrainSeconds  int `env:"SHUTDOWN_DRAIN_SECONDS" default:"20"`      // seconds shutdown waits for requests in flight before canceling them
+
+	// Health endpoint configuration
+	HealthTimeoutMS int `env:"HEALTH_TIMEOUT_MS" default:"2000"` // milliseconds each dependency check may take
+	HealthCacheMS   int `env:"HEALTH_CACHE_MS" default:"2000"`   // milliseconds a health result is reused, 0 checks on every probe
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
-	cfg := &Config{
-		Port:                 getEnv("PORT", "3000"),
-		DBType:               getEnv("DB_TYPE", "mysql"),
-		DBHost:               getEnv("DB_HOST", "localhost"),
-		DBPort:               getEnv("DB_PORT", "3306"),
-		DBAppDatabase:        getEnv("DB_APP_DATABASE", ""),
-		DBAppUser:            getEnv("DB_APP_USER", ""),
-		DBAppPassword:        getEnv("DB_APP_PASSWORD", ""),
-		DBAppConnectionLimit: getEnvAsInt("DB_APP_CONNECTION_LIMIT", 5),
-		DBUser:               getEnv("DB_USER", ""),
-		DBPassword:           getEnv("DB_PASSWORD", ""),
-		DBConnectionLimit:    getEnvAsInt("DB_CONNECTION_LIMIT", 5),
-		AuthzURL:             getEnv("AUTHZ_URL", ""),
-		AuthzClientID:        getEnv("AUTHZ_CLIENT_ID", ""),
+// Load loads the configuration in layers, each overriding the last: defaults, the YAML or TOML file named
+// by --config or CONFIG_FILE, environment variables, then the flags in args. Every invalid value is reported.
+func Load(args ...string) (*Config, error) {
+	flags, configFile, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
 	}
 
-	// Validate required fields
-	if cfg.DBAppDatabase == "" {
-		return nil, fmt.Errorf("DB_APP_DATABASE is required")
+	cfg := &Config{}
+	errs := []error{applyDefaults(cfg)}
+	if configFile != "" {
+		errs = append(errs, applyFile(cfg, configFile))
 	}
-	if cfg.DBAppUser == "" {
-		return nil, fmt.Errorf("DB_APP_USER is required")
+	errs = append(errs, applyEnv(cfg), applyFlags(cfg, flags), cfg.validate())
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate checks the loaded values, reporting every problem
+func (cfg *Config) validate() error {
+	var errs []error
+	check := func(ok bool, message string) {
+		if !ok {
+			errs = append(errs, errors.New(message))
+		}
+	}
+
+	// Validate required fields
+	check(cfg.DBAppDatabase != "" || (cfg.DBDSN != "" && cfg.DBUserDSN != ""), "DB_APP_DATABASE is required")
+	check(cfg.DBAppUser != "" || cfg.DBDSN != "", "DB_APP_USER is required")
+	check(cfg.DBUser != "" || cfg.DBUserDSN != "", "DB_USER is required")
+	check(cfg.AuthzURL != "", "AUTHZ_URL is required")
+	check(cfg.AuthzClientID != "", "AUTHZ_CLIENT_ID is required")
+
+	for _, address := range cfg.Listen {
+		check(address != "unix:", "LISTEN unix addresses need a socket path")
 	}
-	if cfg.DBUser == "" {
-		return nil, fmt.Errorf("DB_USER is required")
+	check((cfg.TLSCert == "") == (cfg.TLSKey == ""), "TLS_CERT and TLS_KEY must be set together")
+	check(cfg.TLSClientCA == "" || cfg.TLSCert != "", "TLS_CLIENT_CA requires TLS_CERT")
+	check(cfg.HSTSMaxAge >= 0, "HSTS_MAX_AGE must not be negative")
+	check(cfg.HSTSMaxAge == 0 || cfg.SecurityHeaders, "HSTS_MAX_AGE requires SECURITY_HEADERS")
+	check(!cfg.HSTSPreload || cfg.HSTSMaxAge > 0, "HSTS_PRELOAD requires HSTS_MAX_AGE")
+
+	switch cfg.AppCache {
+	case "memory", "none":
+	case "redis":
+		check(cfg.RedisURL != "", "REDIS_URL is required when APP_CACHE is redis")
+	default:
+		check(false, "APP_CACHE must be memory, redis, or none")
 	}
-	if cfg.AuthzURL == "" {
-		return nil, fmt.Errorf("AUTHZ_URL is required")
+	switch cfg.IdempotencyStore {
+	case "memory", "none":
+	case "redis":
+		check(cfg.RedisURL != "", "REDIS_URL is required when IDEMPOTENCY_STORE is redis")
+	default:
+		check(false, "IDEMPOTENCY_STORE must be memory, redis, or none")
 	}
-	if cfg.AuthzClientID == "" {
-		return nil, fmt.Errorf("AUTHZ_CLIENT_ID is required")
+	switch cfg.DBTLSMode {
+	case "disable":
+		check(cfg.DBTLSCA == "" && cfg.DBTLSCert == "", "DB_TLS_CA and DB_TLS_CERT require a DB_TLS_MODE other than disable")
+	case "require", "verify-ca", "verify-full":
+		check(cfg.DBType != "sqlite", "DB_TLS_MODE must be disable for sqlite")
+		check(cfg.DBTLSCert == "" || (cfg.DBType != "sqlserver" && cfg.DBType != "mssql"), "DB_TLS_CERT is not supported for sqlserver")
+	default:
+		check(false, "DB_TLS_MODE must be disable, require, verify-ca, or verify-full")
 	}
+	check((cfg.DBTLSCert == "") == (cfg.DBTLSKey == ""), "DB_TLS_CERT and DB_TLS_KEY must be set together")
 
-	return cfg, nil
-}
+	check(cfg.DBAppConnectionLimit > 0, "DB_APP_CONNECTION_LIMIT must be positive")
+	check(cfg.DBConnectionLimit > 0, "DB_CONNECTION_LIMIT must be positive")
+	check(cfg.DBReplicaStickySeconds >= 0, "DB_REPLICA_STICKY_SECONDS must not be negative")
+	check(cfg.DBReplicaCheckSeconds > 0, "DB_REPLICA_CHECK_SECONDS must be positive")
+	check(cfg.DBConnMaxLifetimeSeconds >= 0, "DB_CONN_MAX_LIFETIME_SECONDS must not be negative")
+	check(cfg.DBConnMaxIdleSeconds >= 0, "DB_CONN_MAX_IDLE_SECONDS must not be negative")
+	check(cfg.DBConnectRetrySeconds >= 0, "DB_CONNECT_RETRY_SECONDS must not be negative")
+	check(cfg.DBQueryTimeoutMS >= 0, "DB_QUERY_TIMEOUT_MS must not be negative")
+	check(cfg.DBBreakerThreshold >= 0, "DB_BREAKER_THRESHOLD must not be negative")
+	check(cfg.DBBreakerCooldownSeconds > 0, "DB_BREAKER_COOLDOWN_SECONDS must be positive")
+	check(cfg.AppCacheSize > 0, "APP_CACHE_SIZE must be positive")
+	check(cfg.AppCacheTTL >= 0, "APP_CACHE_TTL must not be negative")
+	check(cfg.AppCacheMaxAge >= 0, "APP_CACHE_MAX_AGE must not be negative")
+	check(cfg.IdempotencySize > 0, "IDEMPOTENCY_SIZE must be positive")
+	check(cfg.IdempotencyTTL > 0, "IDEMPOTENCY_TTL must be positive")
+	check(cfg.TrashRetentionHours >= 0, "TRASH_RETENTION_HOURS must not be negative")
+	check(cfg.TrashPurgeIntervalHours > 0, "TRASH_PURGE_INTERVAL_HOURS must be positive")
+	check(cfg.ExpirySweepSeconds > 0, "EXPIRY_SWEEP_SECONDS must be positive")
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	switch cfg.TracingExporter {
+	case "otlp", "stdout", "file", "none":
+	default:
+		check(false, "TRACING_EXPORTER must be otlp, stdout, file, or none")
 	}
-	return defaultValue
-}
+	check(cfg.TracingOTLPProtocol == "http" || cfg.TracingOTLPProtocol == "grpc", "TRACING_OTLP_PROTOCOL must be http or grpc")
+	check(cfg.TracingSampleRatio >= 0 && cfg.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1")
 
-// getEnvAsInt gets an environment variable as an integer or returns a default value
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
-	}
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
+	switch cfg.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "LOG_LEVEL must be debug, info, warn, or error")
 	}
-	return value
+	check(cfg.LogFormat == "json" || cfg.LogFormat == "text", "LOG_FORMAT must be json or text")
+	check(cfg.LogSlowQueryMS >= 0, "LOG_SLOW_QUERY_MS must not be negative")
+	check(cfg.RequestReadTimeoutMS >= 0, "REQUEST_READ_TIMEOUT_MS must not be negative")
+	check(cfg.RequestWriteTimeoutMS >= 0, "REQUEST_WRITE_TIMEOUT_MS must not be negative")
+	check(cfg.ShutdownDrainSeconds >= 0, "SHUTDOWN_DRAIN_SECONDS must not be negative")
+	check(cfg.HealthTimeoutMS > 0, "HEALTH_TIMEOUT_MS must be positive")
+	check(cfg.HealthCacheMS >= 0, "HEALTH_CACHE_MS must not be negative")
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,73 @@
+// print.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package config
+
+import (
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret values in printed configuration
+const Redacted = "[REDACTED]"
+
+// Print writes the effective configuration as YAML config file keys, secrets replaced when redact is set
+func (cfg *Config) Print(w io.Writer, redact bool) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	values := reflect.ValueOf(cfg).Elem()
+
+	for _, s := range settings {
+		value := values.Field(s.index).Interface()
+		if redact && s.secret {
+			value = redactValue(value)
+		}
+
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return err
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s.key}, &node)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// redactValue replaces a set secret, or each entry of a secret list
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return Redacted
+		}
+	case []string:
+		redacted := make([]string, len(v))
+		for i := range v {
+			redacted[i] = Redacted
+		}
+		return redacted
+	}
+	return value
+}
@@ -0,0 +1,298 @@
+// sources.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// setting describes a Config field and the names it is set by in each layer
+type setting struct {
+	index  int    // Config field index
+	env    string // environment variable, e.g. DB_APP_PASSWORD
+	key    string // config file key, e.g. db_app_password
+	flag   string // flag name, e.g. db-app-password
+	def    string // default value
+	secret bool   // redacted when printed, also read from a file
+}
+
+// settings describes every Config field, in declaration order
+var settings = describeSettings()
+
+// describeSettings reads the setting names from the Config struct tags
+func describeSettings() []setting {
+	configType := reflect.TypeOf(Config{})
+	list := make([]setting, 0, configType.NumField())
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		env := field.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		key := strings.ToLower(env)
+		list = append(list, setting{
+			index:  i,
+			env:    env,
+			key:    key,
+			flag:   strings.ReplaceAll(key, "_", "-"),
+			def:    field.Tag.Get("default"),
+			secret: field.Tag.Get("secret") == "true",
+		})
+	}
+	return list
+}
+
+// set parses raw into the setting's field, naming source in errors. Secret values are not echoed.
+func (s setting) set(cfg *Config, raw, source string) error {
+	field := reflect.ValueOf(cfg).Elem().Field(s.index)
+	value := strings.TrimSpace(raw)
+	shown := strconv.Quote(raw)
+	if s.secret {
+		shown = "value"
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %s", source, shown)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %s", source, shown)
+		}
+		field.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %s", source, shown)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(splitList(value)))
+	}
+	return nil
+}
+
+// setList sets a list setting from the elements of a file list value
+func (s setting) setList(cfg *Config, values []interface{}, source string) error {
+	field := reflect.ValueOf(cfg).Elem().Field(s.index)
+	if field.Kind() != reflect.Slice {
+		return fmt.Errorf("%s: a list is not allowed", source)
+	}
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: list entries must be strings", source)
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			list = append(list, text)
+		}
+	}
+	field.Set(reflect.ValueOf(list))
+	return nil
+}
+
+// readSecret returns the contents of a secret file, without the trailing newline
+func readSecret(path, source string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", source, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// applyDefaults sets the default of every setting that has one
+func applyDefaults(cfg *Config) error {
+	var errs []error
+	for _, s := range settings {
+		if s.def != "" {
+			errs = append(errs, s.set(cfg, s.def, s.env+" default"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyFile sets the settings in a flat YAML or TOML file, keyed by lowercase setting name.
+// A secret can be given as <key>_file, naming the file holding it.
+func applyFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml, or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	var errs []error
+	for key, value := range values {
+		source := fmt.Sprintf("%s in %s", key, path)
+		s, ok := byKey[key]
+		fromFile := false
+		if !ok {
+			s, ok = byKey[strings.TrimSuffix(key, "_file")]
+			fromFile = ok && s.secret && strings.HasSuffix(key, "_file")
+			if !fromFile {
+				errs = append(errs, fmt.Errorf("%s: unknown setting", source))
+				continue
+			}
+			if _, both := values[s.key]; both {
+				errs = append(errs, fmt.Errorf("%s: %s is also set", source, s.key))
+				continue
+			}
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case []interface{}:
+			errs = append(errs, s.setList(cfg, v, source))
+		case map[string]interface{}:
+			errs = append(errs, fmt.Errorf("%s: nested values are not allowed", source))
+		default:
+			raw := fmt.Sprint(v)
+			if fromFile {
+				if raw, err = readSecret(raw, source); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			errs = append(errs, s.set(cfg, raw, source))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnv sets the settings given by environment variables, empty ones are ignored.
+// A secret can be given as <env>_FILE, naming the file holding it, e.g. a Docker secret.
+func applyEnv(cfg *Config) error {
+	var errs []error
+	for _, s := range settings {
+		value := os.Getenv(s.env)
+		if s.secret {
+			if path := os.Getenv(s.env + "_FILE"); path != "" {
+				if value != "" {
+					errs = append(errs, fmt.Errorf("%s and %s_FILE are both set", s.env, s.env))
+					continue
+				}
+				secret, err := readSecret(path, s.env+"_FILE")
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				errs = append(errs, s.set(cfg, secret, s.env+"_FILE"))
+				continue
+			}
+		}
+		if value != "" {
+			errs = append(errs, s.set(cfg, value, s.env))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseFlags parses --config and a flag per setting from args, returning the flags given and the config file
+func parseFlags(args []string) (*flag.FlagSet, string, error) {
+	flags := flag.NewFlagSet("propsdb", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file, overrides CONFIG_FILE")
+	for _, s := range settings {
+		flags.String(s.flag, "", "overrides "+s.env)
+		if s.secret {
+			flags.String(s.flag+"-file", "", "file holding "+s.env)
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if flags.NArg() > 0 {
+		return nil, "", fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+	return flags, *configFile, nil
+}
+
+// applyFlags sets the settings given as flags
+func applyFlags(cfg *Config, flags *flag.FlagSet) error {
+	given := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	var errs []error
+	for _, s := range settings {
+		value, ok := given[s.flag]
+		if path, fromFile := given[s.flag+"-file"]; fromFile {
+			if ok {
+				errs = append(errs, fmt.Errorf("--%s and --%s-file are both set", s.flag, s.flag))
+				continue
+			}
+			secret, err := readSecret(path, "--"+s.flag+"-file")
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			value, ok = secret, true
+		}
+		if ok {
+			errs = append(errs, s.set(cfg, value, "--"+s.flag))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma-separated value, empty entries removed
+func splitList(value string) []string {
+	var values []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+	return values
+}
@@ -0,0 +1,189 @@
+// breaker.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// ErrUnavailable is returned without reaching the database while a pool's circuit breaker is open
+var ErrUnavailable = errors.New("database unavailable")
+
+// breakerProbeKey marks the statement probing a pool after the breaker's cooldown
+const breakerProbeKey = "propsdb:breaker_probe"
+
+// Breaker is a circuit breaker for a pool. It opens after threshold consecutive connection failures, failing
+// statements fast with ErrUnavailable. After the cooldown one statement probes the database, closing the
+// breaker if it reaches it or opening it for another cooldown if not.
+type Breaker struct {
+	pool      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time // zero while closed
+	probing   bool
+}
+
+// NewBreaker creates a closed circuit breaker for a pool
+func NewBreaker(pool string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{pool: pool, threshold: threshold, cooldown: cooldown}
+}
+
+// Open reports whether the breaker fails statements fast, false once a probe is due
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.openUntil.IsZero() && (b.probing || time.Now().Before(b.openUntil))
+}
+
+// allow reports whether a statement may run, and whether it is the probe of an open breaker
+func (b *Breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return true, false
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// record records the outcome of a statement that ran
+func (b *Breaker) record(failed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if !failed {
+		if !b.openUntil.IsZero() {
+			slog.Info("Database circuit breaker closed", "pool", b.pool)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	if probe || (b.openUntil.IsZero() && b.failures >= b.threshold) {
+		b.openUntil = time.Now().Add(b.cooldown)
+		slog.Warn("Database circuit breaker open", "pool", b.pool, "failures", b.failures, "cooldown_ms", b.cooldown.Milliseconds())
+	}
+}
+
+// Name identifies the plugin to GORM
+func (b *Breaker) Name() string {
+	return "propsdb:breaker"
+}
+
+// Initialize registers the breaker callbacks around each kind of statement
+func (b *Breaker) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("propsdb:breaker_before_create", b.before),
+		cb.Create().After("gorm:create").Register("propsdb:breaker_after_create", b.after),
+		cb.Query().Before("gorm:query").Register("propsdb:breaker_before_query", b.before),
+		cb.Query().After("gorm:query").Register("propsdb:breaker_after_query", b.after),
+		cb.Update().Before("gorm:update").Register("propsdb:breaker_before_update", b.before),
+		cb.Update().After("gorm:update").Register("propsdb:breaker_after_update", b.after),
+		cb.Delete().Before("gorm:delete").Register("propsdb:breaker_before_delete", b.before),
+		cb.Delete().After("gorm:delete").Register("propsdb:breaker_after_delete", b.after),
+		cb.Row().Before("gorm:row").Register("propsdb:breaker_before_row", b.before),
+		cb.Row().After("gorm:row").Register("propsdb:breaker_after_row", b.after),
+		cb.Raw().Before("gorm:raw").Register("propsdb:breaker_before_raw", b.before),
+		cb.Raw().After("gorm:raw").Register("propsdb:breaker_after_raw", b.after),
+	)
+}
+
+// before fails a statement with ErrUnavailable while the breaker is open, so GORM does not run it
+func (b *Breaker) before(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	ok, probe := b.allow()
+	if !ok {
+		tx.AddError(ErrUnavailable)
+		return
+	}
+	tx.InstanceSet(breakerProbeKey, probe)
+}
+
+// after records whether a statement that ran reached the database
+func (b *Breaker) after(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(breakerProbeKey)
+	if !ok {
+		return
+	}
+	probe, _ := value.(bool)
+
+	// A statement stopped by its caller's deadline or cancellation says nothing about the database
+	if tx.Error != nil && tx.Statement.Context.Err() != nil {
+		b.abandon(probe)
+		return
+	}
+	b.record(IsConnectionError(tx.Error), probe)
+}
+
+// abandon records a statement without an outcome, letting another statement probe
+func (b *Breaker) abandon(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// IsConnectionError reports whether err is a driver, network or bad connection error.
+// Context deadlines and cancellations are not, although they satisfy net.Error.
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysqldriver.ErrInvalidConn)
+}
+
+// Available returns ErrUnavailable while the circuit breaker of db's pool is open, nil for pools without one
+func Available(db *gorm.DB) error {
+	if b, ok := db.Config.Plugins["propsdb:breaker"].(*Breaker); ok && b.Open() {
+		return ErrUnavailable
+	}
+	return nil
+}
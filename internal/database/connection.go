@@ -21,11 +21,16 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/glebarez/sqlite"
 	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/logging"
 	"github.com/localnerve/jam-build-propsdb/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlserver"
@@ -33,146 +38,189 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-// Connect establishes a database connection based on the configured DB_TYPE
+// Connect establishes the app database connection based on the configured DB_TYPE
 func Connect(cfg *config.Config) (*gorm.DB, error) {
-	var dialector gorm.Dialector
-
-	switch cfg.DBType {
-	case "mysql", "mariadb":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.DBAppUser,
-			cfg.DBAppPassword,
-			cfg.DBHost,
-			cfg.DBPort,
-			cfg.DBAppDatabase,
-		)
-		dialector = mysql.Open(dsn)
-
-	case "postgres", "postgresql":
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-			cfg.DBHost,
-			cfg.DBAppUser,
-			cfg.DBAppPassword,
-			cfg.DBAppDatabase,
-			cfg.DBPort,
-		)
-		dialector = postgres.Open(dsn)
+	return connect(cfg, PoolApp, cfg.DBAppConnectionLimit)
+}
 
-	case "sqlite":
-		// For SQLite, DBAppDatabase is the file path
-		dialector = sqlite.Open(cfg.DBAppDatabase)
+// ConnectUser establishes a user database connection (with different credentials)
+func ConnectUser(cfg *config.Config) (*gorm.DB, error) {
+	return connect(cfg, PoolUser, cfg.DBConnectionLimit)
+}
 
-	case "sqlserver", "mssql":
-		dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s&trust+server+certificate=true",
-			cfg.DBAppUser,
-			cfg.DBAppPassword,
-			cfg.DBHost,
-			cfg.DBPort,
-			cfg.DBAppDatabase,
-		)
-		dialector = sqlserver.Open(dsn)
+// connect opens the pool's DSN with up to connectionLimit connections
+func connect(cfg *config.Config, pool string, connectionLimit int) (*gorm.DB, error) {
+	dsn, err := DSN(cfg, pool)
+	if err != nil {
+		return nil, err
+	}
+	if isMySQL(cfg.DBType) && cfg.DBTLSMode != "disable" && !hasDSN(cfg, pool) {
+		if err := registerTLS(cfg, pool); err != nil {
+			return nil, err
+		}
+	}
 
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", cfg.DBType)
+	dialector, err := openDialector(cfg.DBType, dsn)
+	if err != nil {
+		return nil, err
 	}
 
 	db, err := gorm.Open(dialector, &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: newLogger(cfg),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to %s database: %w", pool, err)
+	}
+	if err := db.Use(Tracing(cfg.DBType, pool)); err != nil {
+		return nil, fmt.Errorf("failed to install tracing: %w", err)
+	}
+	if cfg.DBBreakerThreshold > 0 {
+		breaker := NewBreaker(pool, cfg.DBBreakerThreshold, time.Duration(cfg.DBBreakerCooldownSeconds)*time.Second)
+		if err := db.Use(breaker); err != nil {
+			return nil, fmt.Errorf("failed to install circuit breaker: %w", err)
+		}
 	}
 
-	// Get underlying SQL DB for connection pool configuration
+	if err := configurePool(cfg, db, connectionLimit); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Connected to database", "type", cfg.DBType, "database", cfg.DBAppDatabase, "pool", pool)
+
+	return db, nil
+}
+
+// configurePool sets the connection limit, half of it idle, and the connection lifetimes of a pool
+func configurePool(cfg *config.Config, db *gorm.DB, connectionLimit int) error {
 	sqlDB, err := db.DB()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying SQL DB: %w", err)
+		return fmt.Errorf("failed to get underlying SQL DB: %w", err)
 	}
 
-	// Set connection pool settings
-	sqlDB.SetMaxOpenConns(cfg.DBAppConnectionLimit)
-	sqlDB.SetMaxIdleConns(cfg.DBAppConnectionLimit / 2)
-
-	log.Printf("Connected to %s database: %s", cfg.DBType, cfg.DBAppDatabase)
+	sqlDB.SetMaxOpenConns(connectionLimit)
+	sqlDB.SetMaxIdleConns(connectionLimit / 2)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DBConnMaxLifetimeSeconds) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.DBConnMaxIdleSeconds) * time.Second)
 
-	return db, nil
+	return nil
 }
 
-// ConnectUser establishes a user database connection (with different credentials)
-func ConnectUser(cfg *config.Config) (*gorm.DB, error) {
-	var dialector gorm.Dialector
+// ConnectWithRetry calls connect until it succeeds or DB_CONNECT_RETRY_SECONDS have passed,
+// backing off exponentially between attempts, so the service can start before its database
+func ConnectWithRetry(cfg *config.Config, connect func(*config.Config) (*gorm.DB, error)) (*gorm.DB, error) {
+	deadline := time.Now().Add(time.Duration(cfg.DBConnectRetrySeconds) * time.Second)
+	backoff := 250 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		db, err := connect(cfg)
+		if err == nil {
+			return db, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, err
+		}
+
+		slog.Warn("Database not reachable, retrying", "attempt", attempt, "retry_in_ms", backoff.Milliseconds(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
 
-	switch cfg.DBType {
+// openDialector returns the GORM dialector of DB_TYPE for a DSN in the driver's format
+func openDialector(dbType, dsn string) (gorm.Dialector, error) {
+	switch dbType {
 	case "mysql", "mariadb":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.DBUser,
-			cfg.DBPassword,
-			cfg.DBHost,
-			cfg.DBPort,
-			cfg.DBAppDatabase,
-		)
-		dialector = mysql.Open(dsn)
-
+		return mysql.Open(dsn), nil
 	case "postgres", "postgresql":
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-			cfg.DBHost,
-			cfg.DBUser,
-			cfg.DBPassword,
-			cfg.DBAppDatabase,
-			cfg.DBPort,
-		)
-		dialector = postgres.Open(dsn)
-
+		return postgres.Open(dsn), nil
 	case "sqlite":
-		// For SQLite, use the same connection (no separate user credentials)
-		dialector = sqlite.Open(cfg.DBAppDatabase)
-
+		return sqlite.Open(dsn), nil
 	case "sqlserver", "mssql":
-		dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s&trust+server+certificate=true",
-			cfg.DBUser,
-			cfg.DBPassword,
-			cfg.DBHost,
-			cfg.DBPort,
-			cfg.DBAppDatabase,
-		)
-		dialector = sqlserver.Open(dsn)
-
+		return sqlserver.Open(dsn), nil
 	default:
-		return nil, fmt.Errorf("unsupported database type: %s", cfg.DBType)
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
+}
 
-	db, err := gorm.Open(dialector, &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to user database: %w", err)
+// isMySQL reports whether DB_TYPE uses the MySQL driver
+func isMySQL(dbType string) bool {
+	return dbType == "mysql" || dbType == "mariadb"
+}
+
+// hasDSN reports whether the pool's DSN is configured rather than built
+func hasDSN(cfg *config.Config, pool string) bool {
+	if pool == PoolUser {
+		return cfg.DBUserDSN != ""
 	}
+	return cfg.DBDSN != ""
+}
 
-	// Get underlying SQL DB for connection pool configuration
+// newLogger creates the GORM logger of a pool from LOG_LEVEL and LOG_SLOW_QUERY_MS
+func newLogger(cfg *config.Config) logger.Interface {
+	return logging.NewGorm(logging.Level(cfg.LogLevel), time.Duration(cfg.LogSlowQueryMS)*time.Millisecond)
+}
+
+// RegisterPoolMetrics exports the sql.DBStats of a pool as go_sql_* Prometheus metrics labeled db_name=pool
+func RegisterPoolMetrics(db *gorm.DB, pool string) error {
 	sqlDB, err := db.DB()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying SQL DB: %w", err)
+		return err
 	}
-
-	// Set connection pool settings
-	sqlDB.SetMaxOpenConns(cfg.DBConnectionLimit)
-	sqlDB.SetMaxIdleConns(cfg.DBConnectionLimit / 2)
-
-	log.Printf("Connected to %s user database: %s", cfg.DBType, cfg.DBAppDatabase)
-
-	return db, nil
+	return prometheus.Register(collectors.NewDBStatsCollector(sqlDB, pool))
 }
 
 // AutoMigrate runs automatic migrations for all models
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	return db.AutoMigrate(migratedModels()...)
+}
+
+// migratedModels lists the models AutoMigrate creates tables for
+func migratedModels() []interface{} {
+	return []interface{}{
 		&models.ApplicationDocument{},
 		&models.ApplicationCollection{},
 		&models.ApplicationProperty{},
 		&models.UserDocument{},
 		&models.UserCollection{},
 		&models.UserProperty{},
-	)
+		&models.ApplicationTrash{},
+		&models.UserTrash{},
+	}
+}
+
+// PendingMigrations returns the tables and columns AutoMigrate would still create, "table" or "table.column"
+func PendingMigrations(db *gorm.DB) ([]string, error) {
+	var pending []string
+	migrator := db.Migrator()
+
+	for _, model := range migratedModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model: %w", err)
+		}
+		table := stmt.Schema.Table
+
+		if !migrator.HasTable(model) {
+			pending = append(pending, table)
+			continue
+		}
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+
+		columns := make(map[string]bool, len(columnTypes))
+		for _, columnType := range columnTypes {
+			columns[strings.ToLower(columnType.Name())] = true
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !columns[strings.ToLower(field.DBName)] {
+				pending = append(pending, table+"."+field.DBName)
+			}
+		}
+	}
+
+	return pending, nil
 }
 
 // Close closes the database connection
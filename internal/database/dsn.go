@@ -0,0 +1,234 @@
+// dsn.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+)
+
+// Pools connected by the service, each with its own credentials
+const (
+	PoolApp  = "app"
+	PoolUser = "user"
+)
+
+// DSN returns the DSN of a pool: DB_DSN or DB_USER_DSN as is when set, otherwise one built for DB_TYPE from
+// the host, port, database, time zone and TLS settings and the pool's credentials, with every value escaped
+func DSN(cfg *config.Config, pool string) (string, error) {
+	user, password, override := cfg.DBAppUser, cfg.DBAppPassword, cfg.DBDSN
+	if pool == PoolUser {
+		user, password, override = cfg.DBUser, cfg.DBPassword, cfg.DBUserDSN
+	}
+	if override != "" {
+		return override, nil
+	}
+
+	switch cfg.DBType {
+	case "mysql", "mariadb":
+		return mysqlDSN(cfg, pool, user, password)
+	case "postgres", "postgresql":
+		return postgresDSN(cfg, user, password), nil
+	case "sqlite":
+		// For SQLite, DBAppDatabase is the file path shared by both pools
+		return cfg.DBAppDatabase, nil
+	case "sqlserver", "mssql":
+		return sqlserverDSN(cfg, user, password), nil
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", cfg.DBType)
+	}
+}
+
+// mysqlDSN builds a go-sql-driver DSN, referring to the TLS config registerTLS registers for the pool
+func mysqlDSN(cfg *config.Config, pool, user, password string) (string, error) {
+	dsn := mysqldriver.NewConfig()
+	dsn.User = user
+	dsn.Passwd = password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(cfg.DBHost, cfg.DBPort)
+	dsn.DBName = cfg.DBAppDatabase
+	dsn.ParseTime = true
+	if err := dsn.Apply(mysqldriver.Charset("utf8mb4", "")); err != nil {
+		return "", err
+	}
+
+	timezone := cfg.DBTimezone
+	if timezone == "" {
+		timezone = "Local"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", fmt.Errorf("invalid DB_TIMEZONE: %w", err)
+	}
+	dsn.Loc = loc
+
+	if cfg.DBTLSMode != "disable" {
+		dsn.TLSConfig = tlsConfigName(pool)
+	}
+
+	return dsn.FormatDSN(), nil
+}
+
+// postgresDSN builds a pgx connection URL, which takes the TLS settings as libpq parameters
+func postgresDSN(cfg *config.Config, user, password string) string {
+	timezone := cfg.DBTimezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", cfg.DBTLSMode)
+	query.Set("TimeZone", timezone)
+	if cfg.DBTLSCA != "" {
+		query.Set("sslrootcert", cfg.DBTLSCA)
+	}
+	if cfg.DBTLSCert != "" {
+		query.Set("sslcert", cfg.DBTLSCert)
+		query.Set("sslkey", cfg.DBTLSKey)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(cfg.DBHost, cfg.DBPort),
+		Path:     "/" + cfg.DBAppDatabase,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
+}
+
+// sqlserverDSN builds a go-mssqldb connection URL. The driver checks the host name whenever it verifies the
+// server certificate, so verify-ca behaves as verify-full, and it does not support client certificates.
+func sqlserverDSN(cfg *config.Config, user, password string) string {
+	query := url.Values{}
+	query.Set("database", cfg.DBAppDatabase)
+
+	switch cfg.DBTLSMode {
+	case "disable":
+		// Only the login is encrypted
+		query.Set("trustservercertificate", "true")
+	case "require":
+		query.Set("encrypt", "true")
+		query.Set("trustservercertificate", "true")
+	default:
+		query.Set("encrypt", "true")
+		query.Set("trustservercertificate", "false")
+		if cfg.DBTLSCA != "" {
+			query.Set("certificate", cfg.DBTLSCA)
+		}
+	}
+
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(cfg.DBHost, cfg.DBPort),
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
+}
+
+// tlsConfigName names the go-sql-driver TLS config of a pool
+func tlsConfigName(pool string) string {
+	return "propsdb-" + pool
+}
+
+// registerTLS registers the TLS config a built mysql DSN of the pool refers to
+func registerTLS(cfg *config.Config, pool string) error {
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return mysqldriver.RegisterTLSConfig(tlsConfigName(pool), tlsConfig)
+}
+
+// TLSConfig returns the tls.Config of DB_TLS_MODE for DB_HOST, with the DB_TLS_CA roots and DB_TLS_CERT client certificate.
+// require encrypts without verifying the server, verify-ca verifies its certificate chain but not its host name.
+func TLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.DBHost,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.DBTLSCA != "" {
+		pem, err := os.ReadFile(cfg.DBTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DB_TLS_CA: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in DB_TLS_CA %s", cfg.DBTLSCA)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if cfg.DBTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.DBTLSCert, cfg.DBTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load DB_TLS_CERT: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch cfg.DBTLSMode {
+	case "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Skip the default verification, which includes the host name, and verify the chain only
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChain(tlsConfig.RootCAs)
+	}
+
+	return tlsConfig, nil
+}
+
+// verifyChain verifies a peer certificate chain against roots, the system roots when nil, ignoring the host name
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		var leaf *x509.Certificate
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("invalid server certificate: %w", err)
+			}
+			if i == 0 {
+				leaf = cert
+			} else {
+				opts.Intermediates.AddCert(cert)
+			}
+		}
+
+		_, err := leaf.Verify(opts)
+		return err
+	}
+}
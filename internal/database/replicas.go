@@ -0,0 +1,273 @@
+// replicas.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"gorm.io/gorm"
+)
+
+// ReplicaSet routes reads for a pool to healthy read replicas, and everything else to the primary.
+// Replicas that fail a health check are ejected until they pass one again.
+// After a write, reads with the same key go to the primary for the sticky window (read-your-writes).
+type ReplicaSet struct {
+	primary   *gorm.DB
+	replicas  []*replica
+	next      atomic.Uint64
+	stickyFor time.Duration
+
+	mu     sync.Mutex
+	writes map[string]time.Time
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// replica is a read replica pool and its last health check result
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+	lastErr atomic.Value // string
+}
+
+// ReplicaStatus reports the health of one replica
+type ReplicaStatus struct {
+	Name    string
+	Healthy bool
+	Error   string
+}
+
+// NewReplicaSet creates a ReplicaSet over already opened replica pools, all initially healthy.
+// pool names the replicas in logs and health results, e.g. "app" gives "app-replica-0".
+func NewReplicaSet(pool string, primary *gorm.DB, replicas []*gorm.DB, stickyFor time.Duration) *ReplicaSet {
+	set := &ReplicaSet{
+		primary:   primary,
+		stickyFor: stickyFor,
+		writes:    make(map[string]time.Time),
+	}
+
+	for i, db := range replicas {
+		r := &replica{name: fmt.Sprintf("%s-replica-%d", pool, i), db: db}
+		r.healthy.Store(true)
+		r.lastErr.Store("")
+		set.replicas = append(set.replicas, r)
+	}
+
+	return set
+}
+
+// ConnectReplicas opens a pool for each replica DSN of the configured DB_TYPE, and checks them once.
+// An unreachable replica starts ejected, and a DSN that cannot be opened is logged and left out.
+func ConnectReplicas(cfg *config.Config, pool string, primary *gorm.DB, dsns []string, connectionLimit int) *ReplicaSet {
+	var replicas []*gorm.DB
+	for i, dsn := range dsns {
+		db, err := openReplica(cfg, dsn, connectionLimit)
+		if err != nil {
+			slog.Error("Failed to connect to read replica", "replica", fmt.Sprintf("%s-replica-%d", pool, i), "error", err)
+			continue
+		}
+		replicas = append(replicas, db)
+	}
+
+	if len(replicas) > 0 {
+		slog.Info("Connected to read replicas", "pool", pool, "count", len(replicas))
+	}
+
+	set := NewReplicaSet(pool, primary, replicas, time.Duration(cfg.DBReplicaStickySeconds)*time.Second)
+	set.Check()
+
+	return set
+}
+
+// openReplica opens a pool for a replica DSN in the driver's own DSN format
+func openReplica(cfg *config.Config, dsn string, connectionLimit int) (*gorm.DB, error) {
+	dialector, err := openDialector(cfg.DBType, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the connect time ping, health checks decide whether the replica is used
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:               newLogger(cfg),
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(Tracing(cfg.DBType, "replica")); err != nil {
+		return nil, err
+	}
+
+	if err := configurePool(cfg, db, connectionLimit); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Primary returns the primary pool
+func (s *ReplicaSet) Primary() *gorm.DB {
+	return s.primary
+}
+
+// Reader returns the pool to read with for key, the primary if key wrote recently or no replica is healthy
+func (s *ReplicaSet) Reader(key string) *gorm.DB {
+	if len(s.replicas) == 0 || s.wroteRecently(key) {
+		return s.primary
+	}
+
+	// Round robin, skipping ejected replicas
+	start := s.next.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(start+uint64(i))%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return s.primary
+}
+
+// MarkWrite records a committed write for key, so its reads go to the primary for the sticky window
+func (s *ReplicaSet) MarkWrite(key string) {
+	if len(s.replicas) == 0 || s.stickyFor <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes[key] = time.Now().Add(s.stickyFor)
+}
+
+// wroteRecently reports whether key is inside its sticky window
+func (s *ReplicaSet) wroteRecently(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.writes[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(s.writes, key)
+		return false
+	}
+	return true
+}
+
+// Check pings every replica, ejecting those that fail and restoring those that recover
+func (s *ReplicaSet) Check() {
+	for _, r := range s.replicas {
+		err := ping(r.db)
+		wasHealthy := r.healthy.Swap(err == nil)
+
+		if err != nil {
+			r.lastErr.Store(err.Error())
+			if wasHealthy {
+				slog.Warn("Ejecting read replica", "replica", r.name, "error", err)
+			}
+		} else {
+			r.lastErr.Store("")
+			if !wasHealthy {
+				slog.Info("Restoring read replica", "replica", r.name)
+			}
+		}
+	}
+
+	// Forget expired sticky windows
+	s.mu.Lock()
+	now := time.Now()
+	for key, until := range s.writes {
+		if now.After(until) {
+			delete(s.writes, key)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// ping checks a pool's connectivity
+func ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// Status returns the health of each replica from the last check
+func (s *ReplicaSet) Status() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		statuses = append(statuses, ReplicaStatus{
+			Name:    r.name,
+			Healthy: r.healthy.Load(),
+			Error:   r.lastErr.Load().(string),
+		})
+	}
+	return statuses
+}
+
+// StartHealthChecks checks the replicas every interval until Close
+func (s *ReplicaSet) StartHealthChecks(interval time.Duration) {
+	if len(s.replicas) == 0 || interval <= 0 || s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Check()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops health checks and closes the replica pools, the primary is left open
+func (s *ReplicaSet) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		s.done.Wait()
+		s.stop = nil
+	}
+
+	var firstErr error
+	for _, r := range s.replicas {
+		if err := Close(r.db); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
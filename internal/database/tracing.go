@@ -0,0 +1,130 @@
+// tracing.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package database
+
+import (
+	"errors"
+
+	"github.com/localnerve/jam-build-propsdb/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// tracingSpanKey holds a statement's span between its before and after callbacks
+const tracingSpanKey = "propsdb:tracing_span"
+
+// tracingPlugin is a GORM plugin recording a client span for each statement
+type tracingPlugin struct {
+	system attribute.KeyValue
+	pool   string
+}
+
+// Tracing returns a GORM plugin recording a span for each statement, a child of the statement context's span.
+// Spans carry the SQL with placeholders, never the values.
+func Tracing(dbType, pool string) gorm.Plugin {
+	return tracingPlugin{system: dbSystem(dbType), pool: pool}
+}
+
+// Name identifies the plugin to GORM
+func (p tracingPlugin) Name() string {
+	return "propsdb:tracing"
+}
+
+// Initialize registers the span callbacks around each kind of statement
+func (p tracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("propsdb:tracing_before_create", p.before("create")),
+		cb.Create().After("gorm:create").Register("propsdb:tracing_after_create", p.after("create")),
+		cb.Query().Before("gorm:query").Register("propsdb:tracing_before_query", p.before("select")),
+		cb.Query().After("gorm:query").Register("propsdb:tracing_after_query", p.after("select")),
+		cb.Update().Before("gorm:update").Register("propsdb:tracing_before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("propsdb:tracing_after_update", p.after("update")),
+		cb.Delete().Before("gorm:delete").Register("propsdb:tracing_before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("propsdb:tracing_after_delete", p.after("delete")),
+		cb.Row().Before("gorm:row").Register("propsdb:tracing_before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("propsdb:tracing_after_row", p.after("row")),
+		cb.Raw().Before("gorm:raw").Register("propsdb:tracing_before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("propsdb:tracing_after_raw", p.after("raw")),
+	)
+}
+
+// before starts the span of a statement
+func (p tracingPlugin) before(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		_, span := telemetry.Tracer().Start(tx.Statement.Context, operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				p.system,
+				semconv.DBOperationName(operation),
+				attribute.String("propsdb.db.pool", p.pool),
+			),
+		)
+		tx.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+// after ends the span of a statement with its SQL, table and outcome
+func (p tracingPlugin) after(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(tracingSpanKey)
+		if !ok {
+			return
+		}
+		span, ok := value.(trace.Span)
+		if !ok {
+			return
+		}
+		defer span.End()
+
+		if table := tx.Statement.Table; table != "" {
+			span.SetName(operation + " " + table)
+			span.SetAttributes(semconv.DBCollectionName(table))
+		}
+		span.SetAttributes(
+			semconv.DBQueryText(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+
+		// A missing record is an answer, not a failure
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+}
+
+// dbSystem maps DB_TYPE to the db.system.name attribute
+func dbSystem(dbType string) attribute.KeyValue {
+	switch dbType {
+	case "mariadb":
+		return semconv.DBSystemNameMariaDB
+	case "postgres", "postgresql":
+		return semconv.DBSystemNamePostgreSQL
+	case "sqlite":
+		return semconv.DBSystemNameSQLite
+	case "sqlserver", "mssql":
+		return semconv.DBSystemNameMicrosoftSQLServer
+	}
+	return semconv.DBSystemNameMySQL
+}
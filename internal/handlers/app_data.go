@@ -20,10 +20,12 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
 	"github.com/localnerve/jam-build-propsdb/internal/services"
 	"github.com/localnerve/jam-build-propsdb/internal/types"
 	"github.com/localnerve/jam-build-propsdb/internal/utils"
@@ -32,7 +34,41 @@ import (
 
 // AppDataHandler handles application data routes
 type AppDataHandler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Replicas *database.ReplicaSet // Optional, GETs read from replicas when set
+	Store    services.Store       // Optional, replaces DB and Replicas when set
+	Timeout  time.Duration        // Optional, bounds each store call
+}
+
+// reader returns the Store for GETs
+func (h *AppDataHandler) reader(c *fiber.Ctx) services.Store {
+	if h.Store != nil {
+		return h.Store
+	}
+	return services.GormStore{DB: readerFor(h.DB, h.Replicas, ""), Timeout: h.Timeout}
+}
+
+// writer returns the Store for mutations
+func (h *AppDataHandler) writer(c *fiber.Ctx) services.Store {
+	if h.Store != nil {
+		return h.Store
+	}
+	return services.GormStore{DB: h.DB, Timeout: h.Timeout}
+}
+
+// fetchDocument returns a reader of a document from the primary, for conflict details
+func (h *AppDataHandler) fetchDocument(c *fiber.Ctx, document string) func(services.ReadOptions) (services.DocumentResult, error) {
+	return func(opts services.ReadOptions) (services.DocumentResult, error) {
+		return h.writer(c).GetApplicationCollectionsAndProperties(c.UserContext(), document, nil, opts)
+	}
+}
+
+// publicReadOptions are the read options of the public GET routes, whose metadata
+// leaves out the admin ids since the responses are anonymous and publicly cached
+func publicReadOptions(c *fiber.Ctx) services.ReadOptions {
+	opts := parseReadOptions(c)
+	opts.OmitActors = true
+	return opts
 }
 
 // GetAppProperties handles GET /api/data/app/:document/:collection
@@ -43,20 +79,23 @@ type AppDataHandler struct {
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param collection path string true "Collection ID"
+// @Param fields query string false "Comma-separated list of properties, or property.json.path selections, to return"
+// @Param include query string false "Set to meta to return property metadata (version, createdAt, updatedAt) under __meta"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Strong entity tag, prefixed with the document version on single document routes"
+// @Success 304 {string} string "Not Modified"
 // @Failure 404 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/app/{document}/{collection} [get]
 func (h *AppDataHandler) GetAppProperties(c *fiber.Ctx) error {
 	document := c.Params("document")
 	collection := c.Params("collection")
 
-	result, err := services.GetApplicationProperties(h.DB, document, collection)
+	result, err := h.reader(c).GetApplicationProperties(c.UserContext(), document, collection, publicReadOptions(c))
 	if err != nil {
-		if err.Error() == "not found" {
-			return utils.NotFoundResponse(c, fmt.Sprintf("Document '%s' or collection '%s' not found", document, collection))
-		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "getAppProperties")
+		return serviceErrorResponse(c, err, "getAppProperties", fmt.Sprintf("Document '%s' or collection '%s' not found", document, collection))
 	}
 
 	if len(result) == 0 {
@@ -67,7 +106,7 @@ func (h *AppDataHandler) GetAppProperties(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return sendConditionalJSON(c, result, document)
 }
 
 // GetAppCollectionsAndProperties handles GET /api/data/app/:document?collections=...
@@ -78,20 +117,23 @@ func (h *AppDataHandler) GetAppProperties(c *fiber.Ctx) error {
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param collections query string false "Comma-separated list of collections to filter"
+// @Param fields query string false "Comma-separated list of properties, or property.json.path selections, to return"
+// @Param include query string false "Set to meta to return property metadata (version, createdAt, updatedAt) under __meta"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Strong entity tag, prefixed with the document version on single document routes"
+// @Success 304 {string} string "Not Modified"
 // @Failure 404 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/app/{document} [get]
 func (h *AppDataHandler) GetAppCollectionsAndProperties(c *fiber.Ctx) error {
 	document := c.Params("document")
 	collections := parseCollections(c)
 
-	result, err := services.GetApplicationCollectionsAndProperties(h.DB, document, collections)
+	result, err := h.reader(c).GetApplicationCollectionsAndProperties(c.UserContext(), document, collections, publicReadOptions(c))
 	if err != nil {
-		if err.Error() == "not found" {
-			return utils.NotFoundResponse(c, fmt.Sprintf("Document '%s' not found", document))
-		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "getAppCollectionsAndProperties")
+		return serviceErrorResponse(c, err, "getAppCollectionsAndProperties", fmt.Sprintf("Document '%s' not found", document))
 	}
 
 	if len(result) == 0 {
@@ -102,7 +144,7 @@ func (h *AppDataHandler) GetAppCollectionsAndProperties(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return sendConditionalJSON(c, result, document)
 }
 
 // GetAppDocumentsCollectionsAndProperties handles GET /api/data/app
@@ -111,17 +153,20 @@ func (h *AppDataHandler) GetAppCollectionsAndProperties(c *fiber.Ctx) error {
 // @Tags AppData
 // @Accept json
 // @Produce json
+// @Param fields query string false "Comma-separated list of properties, or property.json.path selections, to return"
+// @Param include query string false "Set to meta to return property metadata (version, createdAt, updatedAt) under __meta"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Strong entity tag, prefixed with the document version on single document routes"
+// @Success 304 {string} string "Not Modified"
 // @Failure 404 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/app [get]
 func (h *AppDataHandler) GetAppDocumentsCollectionsAndProperties(c *fiber.Ctx) error {
-	result, err := services.GetApplicationDocumentsCollectionsAndProperties(h.DB)
+	result, err := h.reader(c).GetApplicationDocumentsCollectionsAndProperties(c.UserContext(), publicReadOptions(c))
 	if err != nil {
-		if err.Error() == "not found" {
-			return utils.NotFoundResponse(c, "No application documents found")
-		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "getAppDocumentsCollectionsAndProperties")
+		return serviceErrorResponse(c, err, "getAppDocumentsCollectionsAndProperties", "No application documents found")
 	}
 
 	if len(result) == 0 {
@@ -132,44 +177,77 @@ func (h *AppDataHandler) GetAppDocumentsCollectionsAndProperties(c *fiber.Ctx) e
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return sendConditionalJSON(c, result, "")
 }
 
 // SetAppProperties handles POST /api/data/app/:document
 // @Summary Set application properties
-// @Description Set properties for a specific application document
+// @Description Set properties for a specific application document. Set conflictDetails in the body to receive the current version and collections with a version conflict, and mergeStrategy "merge" to accept a stale version when the written properties are unchanged since it. A collection operations map ($inc, $min, $max, $push, $addToSet, $pull, $unset) updates properties from their current values, and mergeStrategy "atomic" accepts any version for writes of only operations. Set ttl (seconds) or expiresAt on the body, a collection, or in a collection propertyExpiry map to expire the document, collection or properties
 // @Tags AppData
 // @Accept json
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param body body object true "Properties to set"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
 // @Success 200 {object} utils.SuccessResponseStruct
 // @Failure 400 {object} utils.ErrorResponseStruct
-// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.VersionConflictResponseStruct
+// @Failure 412 {object} utils.VersionConflictResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/app/{document} [post]
 func (h *AppDataHandler) SetAppProperties(c *fiber.Ctx) error {
 	document := c.Params("document")
 
 	var body struct {
-		Version     types.FlexUint64                         `json:"version"`
-		Collections types.FlexList[services.CollectionInput] `json:"collections"`
+		Version         types.FlexUint64                         `json:"version"`
+		Collections     types.FlexList[services.CollectionInput] `json:"collections"`
+		ConflictDetails bool                                     `json:"conflictDetails"`
+		MergeStrategy   string                                   `json:"mergeStrategy"`
+		services.Expiry
+	}
+
+	var opts services.WriteOptions
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "setAppProperties")
 	}
 
-	if err := c.BodyParser(&body); err != nil {
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	if document == "" || len(body.Collections) == 0 {
+	version := body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	if document == "" || document == trashDocument || len(body.Collections) == 0 {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	newVersion, affectedRows, err := services.SetApplicationProperties(h.DB, document, body.Version.Uint64(), body.Collections.Slice())
+	mergeStrategy, err := services.ParseMergeStrategy(body.MergeStrategy)
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	// The admin making the change, recorded as the last writer
+	opts.Actor, _ = getUserID(c)
+
+	opts.MergeStrategy = mergeStrategy
+	opts.Expiry = body.Expiry
+	newVersion, affectedRows, err := h.writer(c).SetApplicationProperties(c.UserContext(), document, version, body.Collections.Slice(), opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "E_VERSION") {
-			return utils.VersionErrorResponse(c)
+		if errors.Is(err, services.ErrVersionConflict) {
+			var details fiber.Map
+			if body.ConflictDetails {
+				details = conflictDetails(h.fetchDocument(c, document), document, version, body.Collections.Slice())
+			}
+			return versionErrorResponse(c, hasIfMatch, details)
 		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "setAppProperties")
+		return serviceErrorResponse(c, err, "setAppProperties", "Document or collection not found")
 	}
 
 	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
@@ -184,10 +262,15 @@ func (h *AppDataHandler) SetAppProperties(c *fiber.Ctx) error {
 // @Param document path string true "Document ID"
 // @Param collection path string true "Collection ID"
 // @Param body body object true "Version check"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
 // @Success 200 {object} utils.SuccessResponseStruct
 // @Failure 400 {object} utils.ErrorResponseStruct
 // @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 412 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/app/{document}/{collection} [delete]
 func (h *AppDataHandler) DeleteAppCollection(c *fiber.Ctx) error {
 	document := c.Params("document")
@@ -197,16 +280,30 @@ func (h *AppDataHandler) DeleteAppCollection(c *fiber.Ctx) error {
 		Version types.FlexUint64 `json:"version"`
 	}
 
-	if err := c.BodyParser(&body); err != nil {
+	var opts services.WriteOptions
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "deleteAppCollection")
+	}
+
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	newVersion, affectedRows, err := services.DeleteApplicationCollection(h.DB, document, body.Version.Uint64(), collection)
+	version := body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	// The admin making the change, recorded in the trash
+	opts.Actor, _ = getUserID(c)
+
+	newVersion, affectedRows, err := h.writer(c).DeleteApplicationCollection(c.UserContext(), document, version, collection, opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "E_VERSION") {
-			return utils.VersionErrorResponse(c)
+		if errors.Is(err, services.ErrVersionConflict) {
+			return versionErrorResponse(c, hasIfMatch, nil)
 		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "deleteAppCollection")
+		return serviceErrorResponse(c, err, "deleteAppCollection", "Document or collection not found")
 	}
 
 	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
@@ -220,10 +317,15 @@ func (h *AppDataHandler) DeleteAppCollection(c *fiber.Ctx) error {
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param body body object true "Properties to delete"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
 // @Success 200 {object} utils.SuccessResponseStruct
 // @Failure 400 {object} utils.ErrorResponseStruct
 // @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 412 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/app/{document} [delete]
 func (h *AppDataHandler) DeleteAppProperties(c *fiber.Ctx) error {
 	document := c.Params("document")
@@ -234,16 +336,173 @@ func (h *AppDataHandler) DeleteAppProperties(c *fiber.Ctx) error {
 		DeleteDocument bool                                           `json:"deleteDocument"`
 	}
 
-	if err := c.BodyParser(&body); err != nil {
+	var opts services.WriteOptions
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "deleteAppProperties")
+	}
+
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	newVersion, affectedRows, err := services.DeleteApplicationProperties(h.DB, document, body.Version.Uint64(), body.Collections.Slice(), body.DeleteDocument)
+	version := body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	// The admin making the change, recorded in the trash
+	opts.Actor, _ = getUserID(c)
+
+	newVersion, affectedRows, err := h.writer(c).DeleteApplicationProperties(c.UserContext(), document, version, body.Collections.Slice(), body.DeleteDocument, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			return versionErrorResponse(c, hasIfMatch, nil)
+		}
+		return serviceErrorResponse(c, err, "deleteAppProperties", "Document or collection not found")
+	}
+
+	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
+}
+
+// CopyAppDocument handles POST /api/data/app/:document/copy
+// @Summary Copy an application document
+// @Description Copy the collections and properties of an application document at the given version to a new document, in one transaction. The version returned is the new document's. The copy gets collections of its own, so writing to it leaves the source unchanged. An existing target returns 409
+// @Tags AppData
+// @Accept json
+// @Produce json
+// @Param document path string true "Document ID"
+// @Param body body object true "Target document name and version check"
+// @Param target query string false "Target document name, instead of the body"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
+// @Success 200 {object} utils.SuccessResponseStruct
+// @Failure 400 {object} utils.ErrorResponseStruct
+// @Failure 404 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 412 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/app/{document}/copy [post]
+func (h *AppDataHandler) CopyAppDocument(c *fiber.Ctx) error {
+	document := c.Params("document")
+
+	var opts services.WriteOptions
+	version, target, hasIfMatch, err := parseTargetMutation(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "copyAppDocument")
+	}
+
+	// The admin making the change, recorded as the last writer
+	opts.Actor, _ = getUserID(c)
+
+	newVersion, affectedRows, err := h.writer(c).CopyApplicationDocument(c.UserContext(), document, version, target, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			return versionErrorResponse(c, hasIfMatch, nil)
+		}
+		return serviceErrorResponse(c, err, "copyAppDocument", fmt.Sprintf("Document '%s' not found", document))
+	}
+
+	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
+}
+
+// RenameAppDocument handles POST /api/data/app/:document/rename
+// @Summary Rename an application document
+// @Description Rename an application document at the given version, in one transaction, bumping its version. An existing target returns 409
+// @Tags AppData
+// @Accept json
+// @Produce json
+// @Param document path string true "Document ID"
+// @Param body body object true "Target document name and version check"
+// @Param target query string false "Target document name, instead of the body"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
+// @Success 200 {object} utils.SuccessResponseStruct
+// @Failure 400 {object} utils.ErrorResponseStruct
+// @Failure 404 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 412 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/app/{document}/rename [post]
+func (h *AppDataHandler) RenameAppDocument(c *fiber.Ctx) error {
+	document := c.Params("document")
+
+	var opts services.WriteOptions
+	version, target, hasIfMatch, err := parseTargetMutation(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "renameAppDocument")
+	}
+
+	// The admin making the change, recorded as the last writer
+	opts.Actor, _ = getUserID(c)
+
+	newVersion, affectedRows, err := h.writer(c).RenameApplicationDocument(c.UserContext(), document, version, target, opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "E_VERSION") {
-			return utils.VersionErrorResponse(c)
+		if errors.Is(err, services.ErrVersionConflict) {
+			return versionErrorResponse(c, hasIfMatch, nil)
 		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "deleteAppProperties")
+		return serviceErrorResponse(c, err, "renameAppDocument", fmt.Sprintf("Document '%s' not found", document))
+	}
+
+	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
+}
+
+// GetAppTrash handles GET /api/data/app/_trash
+// @Summary List deleted application data
+// @Description List the deleted application documents and collections in the trash, most recent first. Entries are purged after the trash retention
+// @Tags AppData
+// @Produce json
+// @Success 200 {object} handlers.TrashResponse
+// @Failure 401 {object} utils.ErrorResponseStruct
+// @Failure 403 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/app/_trash [get]
+func (h *AppDataHandler) GetAppTrash(c *fiber.Ctx) error {
+	entries, err := h.writer(c).GetApplicationTrash(c.UserContext())
+	if err != nil {
+		return serviceErrorResponse(c, err, "getAppTrash", "Trash not found")
+	}
+
+	return c.JSON(TrashResponse{Trash: entries})
+}
+
+// RestoreAppTrash handles POST /api/data/app/_trash/:document/restore
+// @Summary Restore deleted application data
+// @Description Restore the most recently deleted copy of an application document, or of one of its collections when collection is given. Existing documents and collections are not overwritten
+// @Tags AppData
+// @Accept json
+// @Produce json
+// @Param document path string true "Document ID"
+// @Param body body object false "Optional collection to restore"
+// @Param collection query string false "Collection to restore, instead of the body"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
+// @Success 200 {object} utils.SuccessResponseStruct
+// @Failure 400 {object} utils.ErrorResponseStruct
+// @Failure 404 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/app/_trash/{document}/restore [post]
+func (h *AppDataHandler) RestoreAppTrash(c *fiber.Ctx) error {
+	document := c.Params("document")
+
+	collection, err := parseRestoreCollection(c)
+	if err != nil {
+		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	// The admin making the change, recorded as the last writer
+	actor, _ := getUserID(c)
+
+	newVersion, affectedRows, err := h.writer(c).RestoreApplicationTrash(c.UserContext(), document, collection, services.WriteOptions{Actor: actor})
+	if err != nil {
+		return serviceErrorResponse(c, err, "restoreAppTrash", fmt.Sprintf("Document '%s' not found in trash", document))
 	}
 
 	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
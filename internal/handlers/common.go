@@ -20,42 +20,334 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/internal/types"
+	"github.com/localnerve/jam-build-propsdb/internal/utils"
+	"gorm.io/gorm"
 )
 
+// readerFor returns the pool for GETs, a healthy read replica if any are configured.
+// key identifies the writer for read-your-writes, see ReplicaSet.MarkWrite.
+func readerFor(primary *gorm.DB, replicas *database.ReplicaSet, key string) *gorm.DB {
+	if replicas == nil {
+		return primary
+	}
+	return replicas.Reader(key)
+}
+
 // parseCollections extracts collections from query parameters,
 // supporting both multiple 'collections' keys and comma-separated values.
 func parseCollections(c *fiber.Ctx) []string {
-	collectionMap := make(map[string]struct{})
+	return parseListParam(c, "collections")
+}
+
+// parseReadOptions builds the read options for GET routes from query parameters.
+// 'fields' selects properties, or JSON paths inside them, e.g. fields=theme,locale.lang
+// 'include=meta' adds property metadata under services.MetaKey
+func parseReadOptions(c *fiber.Ctx) services.ReadOptions {
+	opts := services.ReadOptions{
+		Fields: services.ParseProjection(parseListParam(c, "fields")),
+	}
+	for _, include := range parseListParam(c, "include") {
+		if include == "meta" {
+			opts.IncludeMeta = true
+		}
+	}
+	// The earliest expiry read bounds how long the response may be cached, see middleware.AppCache
+	opts.OnExpiry = func(expiresAt time.Time) {
+		if earliest, ok := c.Locals("expiresAt").(time.Time); !ok || expiresAt.Before(earliest) {
+			c.Locals("expiresAt", expiresAt)
+		}
+	}
+	return opts
+}
 
-	// Visit all query arguments to collect multiple 'collections' parameters
+// parseLayerOptions builds the options of a layered read from query parameters.
+// 'layerMerge' holds collection:mode rules, 'include=provenance' adds the layer of each property
+func parseLayerOptions(c *fiber.Ctx) (services.LayerOptions, error) {
+	opts, err := services.ParseLayerRules(parseListParam(c, "layerMerge"))
+	if err != nil {
+		return opts, err
+	}
+	for _, include := range parseListParam(c, "include") {
+		if include == "provenance" {
+			opts.Provenance = true
+		}
+	}
+	return opts, nil
+}
+
+// parseListParam collects the unique values of a query parameter,
+// supporting both multiple keys and comma-separated values.
+func parseListParam(c *fiber.Ctx, name string) []string {
+	valueMap := make(map[string]struct{})
+
+	// Visit all query arguments to collect multiple parameters of the same name
 	args := c.Context().QueryArgs()
 	for key, value := range args.All() {
-		if string(key) == "collections" {
+		if string(key) == name {
 			// Split by comma in case the value itself is comma-separated
 			vals := strings.Split(string(value), ",")
 			for _, v := range vals {
 				v = strings.TrimSpace(v)
 				if v != "" {
-					collectionMap[v] = struct{}{}
+					valueMap[v] = struct{}{}
 				}
 			}
 		}
 	}
 
-	if len(collectionMap) == 0 {
+	if len(valueMap) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(valueMap))
+	for k := range valueMap {
+		values = append(values, k)
+	}
+
+	return values
+}
+
+// sendConditionalJSON sends a GET result with a strong ETag, or 304 if the client already has it.
+// Single document results are tagged "<version>-<hash>", so the version can be sent back in If-Match.
+func sendConditionalJSON(c *fiber.Ctx, result map[string]interface{}, document string) error {
+	body, err := c.App().Config().JSONEncoder(result)
+	if err != nil {
+		return err
+	}
+
+	hash := fnv.New64a()
+	hash.Write(body)
+	tag := fmt.Sprintf("%016x", hash.Sum64())
+	if docMap, ok := result[document].(map[string]interface{}); ok && document != "" {
+		if version, ok := docMap["__version"].(string); ok {
+			tag = version + "-" + tag
+		}
+	}
+	etag := `"` + tag + `"`
+
+	c.Set(fiber.HeaderETag, etag)
+	if utils.ETagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(fiber.StatusOK).Send(body)
+}
+
+// ifMatchVersion reads the expected document version from an If-Match header.
+// ok is false if the header is absent, in which case the body version applies.
+// Accepts tags as sent in ETag ("3-9f86d081884c7d65") or just the version ("3"), and "*", which sets
+// opts.AnyVersion so the write checks for an existing document in its own transaction.
+// A malformed header is a 400 *types.CustomError, answer any error with requestErrorResponse.
+func ifMatchVersion(c *fiber.Ctx, opts *services.WriteOptions) (version uint64, ok bool, err error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return 0, false, nil
+	}
+
+	if header == "*" {
+		opts.AnyVersion = true
+		return 0, true, nil
+	}
+
+	if strings.Contains(header, ",") || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 3 {
+		return 0, true, invalidInput("If-Match must be a single strong entity tag")
+	}
+
+	tag := strings.Trim(header, `"`)
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		tag = tag[:i]
+	}
+
+	version, err = strconv.ParseUint(tag, 10, 64)
+	if err != nil {
+		return 0, true, invalidInput("If-Match does not contain a document version")
+	}
+
+	return version, true, nil
+}
+
+// invalidInput is a malformed mutation request, answered 400
+func invalidInput(message string) error {
+	return &types.CustomError{Code: fiber.StatusBadRequest, Message: message, Type: "data.validation.input"}
+}
+
+// requestErrorResponse answers an error parsing a mutation request, 400 for malformed input
+func requestErrorResponse(c *fiber.Ctx, err error, op string) error {
+	var customErr *types.CustomError
+	if errors.As(err, &customErr) {
+		return utils.ErrorResponse(c, customErr.Message, customErr.Code, customErr.Type)
+	}
+	return serviceErrorResponse(c, err, op, "")
+}
+
+// parseMutationBody parses a mutation body, allowing it to be empty when If-Match supplies the version
+func parseMutationBody(c *fiber.Ctx, out interface{}, hasIfMatch bool) error {
+	if hasIfMatch && len(c.Body()) == 0 {
+		return nil
+	}
+	return c.BodyParser(out)
+}
+
+// parseTargetMutation parses a copy or rename: the expected version from If-Match or the body, and the
+// target document from the body or the target query parameter. hasIfMatch reports where the version came from.
+// Answer any error with requestErrorResponse.
+func parseTargetMutation(c *fiber.Ctx, opts *services.WriteOptions) (version uint64, target string, hasIfMatch bool, err error) {
+	var body struct {
+		Version types.FlexUint64 `json:"version"`
+		Target  string           `json:"target"`
+	}
+
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, opts)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
+		return 0, "", false, invalidInput("Invalid input")
+	}
+
+	version = body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	target = body.Target
+	if target == "" {
+		target = c.Query("target")
+	}
+	if target == "" || target == trashDocument {
+		return 0, "", false, invalidInput("Invalid input")
+	}
+
+	return version, target, hasIfMatch, nil
+}
+
+// versionErrorResponse reports a version mismatch, as 412 if the expected version came from If-Match.
+// Non-nil details are added to the response body.
+func versionErrorResponse(c *fiber.Ctx, hasIfMatch bool, details fiber.Map) error {
+	status := fiber.StatusConflict
+	if hasIfMatch {
+		status = fiber.StatusPreconditionFailed
+	}
+	return utils.VersionConflictResponse(c, status, details)
+}
+
+// conflictDetails builds the current server state of the collections affected by a conflicting mutation,
+// and the properties in them that changed after the client's base version.
+// fetch reads the whole document from the primary. A missing document reports version "0" and no collections.
+func conflictDetails(fetch func(services.ReadOptions) (services.DocumentResult, error), document string, version uint64, collections []services.CollectionInput) fiber.Map {
+	result, err := fetch(services.ReadOptions{IncludeMeta: true})
+	if err != nil && !errors.Is(err, services.ErrNotFound) {
 		return nil
 	}
 
-	collections := make([]string, 0, len(collectionMap))
-	for k := range collectionMap {
-		collections = append(collections, k)
+	docMap, _ := result[document].(map[string]interface{})
+	currentVersion, _ := docMap["__version"].(string)
+	if currentVersion == "" {
+		currentVersion = "0"
+	}
+	meta, _ := docMap[services.MetaKey].(services.DocumentMeta)
+
+	current := make(map[string]interface{})
+	changes := make([]fiber.Map, 0)
+	for _, coll := range collections {
+		props, ok := docMap[coll.Collection]
+		if !ok {
+			continue
+		}
+		current[coll.Collection] = props
+
+		names := make([]string, 0, len(meta[coll.Collection]))
+		for name := range meta[coll.Collection] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propMeta := meta[coll.Collection][name]
+			if propVersion, _ := strconv.ParseUint(propMeta.Version, 10, 64); propVersion > version {
+				changes = append(changes, fiber.Map{
+					"collection": coll.Collection,
+					"property":   name,
+					"version":    propMeta.Version,
+					"modifiedBy": propMeta.ModifiedBy,
+				})
+			}
+		}
 	}
 
-	return collections
+	return fiber.Map{
+		"currentVersion": currentVersion,
+		"collections":    current,
+		"changes":        changes,
+	}
+}
+
+// trashDocument is the reserved path segment of the trash routes, which cannot be used as a document name
+const trashDocument = "_trash"
+
+// TrashResponse is the body of a trash listing
+type TrashResponse struct {
+	Trash []services.TrashEntry `json:"trash"`
+}
+
+// parseRestoreCollection reads the optional collection to restore from the body, or the collection query parameter
+func parseRestoreCollection(c *fiber.Ctx) (string, error) {
+	var body struct {
+		Collection string `json:"collection"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return "", err
+		}
+	}
+	if body.Collection == "" {
+		body.Collection = c.Query("collection")
+	}
+	return body.Collection, nil
+}
+
+// serviceErrorResponse maps a service error to its response, using notFound as the 404 message.
+// Version conflicts from mutations are handled first by versionErrorResponse.
+func serviceErrorResponse(c *fiber.Ctx, err error, op, notFound string) error {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return utils.NotFoundResponse(c, notFound)
+	case errors.Is(err, services.ErrVersionConflict):
+		return utils.VersionErrorResponse(c)
+	case errors.Is(err, services.ErrValidation):
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusBadRequest, "data.validation.input")
+	case errors.Is(err, services.ErrForbidden):
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization")
+	case errors.Is(err, services.ErrExists):
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusConflict, "data.exists")
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusTooManyRequests, "data.quota")
+	case errors.Is(err, database.ErrUnavailable):
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusServiceUnavailable, "data.unavailable")
+	case errors.Is(err, context.DeadlineExceeded):
+		return utils.ErrorResponse(c, "database timeout", fiber.StatusServiceUnavailable, "data.timeout")
+	case errors.Is(err, context.Canceled):
+		return utils.ErrorResponse(c, "request canceled", fiber.StatusServiceUnavailable, "data.canceled")
+	case database.IsConnectionError(err):
+		return utils.ErrorResponse(c, database.ErrUnavailable.Error(), fiber.StatusServiceUnavailable, "data.unavailable")
+	}
+	return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, op)
 }
 
 // hasContent checks if the result map contains any non-empty properties
@@ -67,7 +359,7 @@ func hasContent(result map[string]interface{}) bool {
 
 	for key, value := range result {
 		// Ignore metadata
-		if key == "__version" {
+		if key == "__version" || key == services.MetaKey || key == services.AppVersionKey || key == services.ProvenanceKey {
 			continue
 		}
 
@@ -0,0 +1,61 @@
+// health.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+)
+
+// HealthHandler serves the liveness, readiness and detailed health endpoints.
+// They are mounted outside /api, for orchestrator probes.
+type HealthHandler struct {
+	Checker *services.HealthChecker
+}
+
+// Liveness reports that the process is serving requests, without checking dependencies.
+// GET /healthz, 200 {"status":"ok"}
+func (h *HealthHandler) Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readiness reports whether the service can take traffic.
+// GET /readyz, 200 when ready, otherwise 503, with the services.ReadinessResult
+func (h *HealthHandler) Readiness(c *fiber.Ctx) error {
+	result := h.Checker.Ready(c.UserContext())
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if !result.Ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(result)
+	}
+	return c.JSON(result)
+}
+
+// Health returns the detailed health of the service and its dependencies, for admins.
+// GET /health, 200 when healthy or degraded, 503 when unhealthy, with the services.HealthCheckResult
+func (h *HealthHandler) Health(c *fiber.Ctx) error {
+	result := h.Checker.Health(c.UserContext())
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if result.Status == "unhealthy" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(result)
+	}
+	return c.JSON(result)
+}
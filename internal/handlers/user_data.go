@@ -20,12 +20,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/localnerve/authorizer-go"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
 	"github.com/localnerve/jam-build-propsdb/internal/services"
 	"github.com/localnerve/jam-build-propsdb/internal/types"
 	"github.com/localnerve/jam-build-propsdb/internal/utils"
@@ -34,20 +36,23 @@ import (
 
 // UserDataHandler handles user data routes
 type UserDataHandler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Replicas *database.ReplicaSet // Optional, GETs read from replicas when set
+	Store    services.Store       // Optional, replaces DB and Replicas when set
+	Timeout  time.Duration        // Optional, bounds each store call
 }
 
 // getUserID extracts user ID from context (set by auth middleware)
 func getUserID(c *fiber.Ctx) (string, error) {
 	user := c.Locals("user")
 	if user == nil {
-		return "", fmt.Errorf("user not found in context")
+		return "", fmt.Errorf("%w: user not found in context", services.ErrForbidden)
 	}
 
 	// Try as *authorizer.User struct first
 	if u, ok := user.(*authorizer.User); ok {
 		if u.ID == "" {
-			return "", fmt.Errorf("user ID not found in struct")
+			return "", fmt.Errorf("%w: user ID not found in struct", services.ErrForbidden)
 		}
 		return u.ID, nil
 	}
@@ -55,17 +60,40 @@ func getUserID(c *fiber.Ctx) (string, error) {
 	// Fallback to map[string]interface{}
 	userMap, ok := user.(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("invalid user data format: %T", user)
+		return "", fmt.Errorf("%w: invalid user data format: %T", services.ErrForbidden, user)
 	}
 
 	userID, ok := userMap["id"].(string)
 	if !ok {
-		return "", fmt.Errorf("user ID not found in map")
+		return "", fmt.Errorf("%w: user ID not found in map", services.ErrForbidden)
 	}
 
 	return userID, nil
 }
 
+// reader returns the Store for a user's GETs
+func (h *UserDataHandler) reader(c *fiber.Ctx, userID string) services.Store {
+	if h.Store != nil {
+		return h.Store
+	}
+	return services.GormStore{DB: readerFor(h.DB, h.Replicas, userID), Timeout: h.Timeout}
+}
+
+// writer returns the Store for mutations
+func (h *UserDataHandler) writer(c *fiber.Ctx) services.Store {
+	if h.Store != nil {
+		return h.Store
+	}
+	return services.GormStore{DB: h.DB, Timeout: h.Timeout}
+}
+
+// fetchDocument returns a reader of a document from the primary, for conflict details
+func (h *UserDataHandler) fetchDocument(c *fiber.Ctx, userID, document string) func(services.ReadOptions) (services.DocumentResult, error) {
+	return func(opts services.ReadOptions) (services.DocumentResult, error) {
+		return h.writer(c).GetUserCollectionsAndProperties(c.UserContext(), userID, document, nil, opts)
+	}
+}
+
 // GetUserProperties handles GET /api/data/user/:document/:collection
 // @Summary Get user properties
 // @Description Get properties for a specific user document and collection
@@ -74,26 +102,29 @@ func getUserID(c *fiber.Ctx) (string, error) {
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param collection path string true "Collection ID"
+// @Param fields query string false "Comma-separated list of properties, or property.json.path selections, to return"
+// @Param include query string false "Set to meta to return property metadata (version, modifiedBy, createdAt, updatedAt) under __meta"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Strong entity tag, prefixed with the document version on single document routes"
+// @Success 304 {string} string "Not Modified"
 // @Failure 403 {object} utils.ErrorResponseStruct
 // @Failure 404 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/user/{document}/{collection} [get]
 func (h *UserDataHandler) GetUserProperties(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization.user")
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
 	}
 
 	document := c.Params("document")
 	collection := c.Params("collection")
 
-	result, err := services.GetUserProperties(h.DB, userID, document, collection)
+	result, err := h.reader(c, userID).GetUserProperties(c.UserContext(), userID, document, collection, parseReadOptions(c))
 	if err != nil {
-		if err.Error() == "not found" {
-			return utils.NotFoundResponse(c, fmt.Sprintf("Document '%s' or collection '%s' not found", document, collection))
-		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "getUserProperties")
+		return serviceErrorResponse(c, err, "getUserProperties", fmt.Sprintf("Document '%s' or collection '%s' not found", document, collection))
 	}
 
 	if len(result) == 0 {
@@ -104,7 +135,7 @@ func (h *UserDataHandler) GetUserProperties(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return sendConditionalJSON(c, result, document)
 }
 
 // GetUserCollectionsAndProperties handles GET /api/data/user/:document?collections=...
@@ -115,26 +146,36 @@ func (h *UserDataHandler) GetUserProperties(c *fiber.Ctx) error {
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param collections query string false "Comma-separated list of collections to filter"
+// @Param fields query string false "Comma-separated list of properties, or property.json.path selections, to return"
+// @Param include query string false "Set to meta to return property metadata (version, modifiedBy, createdAt, updatedAt) under __meta, or provenance with layer=app to return the layer of each property under __provenance"
+// @Param layer query string false "Set to app to deep merge the user document over the app document of the same name"
+// @Param layerMerge query string false "Comma-separated collection:mode rules for layer=app, mode merge or replace. *:mode sets the default, merge"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Strong entity tag, prefixed with the document version on single document routes"
+// @Success 304 {string} string "Not Modified"
+// @Failure 400 {object} utils.ErrorResponseStruct
 // @Failure 403 {object} utils.ErrorResponseStruct
 // @Failure 404 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/user/{document} [get]
 func (h *UserDataHandler) GetUserCollectionsAndProperties(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization.user")
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
 	}
 
 	document := c.Params("document")
 	collections := parseCollections(c)
 
-	result, err := services.GetUserCollectionsAndProperties(h.DB, userID, document, collections)
+	if layer := c.Query("layer"); layer != "" {
+		return h.getLayeredDocument(c, userID, document, collections, layer)
+	}
+
+	result, err := h.reader(c, userID).GetUserCollectionsAndProperties(c.UserContext(), userID, document, collections, parseReadOptions(c))
 	if err != nil {
-		if err.Error() == "not found" {
-			return utils.NotFoundResponse(c, fmt.Sprintf("Document '%s' not found", document))
-		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "getUserCollectionsAndProperties")
+		return serviceErrorResponse(c, err, "getUserCollectionsAndProperties", fmt.Sprintf("Document '%s' not found", document))
 	}
 
 	if len(result) == 0 {
@@ -145,7 +186,45 @@ func (h *UserDataHandler) GetUserCollectionsAndProperties(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return sendConditionalJSON(c, result, document)
+}
+
+// getLayeredDocument sends a user document deep merged over the app document of the same name
+func (h *UserDataHandler) getLayeredDocument(c *fiber.Ctx, userID, document string, collections []string, layer string) error {
+	if layer != "app" {
+		return utils.ErrorResponse(c, "layer must be app", fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	layerOpts, err := parseLayerOptions(c)
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	notFound := fmt.Sprintf("Document '%s' not found", document)
+	readOpts := parseReadOptions(c)
+	store := h.reader(c, userID)
+
+	user, err := store.GetUserCollectionsAndProperties(c.UserContext(), userID, document, collections, readOpts)
+	if err != nil && !errors.Is(err, services.ErrNotFound) {
+		return serviceErrorResponse(c, err, "getUserCollectionsAndProperties", notFound)
+	}
+	// The app layer is public, so its metadata leaves out the admin ids as on the app routes
+	appOpts := readOpts
+	appOpts.OmitActors = true
+	app, err := store.GetApplicationCollectionsAndProperties(c.UserContext(), document, collections, appOpts)
+	if err != nil && !errors.Is(err, services.ErrNotFound) {
+		return serviceErrorResponse(c, err, "getApplicationCollectionsAndProperties", notFound)
+	}
+	if user == nil && app == nil {
+		return serviceErrorResponse(c, services.ErrNotFound, "getLayeredDocument", notFound)
+	}
+
+	result := services.LayerDocument(document, app, user, layerOpts)
+	if !hasContent(result) {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	return sendConditionalJSON(c, result, document)
 }
 
 // GetUserDocumentsCollectionsAndProperties handles GET /api/data/user
@@ -154,23 +233,26 @@ func (h *UserDataHandler) GetUserCollectionsAndProperties(c *fiber.Ctx) error {
 // @Tags UserData
 // @Accept json
 // @Produce json
+// @Param fields query string false "Comma-separated list of properties, or property.json.path selections, to return"
+// @Param include query string false "Set to meta to return property metadata (version, modifiedBy, createdAt, updatedAt) under __meta"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Strong entity tag, prefixed with the document version on single document routes"
+// @Success 304 {string} string "Not Modified"
 // @Failure 403 {object} utils.ErrorResponseStruct
 // @Failure 404 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/user [get]
 func (h *UserDataHandler) GetUserDocumentsCollectionsAndProperties(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization.user")
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
 	}
 
-	result, err := services.GetUserDocumentsCollectionsAndProperties(h.DB, userID)
+	result, err := h.reader(c, userID).GetUserDocumentsCollectionsAndProperties(c.UserContext(), userID, parseReadOptions(c))
 	if err != nil {
-		if err.Error() == "not found" {
-			return utils.NotFoundResponse(c, "No user documents found")
-		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "getUserDocumentsCollectionsAndProperties")
+		return serviceErrorResponse(c, err, "getUserDocumentsCollectionsAndProperties", "No user documents found")
 	}
 
 	if len(result) == 0 {
@@ -181,50 +263,81 @@ func (h *UserDataHandler) GetUserDocumentsCollectionsAndProperties(c *fiber.Ctx)
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return sendConditionalJSON(c, result, "")
 }
 
 // SetUserProperties handles POST /api/data/user/:document
 // @Summary Set user properties
-// @Description Set properties for a specific user document
+// @Description Set properties for a specific user document. Set conflictDetails in the body to receive the current version and collections with a version conflict, and mergeStrategy "merge" to accept a stale version when the written properties are unchanged since it. A collection operations map ($inc, $min, $max, $push, $addToSet, $pull, $unset) updates properties from their current values, and mergeStrategy "atomic" accepts any version for writes of only operations. Set ttl (seconds) or expiresAt on the body, a collection, or in a collection propertyExpiry map to expire the document, collection or properties
 // @Tags UserData
 // @Accept json
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param body body object true "Properties to set"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
 // @Success 200 {object} utils.SuccessResponseStruct
 // @Failure 400 {object} utils.ErrorResponseStruct
 // @Failure 403 {object} utils.ErrorResponseStruct
-// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.VersionConflictResponseStruct
+// @Failure 412 {object} utils.VersionConflictResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/user/{document} [post]
 func (h *UserDataHandler) SetUserProperties(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization.user")
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
 	}
 
 	document := c.Params("document")
 
 	var body struct {
-		Version     types.FlexUint64                         `json:"version"`
-		Collections types.FlexList[services.CollectionInput] `json:"collections"`
+		Version         types.FlexUint64                         `json:"version"`
+		Collections     types.FlexList[services.CollectionInput] `json:"collections"`
+		ConflictDetails bool                                     `json:"conflictDetails"`
+		MergeStrategy   string                                   `json:"mergeStrategy"`
+		services.Expiry
 	}
 
-	if err := c.BodyParser(&body); err != nil {
+	var opts services.WriteOptions
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "setUserProperties")
+	}
+
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	if document == "" || len(body.Collections) == 0 {
+	version := body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	if document == "" || document == trashDocument || len(body.Collections) == 0 {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	newVersion, affectedRows, err := services.SetUserProperties(h.DB, userID, document, body.Version.Uint64(), body.Collections.Slice())
+	mergeStrategy, err := services.ParseMergeStrategy(body.MergeStrategy)
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error(), fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	opts.MergeStrategy = mergeStrategy
+	opts.Actor = userID
+	opts.Expiry = body.Expiry
+	newVersion, affectedRows, err := h.writer(c).SetUserProperties(c.UserContext(), userID, document, version, body.Collections.Slice(), opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "E_VERSION") {
-			return utils.VersionErrorResponse(c)
+		if errors.Is(err, services.ErrVersionConflict) {
+			var details fiber.Map
+			if body.ConflictDetails {
+				details = conflictDetails(h.fetchDocument(c, userID, document), document, version, body.Collections.Slice())
+			}
+			return versionErrorResponse(c, hasIfMatch, details)
 		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "setUserProperties")
+		return serviceErrorResponse(c, err, "setUserProperties", "Document or collection not found")
 	}
 
 	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
@@ -239,16 +352,21 @@ func (h *UserDataHandler) SetUserProperties(c *fiber.Ctx) error {
 // @Param document path string true "Document ID"
 // @Param collection path string true "Collection ID"
 // @Param body body object true "Version check"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
 // @Success 200 {object} utils.SuccessResponseStruct
 // @Failure 400 {object} utils.ErrorResponseStruct
 // @Failure 403 {object} utils.ErrorResponseStruct
 // @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 412 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/user/{document}/{collection} [delete]
 func (h *UserDataHandler) DeleteUserCollection(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization.user")
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
 	}
 
 	document := c.Params("document")
@@ -258,16 +376,28 @@ func (h *UserDataHandler) DeleteUserCollection(c *fiber.Ctx) error {
 		Version types.FlexUint64 `json:"version"`
 	}
 
-	if err := c.BodyParser(&body); err != nil {
+	var opts services.WriteOptions
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "deleteUserCollection")
+	}
+
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	newVersion, affectedRows, err := services.DeleteUserCollection(h.DB, userID, document, body.Version.Uint64(), collection)
+	version := body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	opts.Actor = userID
+	newVersion, affectedRows, err := h.writer(c).DeleteUserCollection(c.UserContext(), userID, document, version, collection, opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "E_VERSION") {
-			return utils.VersionErrorResponse(c)
+		if errors.Is(err, services.ErrVersionConflict) {
+			return versionErrorResponse(c, hasIfMatch, nil)
 		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "deleteUserCollection")
+		return serviceErrorResponse(c, err, "deleteUserCollection", "Document or collection not found")
 	}
 
 	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
@@ -281,16 +411,21 @@ func (h *UserDataHandler) DeleteUserCollection(c *fiber.Ctx) error {
 // @Produce json
 // @Param document path string true "Document ID"
 // @Param body body object true "Properties to delete"
+// @Param If-Match header string false "Expected document version, an ETag from GET, or * for any existing version. Takes precedence over the body version"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
 // @Success 200 {object} utils.SuccessResponseStruct
 // @Failure 400 {object} utils.ErrorResponseStruct
 // @Failure 403 {object} utils.ErrorResponseStruct
 // @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 412 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
 // @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
 // @Router /data/user/{document} [delete]
 func (h *UserDataHandler) DeleteUserProperties(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusForbidden, "data.authorization.user")
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
 	}
 
 	document := c.Params("document")
@@ -301,16 +436,130 @@ func (h *UserDataHandler) DeleteUserProperties(c *fiber.Ctx) error {
 		DeleteDocument bool                                           `json:"deleteDocument"`
 	}
 
-	if err := c.BodyParser(&body); err != nil {
+	var opts services.WriteOptions
+	ifMatch, hasIfMatch, err := ifMatchVersion(c, &opts)
+	if err != nil {
+		return requestErrorResponse(c, err, "deleteUserProperties")
+	}
+
+	if err := parseMutationBody(c, &body, hasIfMatch); err != nil {
 		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
 	}
 
-	newVersion, affectedRows, err := services.DeleteUserProperties(h.DB, userID, document, body.Version.Uint64(), body.Collections.Slice(), body.DeleteDocument)
+	version := body.Version.Uint64()
+	if hasIfMatch {
+		version = ifMatch
+	}
+
+	opts.Actor = userID
+	newVersion, affectedRows, err := h.writer(c).DeleteUserProperties(c.UserContext(), userID, document, version, body.Collections.Slice(), body.DeleteDocument, opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "E_VERSION") {
-			return utils.VersionErrorResponse(c)
+		if errors.Is(err, services.ErrVersionConflict) {
+			return versionErrorResponse(c, hasIfMatch, nil)
 		}
-		return utils.ErrorResponse(c, err.Error(), fiber.StatusInternalServerError, "deleteUserProperties")
+		return serviceErrorResponse(c, err, "deleteUserProperties", "Document or collection not found")
+	}
+
+	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
+}
+
+// CreateUserDocumentFromTemplate handles POST /api/data/user/:document/from-template/:appDocument
+// @Summary Create a user document from a template
+// @Description Create a user document with the collections and properties of an application document, such as default settings, in one transaction. An existing user document returns 409
+// @Tags UserData
+// @Produce json
+// @Param document path string true "Document ID"
+// @Param appDocument path string true "Application document to copy"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
+// @Success 200 {object} utils.SuccessResponseStruct
+// @Failure 400 {object} utils.ErrorResponseStruct
+// @Failure 403 {object} utils.ErrorResponseStruct
+// @Failure 404 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/user/{document}/from-template/{appDocument} [post]
+func (h *UserDataHandler) CreateUserDocumentFromTemplate(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
+	}
+
+	document := c.Params("document")
+	appDocument := c.Params("appDocument")
+
+	if document == "" || document == trashDocument || appDocument == "" {
+		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	newVersion, affectedRows, err := h.writer(c).CreateUserDocumentFromTemplate(c.UserContext(), userID, document, appDocument, services.WriteOptions{Actor: userID})
+	if err != nil {
+		return serviceErrorResponse(c, err, "createUserDocumentFromTemplate", fmt.Sprintf("Template document '%s' not found", appDocument))
+	}
+
+	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
+}
+
+// GetUserTrash handles GET /api/data/user/_trash
+// @Summary List deleted user data
+// @Description List the user's deleted documents and collections in the trash, most recent first. Entries are purged after the trash retention
+// @Tags UserData
+// @Produce json
+// @Success 200 {object} handlers.TrashResponse
+// @Failure 403 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/user/_trash [get]
+func (h *UserDataHandler) GetUserTrash(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
+	}
+
+	entries, err := h.writer(c).GetUserTrash(c.UserContext(), userID)
+	if err != nil {
+		return serviceErrorResponse(c, err, "getUserTrash", "Trash not found")
+	}
+
+	return c.JSON(TrashResponse{Trash: entries})
+}
+
+// RestoreUserTrash handles POST /api/data/user/_trash/:document/restore
+// @Summary Restore deleted user data
+// @Description Restore the most recently deleted copy of a user document, or of one of its collections when collection is given. Existing documents and collections are not overwritten
+// @Tags UserData
+// @Accept json
+// @Produce json
+// @Param document path string true "Document ID"
+// @Param body body object false "Optional collection to restore"
+// @Param collection query string false "Collection to restore, instead of the body"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first successful response"
+// @Success 200 {object} utils.SuccessResponseStruct
+// @Failure 400 {object} utils.ErrorResponseStruct
+// @Failure 403 {object} utils.ErrorResponseStruct
+// @Failure 404 {object} utils.ErrorResponseStruct
+// @Failure 409 {object} utils.ErrorResponseStruct
+// @Failure 422 {object} utils.ErrorResponseStruct
+// @Failure 500 {object} utils.ErrorResponseStruct
+// @Failure 503 {object} utils.ErrorResponseStruct
+// @Router /data/user/_trash/{document}/restore [post]
+func (h *UserDataHandler) RestoreUserTrash(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return serviceErrorResponse(c, err, "data.authorization.user", "")
+	}
+
+	document := c.Params("document")
+
+	collection, err := parseRestoreCollection(c)
+	if err != nil {
+		return utils.ErrorResponse(c, "Invalid input", fiber.StatusBadRequest, "data.validation.input")
+	}
+
+	newVersion, affectedRows, err := h.writer(c).RestoreUserTrash(c.UserContext(), userID, document, collection, services.WriteOptions{Actor: userID})
+	if err != nil {
+		return serviceErrorResponse(c, err, "restoreUserTrash", fmt.Sprintf("Document '%s' not found in trash", document))
 	}
 
 	return utils.MutationSuccessResponse(c, newVersion, affectedRows)
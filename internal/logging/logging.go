@@ -0,0 +1,129 @@
+// logging.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// levels maps LOG_LEVEL values to slog levels
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// Level returns the slog level of a LOG_LEVEL value, info when unknown
+func Level(name string) slog.Level {
+	if level, ok := levels[name]; ok {
+		return level
+	}
+	return slog.LevelInfo
+}
+
+// Setup installs the service logger configured by LOG_LEVEL and LOG_FORMAT as the slog default,
+// which also routes the standard log package through it
+func Setup(cfg *config.Config) *slog.Logger {
+	logger := New(os.Stdout, Level(cfg.LogLevel), cfg.LogFormat)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New creates a logger writing json or text lines at level and above to w, with secrets redacted and
+// the request, user and trace IDs of the record context added
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{Handler: handler})
+}
+
+// requestFields are the IDs added to the log lines of a request, the user is known after authorization
+type requestFields struct {
+	requestID string
+	userID    string
+}
+
+// fieldsKey is the context key of the request fields
+type fieldsKey struct{}
+
+// WithRequest returns ctx carrying the request ID for the log lines written with it
+func WithRequest(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &requestFields{requestID: requestID})
+}
+
+// SetUser adds the authenticated user ID to the log lines of the ctx request
+func SetUser(ctx context.Context, userID string) {
+	if fields, ok := ctx.Value(fieldsKey{}).(*requestFields); ok {
+		fields.userID = userID
+	}
+}
+
+// RequestID returns the request ID carried by ctx, "" outside a request
+func RequestID(ctx context.Context) string {
+	if fields, ok := ctx.Value(fieldsKey{}).(*requestFields); ok {
+		return fields.requestID
+	}
+	return ""
+}
+
+// contextHandler adds the request, user and trace IDs of the record context and redacts the message
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context IDs to the record and passes it on
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.Message = redactText(r.Message)
+	if ctx != nil {
+		if fields, ok := ctx.Value(fieldsKey{}).(*requestFields); ok {
+			r.AddAttrs(slog.String("request_id", fields.requestID))
+			if fields.userID != "" {
+				r.AddAttrs(slog.String("user_id", fields.userID))
+			}
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the context handler around the handler with attrs
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handler around the handler with a group
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,108 @@
+// redact.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package logging
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in log lines
+const Redacted = "[REDACTED]"
+
+// secretKeys are key fragments of attributes whose values are always redacted
+var secretKeys = []string{"password", "secret", "token", "cookie", "authorization", "session"}
+
+// credentialPatterns match credentials embedded in text such as DSNs and connection URLs,
+// the first group is kept
+var credentialPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`([a-zA-Z][\w+.-]*://[^\s:/@]*:)[^\s@/]+@`),
+	regexp.MustCompile(`(^|[\s"'(])([^\s:/@"'(]+:)[^\s@/]+@tcp\(`),
+	regexp.MustCompile(`(?i)(password=)[^\s&;]+`),
+}
+
+// Redact replaces each non-empty secret in s, also in its URL-escaped and unescaped forms
+func Redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		forms := []string{secret, url.QueryEscape(secret)}
+		if unescaped, err := url.PathUnescape(secret); err == nil {
+			forms = append(forms, unescaped, strings.ReplaceAll(unescaped, " ", "+"))
+		}
+		for _, form := range forms {
+			if form != "" {
+				s = strings.ReplaceAll(s, form, Redacted)
+			}
+		}
+	}
+	return s
+}
+
+// RedactError returns err with the secrets replaced in its message, still unwrapping to err
+func RedactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err, message: Redact(err.Error(), secrets...)}
+}
+
+// redactedError is an error whose message has secrets removed
+type redactedError struct {
+	err     error
+	message string
+}
+
+// Error returns the redacted message
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the original error
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactText removes embedded credentials from text
+func redactText(s string) string {
+	s = credentialPatterns[0].ReplaceAllString(s, "${1}"+Redacted+"@")
+	s = credentialPatterns[1].ReplaceAllString(s, "${1}${2}"+Redacted+"@tcp(")
+	return credentialPatterns[2].ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// redactAttr replaces the values of secret attributes and removes credentials from text values
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactText(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redactText(err.Error()))
+		}
+	}
+	return a
+}
@@ -24,36 +24,37 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/logging"
 	"github.com/localnerve/jam-build-propsdb/internal/services"
 	"github.com/localnerve/jam-build-propsdb/internal/types"
 )
 
-// AuthAdmin validates that the request has admin role authorization
-func AuthAdmin() fiber.Handler {
+// AuthAdmin validates that the request has admin role authorization,
+// over a connection with a client certificate verified by TLS_CLIENT_CA when it is set
+func AuthAdmin(cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		return authorize(c, []string{"admin"}, "data.authorization.admin")
+		if cfg.TLSClientCA != "" && !verifiedClient(c) {
+			return &types.CustomError{
+				Code:    fiber.StatusForbidden,
+				Message: "A verified client certificate is required",
+				Type:    "data.authorization.admin",
+			}
+		}
+		return authorize(c, cfg, []string{"admin"}, "data.authorization.admin")
 	}
 }
 
 // AuthUser validates that the request has user role authorization
-func AuthUser() fiber.Handler {
+func AuthUser(cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		return authorize(c, []string{"user"}, "data.authorization.user")
+		return authorize(c, cfg, []string{"user"}, "data.authorization.user")
 	}
 }
 
 // authorize performs the authorization check
-func authorize(c *fiber.Ctx, roles []string, errorType string) error {
+func authorize(c *fiber.Ctx, cfg *config.Config, roles []string, errorType string) error {
 	// Lazy initialization of Authorizer client
 	if !services.IsAuthorizerInitialized() {
-		cfg, err := config.Load()
-		if err != nil {
-			return &types.CustomError{
-				Code:    fiber.StatusInternalServerError,
-				Message: fmt.Sprintf("Failed to load config for authorizer: %v", err),
-				Type:    errorType,
-			}
-		}
 		if err := services.InitAuthorizer(cfg, c.Protocol(), c.Hostname()); err != nil {
 			return &types.CustomError{
 				Code:    fiber.StatusInternalServerError,
@@ -74,7 +75,7 @@ func authorize(c *fiber.Ctx, roles []string, errorType string) error {
 	}
 
 	// Validate session
-	data, err := services.ValidateSession(session, roles)
+	data, err := services.ValidateSession(c.UserContext(), session, roles)
 	if err != nil {
 		return &types.CustomError{
 			Code:    fiber.StatusForbidden,
@@ -86,7 +87,14 @@ func authorize(c *fiber.Ctx, roles []string, errorType string) error {
 	// Set user data in context
 	if user, ok := data["user"]; ok {
 		c.Locals("user", user)
+		logging.SetUser(c.UserContext(), requestUserID(c))
 	}
 
 	return c.Next()
 }
+
+// verifiedClient reports whether the request's TLS connection presented a verified client certificate
+func verifiedClient(c *fiber.Ctx) bool {
+	state := c.Context().TLSConnectionState()
+	return state != nil && len(state.VerifiedChains) > 0
+}
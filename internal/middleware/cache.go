@@ -0,0 +1,222 @@
+// cache.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/cache"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "propsdb_cache_hits_total",
+		Help: "Number of responses served from the response cache",
+	}, []string{"cache"})
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "propsdb_cache_misses_total",
+		Help: "Number of cacheable requests not found in the response cache",
+	}, []string{"cache"})
+)
+
+// AppCacheConfig configures the app data response cache
+type AppCacheConfig struct {
+	Cache  cache.Cache
+	TTL    time.Duration
+	MaxAge int // Cache-Control max-age in seconds, for clients and CDNs
+}
+
+// cachedResponse is the stored form of a GET response
+type cachedResponse struct {
+	Status      int        `json:"status"`
+	ContentType string     `json:"contentType,omitempty"`
+	ETag        string     `json:"etag,omitempty"`
+	Body        []byte     `json:"body,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"` // The earliest expiry of the data in Body
+}
+
+// AppCache serves app data GETs from a response cache, keyed by route, collections, fields and API version.
+// The whole cache is purged when any app mutation commits, and a response holding expiring data is cached
+// no longer than its earliest expiry. Other methods pass through.
+func AppCache(cfg AppCacheConfig) fiber.Handler {
+	// generation changes on every purge here, so responses read before a commit are not stored after it.
+	// A shared cache also pins its own generation for purges by other instances, see readAppCache.
+	var generation atomic.Uint64
+	services.OnMutation(func(event services.MutationEvent) {
+		if event.Scope != services.ScopeApp {
+			return
+		}
+		generation.Add(1)
+		if err := cfg.Cache.Purge(context.Background()); err != nil {
+			slog.Error("Failed to purge app cache", "error", err)
+		}
+	})
+
+	// cacheControl caps max-age at the earliest expiry of the response data
+	cacheControl := func(expiresAt *time.Time) string {
+		maxAge := cfg.MaxAge
+		if expiresAt != nil {
+			maxAge = min(maxAge, max(0, int(time.Until(*expiresAt)/time.Second)))
+		}
+		return fmt.Sprintf("public, max-age=%d, must-revalidate", maxAge)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		key := appCacheKey(c)
+
+		data, ok, store, err := readAppCache(ctx, cfg, key)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read app cache", "error", err)
+		}
+		var entry cachedResponse
+		if ok && json.Unmarshal(data, &entry) == nil {
+			cacheHits.WithLabelValues("app").Inc()
+			c.Set("X-Cache", "HIT")
+			c.Set(fiber.HeaderCacheControl, cacheControl(entry.ExpiresAt))
+			if entry.ETag != "" {
+				c.Set(fiber.HeaderETag, entry.ETag)
+				if utils.ETagMatches(c.Get(fiber.HeaderIfNoneMatch), entry.ETag) {
+					return c.SendStatus(fiber.StatusNotModified)
+				}
+			}
+			if entry.ContentType != "" {
+				c.Set(fiber.HeaderContentType, entry.ContentType)
+			}
+			return c.Status(entry.Status).Send(entry.Body)
+		}
+		cacheMisses.WithLabelValues("app").Inc()
+
+		start := generation.Load()
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		var expiresAt *time.Time
+		if earliest, ok := c.Locals("expiresAt").(time.Time); ok {
+			expiresAt = &earliest
+		}
+
+		status := c.Response().StatusCode()
+		switch status {
+		case fiber.StatusOK, fiber.StatusNoContent, fiber.StatusNotModified:
+			c.Set("X-Cache", "MISS")
+			c.Set(fiber.HeaderCacheControl, cacheControl(expiresAt))
+		default:
+			return nil
+		}
+
+		// A 304 has no body to store, the next unconditional request will fill the entry
+		if store == nil || status == fiber.StatusNotModified || generation.Load() != start {
+			return nil
+		}
+
+		ttl := cfg.TTL
+		if expiresAt != nil {
+			remaining := time.Until(*expiresAt)
+			if remaining <= 0 {
+				return nil
+			}
+			if ttl <= 0 || remaining < ttl {
+				ttl = remaining
+			}
+		}
+
+		data, err = json.Marshal(cachedResponse{
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			ETag:        c.GetRespHeader(fiber.HeaderETag),
+			Body:        c.Response().Body(),
+			ExpiresAt:   expiresAt,
+		})
+		if err == nil {
+			err = store(data, ttl)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to write app cache", "error", err)
+		}
+
+		return nil
+	}
+}
+
+// readAppCache reads key and returns the store for its response, kept for the ttl given. A shared cache is read and written in one
+// generation, so a response read before another instance's purge is not served after it.
+func readAppCache(ctx context.Context, cfg AppCacheConfig, key string) ([]byte, bool, func([]byte, time.Duration) error, error) {
+	shared, ok := cfg.Cache.(cache.Generational)
+	if !ok {
+		data, found, err := cfg.Cache.Get(ctx, key)
+		return data, found, func(value []byte, ttl time.Duration) error { return cfg.Cache.Set(ctx, key, value, ttl) }, err
+	}
+
+	generation, err := shared.Generation(ctx)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	data, found, err := shared.GetIn(ctx, generation, key)
+	return data, found, func(value []byte, ttl time.Duration) error { return shared.SetIn(ctx, generation, key, value, ttl) }, err
+}
+
+// appCacheKey builds the cache key from the API version, path, and the query parameters that shape the result
+func appCacheKey(c *fiber.Ctx) string {
+	version, _ := c.Locals("apiVersion").(string)
+	if version == "" {
+		version = c.Get("X-Api-Version", "1.0.0")
+	}
+
+	return "app:" + version + ":" + c.Path() +
+		"?collections=" + sortedQueryValues(c, "collections") +
+		"&fields=" + sortedQueryValues(c, "fields") +
+		"&include=" + sortedQueryValues(c, "include")
+}
+
+// sortedQueryValues normalizes a repeatable, comma-separated query parameter
+func sortedQueryValues(c *fiber.Ctx, name string) string {
+	var values []string
+	for key, value := range c.Context().QueryArgs().All() {
+		if string(key) != name {
+			continue
+		}
+		for _, v := range strings.Split(string(value), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
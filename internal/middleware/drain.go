@@ -0,0 +1,118 @@
+// drain.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/types"
+)
+
+// Drainer tracks the requests in flight for graceful shutdown. Each request's context is derived from the
+// drainer's, so the queries and transactions of requests still running when the drain timeout expires are canceled.
+// Once draining starts, requests still arriving on kept-alive connections answer 503 and close their connection.
+type Drainer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	active   int
+	draining bool
+	idle     chan struct{} // closed when draining and nothing is in flight
+}
+
+// NewDrainer creates a Drainer
+func NewDrainer() *Drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Drainer{ctx: ctx, cancel: cancel, idle: make(chan struct{})}
+}
+
+// Handler is mounted first, so every later middleware and handler derives its context from the request's
+func (d *Drainer) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !d.enter() {
+			c.Context().SetConnectionClose()
+			return &types.CustomError{
+				Code:    fiber.StatusServiceUnavailable,
+				Message: "server is shutting down",
+				Type:    "data.unavailable",
+			}
+		}
+		defer d.leave()
+
+		ctx, cancel := context.WithCancel(d.ctx)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
+
+// enter counts a request in flight, and reports false once draining has started
+func (d *Drainer) enter() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+	d.active++
+	return true
+}
+
+// leave counts a finished request, signaling idle when it was the last one while draining
+func (d *Drainer) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.active--
+	if d.draining && d.active == 0 {
+		close(d.idle)
+	}
+}
+
+// Drain stops admitting requests and waits for those in flight until ctx is done, then cancels their contexts
+// and waits up to grace for them to return. It reports whether every request finished before ctx was done.
+func (d *Drainer) Drain(ctx context.Context, grace time.Duration) bool {
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		if d.active == 0 {
+			close(d.idle)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+		return true
+	case <-ctx.Done():
+	}
+
+	d.cancel()
+	select {
+	case <-d.idle:
+	case <-time.After(grace):
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+// idempotency.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/authorizer-go"
+	"github.com/localnerve/jam-build-propsdb/internal/cache"
+	"github.com/localnerve/jam-build-propsdb/internal/utils"
+)
+
+// HeaderIdempotencyKey identifies retries of the same mutation
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the client supplied key
+const maxIdempotencyKeyLength = 255
+
+// releaseTimeout bounds releasing a key, which runs even when the request context is done
+const releaseTimeout = 5 * time.Second
+
+// IdempotencyConfig configures the mutation idempotency middleware
+type IdempotencyConfig struct {
+	Cache   cache.Cache
+	TTL     time.Duration // how long a completed response replays
+	LockTTL time.Duration // how long a request in progress holds its key, default one minute
+}
+
+// idempotencyRecord is the stored state of a keyed request, pending until its response completes
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Pending     bool   `json:"pending,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency makes mutations carrying an Idempotency-Key header safe to retry.
+// The first successful response is stored per user and key for cfg.TTL, and replayed to retries
+// with the same method, URL, If-Match and body instead of executing them again. A retry with a different
+// request gets 422, and a retry while the first request is still running gets 409.
+// Failed requests are not stored, so they can be retried with the same key. GETs pass through.
+func Idempotency(cfg IdempotencyConfig) fiber.Handler {
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Minute
+	}
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return utils.ErrorResponse(c, "Idempotency-Key must be at most 255 characters", fiber.StatusBadRequest, "data.validation.input")
+		}
+
+		ctx := c.UserContext()
+		cacheKey := "idempotency:" + requestUserID(c) + ":" + key
+		fingerprint := requestFingerprint(c)
+
+		pending, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, Pending: true})
+		if err != nil {
+			return err
+		}
+
+		claimed, err := cfg.Cache.Add(ctx, cacheKey, pending, cfg.LockTTL)
+		if err != nil {
+			// Without the store, run the request unprotected rather than fail it
+			slog.ErrorContext(ctx, "Failed to claim idempotency key", "error", err)
+			return c.Next()
+		}
+		if !claimed {
+			return replayIdempotent(c, cfg.Cache, cacheKey, fingerprint)
+		}
+
+		if err := c.Next(); err != nil {
+			releaseIdempotencyKey(c, cfg.Cache, cacheKey)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status < fiber.StatusOK || status >= fiber.StatusMultipleChoices {
+			releaseIdempotencyKey(c, cfg.Cache, cacheKey)
+			return nil
+		}
+
+		data, err := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        c.Response().Body(),
+		})
+		if err == nil {
+			err = cfg.Cache.Set(ctx, cacheKey, data, cfg.TTL)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to store idempotent response", "error", err)
+			releaseIdempotencyKey(c, cfg.Cache, cacheKey)
+		}
+
+		return nil
+	}
+}
+
+// replayIdempotent answers a request whose key is already claimed, from the stored record
+func replayIdempotent(c *fiber.Ctx, store cache.Cache, cacheKey, fingerprint string) error {
+	data, ok, err := store.Get(c.UserContext(), cacheKey)
+	if err != nil {
+		return err
+	}
+
+	var record idempotencyRecord
+	if ok {
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		if record.Fingerprint != fingerprint {
+			return utils.ErrorResponse(c, "Idempotency-Key was already used for a different request", fiber.StatusUnprocessableEntity, "data.idempotency")
+		}
+	}
+
+	// Missing means the first request failed and released the key after this request tried to claim it
+	if !ok || record.Pending {
+		return utils.ErrorResponse(c, "A request with this Idempotency-Key is in progress, retry later", fiber.StatusConflict, "data.idempotency")
+	}
+
+	c.Set("Idempotent-Replayed", "true")
+	if record.ContentType != "" {
+		c.Set(fiber.HeaderContentType, record.ContentType)
+	}
+	return c.Status(record.Status).Send(record.Body)
+}
+
+// releaseIdempotencyKey frees a key whose request did not succeed.
+// A canceled or timed out request must still release, so this does not use the request context.
+func releaseIdempotencyKey(c *fiber.Ctx, store cache.Cache, cacheKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	if err := store.Delete(ctx, cacheKey); err != nil {
+		slog.ErrorContext(c.UserContext(), "Failed to release idempotency key", "error", err)
+	}
+}
+
+// requestFingerprint hashes what makes a mutation the same request: method, URL, If-Match and body
+func requestFingerprint(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.OriginalURL()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.Get(fiber.HeaderIfMatch)))
+	hash.Write([]byte{0})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// requestUserID returns the authenticated user's ID, or "" before authentication
+func requestUserID(c *fiber.Ctx) string {
+	switch user := c.Locals("user").(type) {
+	case *authorizer.User:
+		return user.ID
+	case map[string]interface{}:
+		id, _ := user["id"].(string)
+		return id
+	}
+	return ""
+}
@@ -25,9 +25,10 @@ import (
 
 // ApplicationDocument represents a document in the application scope
 type ApplicationDocument struct {
-	DocumentID      uint64 `gorm:"primaryKey;autoIncrement"`
-	DocumentName    string `gorm:"uniqueIndex;size:255;not null"`
-	DocumentVersion uint64 `gorm:"not null;default:0"`
+	DocumentID      uint64     `gorm:"primaryKey;autoIncrement"`
+	DocumentName    string     `gorm:"uniqueIndex;size:255;not null"`
+	DocumentVersion uint64     `gorm:"not null;default:0"`
+	ExpiresAt       *time.Time `gorm:"index"` // Optional, the document is removed once passed
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	Collections     []ApplicationCollection `gorm:"many2many:application_documents_collections;joinForeignKey:document_id;joinReferences:collection_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -35,8 +36,9 @@ type ApplicationDocument struct {
 
 // ApplicationCollection represents a collection of properties
 type ApplicationCollection struct {
-	CollectionID   uint64 `gorm:"primaryKey;autoIncrement"`
-	CollectionName string `gorm:"size:255;not null"`
+	CollectionID   uint64     `gorm:"primaryKey;autoIncrement"`
+	CollectionName string     `gorm:"size:255;not null"`
+	ExpiresAt      *time.Time `gorm:"index"` // Optional, the collection is removed once passed
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Properties     []ApplicationProperty `gorm:"many2many:application_collections_properties;joinForeignKey:collection_id;joinReferences:property_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -44,11 +46,14 @@ type ApplicationCollection struct {
 
 // ApplicationProperty represents a single property with a JSON value
 type ApplicationProperty struct {
-	PropertyID    uint64 `gorm:"primaryKey;autoIncrement"`
-	PropertyName  string `gorm:"size:255;not null"`
-	PropertyValue JSON
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	PropertyID      uint64 `gorm:"primaryKey;autoIncrement"`
+	PropertyName    string `gorm:"size:255;not null"`
+	PropertyValue   JSON
+	PropertyVersion uint64     `gorm:"not null;default:0"`           // Document version of the last change
+	ModifiedBy      string     `gorm:"size:255;not null;default:''"` // Actor of the last change
+	ExpiresAt       *time.Time `gorm:"index"`                        // Optional, the property is removed once passed
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 // TableName overrides the table name for ApplicationDocument
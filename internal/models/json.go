@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql/driver"
+	"strconv"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -18,8 +19,17 @@ func (j JSON) Value() (driver.Value, error) {
 	return j.JSON.Value()
 }
 
-// Scan promotes the embedded JSON's Scan method
+// Scan promotes the embedded JSON's Scan method.
+// SQLite stores a bare JSON number in a JSON column as a numeric value, so numbers are accepted too.
 func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		j.JSON = datatypes.JSON(strconv.FormatInt(v, 10))
+		return nil
+	case float64:
+		j.JSON = datatypes.JSON(strconv.FormatFloat(v, 'g', -1, 64))
+		return nil
+	}
 	return j.JSON.Scan(value)
 }
 
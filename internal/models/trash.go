@@ -0,0 +1,57 @@
+// trash.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package models
+
+import (
+	"time"
+)
+
+// ApplicationTrash is a deleted application document or collection, kept for restore until purged
+type ApplicationTrash struct {
+	TrashID         uint64    `gorm:"primaryKey;autoIncrement"`
+	DocumentName    string    `gorm:"size:255;not null;index"`
+	CollectionName  string    `gorm:"size:255;not null;default:''"` // Empty when the whole document was deleted
+	DocumentVersion uint64    `gorm:"not null;default:0"`           // Document version at deletion
+	Content         JSON      // Deleted collections and properties, { collection: { property: value }}
+	DeletedBy       string    `gorm:"size:255;not null;default:''"`
+	DeletedAt       time.Time `gorm:"not null;index"`
+}
+
+// UserTrash is a deleted user document or collection, kept for restore until purged
+type UserTrash struct {
+	TrashID         uint64    `gorm:"primaryKey;autoIncrement"`
+	UserID          string    `gorm:"type:char(36);not null;index:idx_user_trash"`
+	DocumentName    string    `gorm:"size:255;not null;index:idx_user_trash"`
+	CollectionName  string    `gorm:"size:255;not null;default:''"` // Empty when the whole document was deleted
+	DocumentVersion uint64    `gorm:"not null;default:0"`           // Document version at deletion
+	Content         JSON      // Deleted collections and properties, { collection: { property: value }}
+	DeletedBy       string    `gorm:"size:255;not null;default:''"`
+	DeletedAt       time.Time `gorm:"not null;index"`
+}
+
+// TableName overrides the table name for ApplicationTrash
+func (ApplicationTrash) TableName() string {
+	return "application_trash"
+}
+
+// TableName overrides the table name for UserTrash
+func (UserTrash) TableName() string {
+	return "user_trash"
+}
@@ -25,10 +25,11 @@ import (
 
 // UserDocument represents a document in the user scope
 type UserDocument struct {
-	DocumentID      uint64 `gorm:"primaryKey;autoIncrement"`
-	UserID          string `gorm:"type:char(36);not null;index:idx_user_document,unique"`
-	DocumentName    string `gorm:"size:255;not null;index:idx_user_document,unique"`
-	DocumentVersion uint64 `gorm:"not null;default:0"`
+	DocumentID      uint64     `gorm:"primaryKey;autoIncrement"`
+	UserID          string     `gorm:"type:char(36);not null;index:idx_user_document,unique"`
+	DocumentName    string     `gorm:"size:255;not null;index:idx_user_document,unique"`
+	DocumentVersion uint64     `gorm:"not null;default:0"`
+	ExpiresAt       *time.Time `gorm:"index"` // Optional, the document is removed once passed
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	Collections     []UserCollection `gorm:"many2many:user_documents_collections;joinForeignKey:document_id;joinReferences:collection_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -36,8 +37,9 @@ type UserDocument struct {
 
 // UserCollection represents a collection of properties for users
 type UserCollection struct {
-	CollectionID   uint64 `gorm:"primaryKey;autoIncrement"`
-	CollectionName string `gorm:"size:255;not null"`
+	CollectionID   uint64     `gorm:"primaryKey;autoIncrement"`
+	CollectionName string     `gorm:"size:255;not null"`
+	ExpiresAt      *time.Time `gorm:"index"` // Optional, the collection is removed once passed
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Properties     []UserProperty `gorm:"many2many:user_collections_properties;joinForeignKey:collection_id;joinReferences:property_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -45,11 +47,14 @@ type UserCollection struct {
 
 // UserProperty represents a single property with a JSON value for users
 type UserProperty struct {
-	PropertyID    uint64 `gorm:"primaryKey;autoIncrement"`
-	PropertyName  string `gorm:"size:255;not null"`
-	PropertyValue JSON
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	PropertyID      uint64 `gorm:"primaryKey;autoIncrement"`
+	PropertyName    string `gorm:"size:255;not null"`
+	PropertyValue   JSON
+	PropertyVersion uint64     `gorm:"not null;default:0"`           // Document version of the last change
+	ModifiedBy      string     `gorm:"size:255;not null;default:''"` // Actor of the last change
+	ExpiresAt       *time.Time `gorm:"index"`                        // Optional, the property is removed once passed
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 // TableName overrides the table name for UserDocument
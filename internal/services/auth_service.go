@@ -20,15 +20,22 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/localnerve/authorizer-go"
 	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/logging"
+	"github.com/localnerve/jam-build-propsdb/internal/telemetry"
 	"github.com/localnerve/jam-build-propsdb/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -53,8 +60,8 @@ func InitAuthorizer(cfg *config.Config, requestProtocol, requestHost string) err
 		}
 
 		redirectURL := fmt.Sprintf("%s://%s", requestProtocol, requestHost)
-		log.Printf("Initializing Authorizer: authorizerURL=%s, clientID=%s, redirectURL=%s",
-			cfg.AuthzURL, cfg.AuthzClientID, redirectURL)
+		slog.Info("Initializing Authorizer", "authorizer_url", cfg.AuthzURL, "client_id", cfg.AuthzClientID,
+			"redirect_url", redirectURL)
 
 		var err error
 		authClient, err = authorizer.NewAuthorizerClient(cfg.AuthzClientID, cfg.AuthzURL, redirectURL, nil)
@@ -67,8 +74,36 @@ func InitAuthorizer(cfg *config.Config, requestProtocol, requestHost string) err
 	return initErr
 }
 
-// ValidateSession validates a session cookie or JWT for the given roles
-func ValidateSession(token string, roles []string) (map[string]interface{}, error) {
+// ValidateSession validates a session cookie or JWT for the given roles, in a span of the ctx trace
+func ValidateSession(ctx context.Context, token string, roles []string) (map[string]interface{}, error) {
+	_, span := telemetry.Tracer().Start(ctx, "authorizer.ValidateSession",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.StringSlice("propsdb.auth.roles", roles)),
+	)
+	defer span.End()
+
+	method := "session"
+	if strings.Contains(token, ".") {
+		method = "jwt"
+	}
+
+	start := time.Now()
+	data, err := validateSession(token, roles)
+	outcome := "valid"
+	if err != nil {
+		// Authorizer errors can echo the token, keep it out of responses, spans and logs
+		err = logging.RedactError(err, token)
+		outcome = "invalid"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	authValidationDuration.WithLabelValues(method, outcome).Observe(time.Since(start).Seconds())
+
+	return data, err
+}
+
+// validateSession validates a session cookie or JWT with the Authorizer client
+func validateSession(token string, roles []string) (map[string]interface{}, error) {
 	if authClient == nil {
 		return nil, fmt.Errorf("authorizer client not initialized")
 	}
@@ -0,0 +1,236 @@
+// data_copy.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// validateTargetDocument checks the name of a document to create from another
+func validateTargetDocument(documentName, targetName string) error {
+	if targetName == "" {
+		return fmt.Errorf("%w: target document name is required", ErrValidation)
+	}
+	if targetName == documentName {
+		return fmt.Errorf("%w: target document must differ from the source", ErrValidation)
+	}
+	return nil
+}
+
+// claimApplicationDocument fails with ErrExists if an application document is named documentName,
+// removing an expired one so the name can be reused
+func claimApplicationDocument(tx *gorm.DB, documentName string, now time.Time) error {
+	var doc models.ApplicationDocument
+	err := lockingQuery(tx, ScopeApp).
+		Where("document_name = ?", documentName).
+		First(&doc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !expired(doc.ExpiresAt, now) {
+		return fmt.Errorf("document %s %w", documentName, ErrExists)
+	}
+	if err := tx.Delete(&doc).Error; err != nil {
+		return err
+	}
+	recordChanges(tx, entityDocument, actionDeleted, 1)
+	return cleanupApplicationOrphans(tx)
+}
+
+// claimUserDocument fails with ErrExists if a user has a document named documentName,
+// removing an expired one so the name can be reused
+func claimUserDocument(tx *gorm.DB, userID, documentName string, now time.Time) error {
+	var doc models.UserDocument
+	err := lockingQuery(tx, ScopeUser).
+		Where("user_id = ? AND document_name = ?", userID, documentName).
+		First(&doc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !expired(doc.ExpiresAt, now) {
+		return fmt.Errorf("document %s %w", documentName, ErrExists)
+	}
+	if err := tx.Delete(&doc).Error; err != nil {
+		return err
+	}
+	recordChanges(tx, entityDocument, actionDeleted, 1)
+	return cleanupUserOrphans(tx)
+}
+
+// CopyApplicationDocument copies the collections and properties of an application document at version
+// to new collections of a new document, returning the new document's version. Expiry is not copied.
+func CopyApplicationDocument(ctx context.Context, db *gorm.DB, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	if err := validateTargetDocument(documentName, targetName); err != nil {
+		return 0, 0, err
+	}
+
+	var newVersion uint64
+	var affectedRows int64
+
+	now := time.Now().UTC()
+	err := transaction(db, ScopeApp, "copy", func(tx *gorm.DB) error {
+		// Lock and check version
+		var doc models.ApplicationDocument
+		if err := lockingQuery(tx, ScopeApp).
+			Where("document_name = ?", documentName).
+			Where(notExpired, now).
+			First(&doc).Error; err != nil {
+			return documentLookupError(err, opts)
+		}
+
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
+		}
+
+		if err := claimApplicationDocument(tx, targetName, now); err != nil {
+			return err
+		}
+
+		content, err := loadApplicationContent(tx, doc.DocumentID, "", now)
+		if err != nil {
+			return err
+		}
+
+		// The copy gets collections of its own, so writing to it leaves the source alone
+		newVersion, affectedRows, err = setApplicationProperties(tx, targetName, 0, content.collectionInputs(), WriteOptions{Actor: opts.Actor}, false)
+		return err
+	})
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: targetName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// RenameApplicationDocument renames an application document at version, returning its bumped version
+func RenameApplicationDocument(ctx context.Context, db *gorm.DB, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	if err := validateTargetDocument(documentName, targetName); err != nil {
+		return 0, 0, err
+	}
+
+	var newVersion uint64
+	var affectedRows int64
+
+	now := time.Now().UTC()
+	err := transaction(db, ScopeApp, "rename", func(tx *gorm.DB) error {
+		// Lock and check version
+		var doc models.ApplicationDocument
+		if err := lockingQuery(tx, ScopeApp).
+			Where("document_name = ?", documentName).
+			Where(notExpired, now).
+			First(&doc).Error; err != nil {
+			return documentLookupError(err, opts)
+		}
+
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
+		}
+
+		if err := claimApplicationDocument(tx, targetName, now); err != nil {
+			return err
+		}
+
+		// Expired collections and properties are not carried over
+		if _, err := pruneExpired(tx, "application", doc.DocumentID, now); err != nil {
+			return err
+		}
+
+		newVersion = doc.DocumentVersion + 1
+		result := tx.Model(&doc).Where("document_version = ?", doc.DocumentVersion).
+			Updates(map[string]interface{}{"document_name": targetName, "document_version": newVersion})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errConcurrentModification
+		}
+		affectedRows = result.RowsAffected
+		recordChanges(tx, entityDocument, actionUpdated, affectedRows)
+
+		return nil
+	})
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName})
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: targetName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// CreateUserDocumentFromTemplate creates a user document with the collections and properties of an
+// application document, returning the new document's version. Existing user documents are never overwritten.
+func CreateUserDocumentFromTemplate(ctx context.Context, db *gorm.DB, userID, documentName, templateName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	if documentName == "" {
+		return 0, 0, fmt.Errorf("%w: document name is required", ErrValidation)
+	}
+
+	var newVersion uint64
+	var affectedRows int64
+
+	now := time.Now().UTC()
+	err := transaction(db, ScopeUser, "template", func(tx *gorm.DB) error {
+		var template models.ApplicationDocument
+		if err := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+			Where("document_name = ?", templateName).
+			Where(notExpired, now).
+			First(&template).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("template %w", ErrNotFound)
+			}
+			return err
+		}
+
+		if err := claimUserDocument(tx, userID, documentName, now); err != nil {
+			return err
+		}
+
+		content, err := loadApplicationContent(tx, template.DocumentID, "", now)
+		if err != nil {
+			return err
+		}
+
+		newVersion, affectedRows, err = setUserProperties(tx, userID, documentName, 0, content.collectionInputs(), WriteOptions{Actor: opts.Actor})
+		return err
+	})
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
@@ -20,29 +20,39 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/localnerve/jam-build-propsdb/internal/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// DeleteApplicationCollection deletes a collection from an application document
-func DeleteApplicationCollection(db *gorm.DB, documentName string, version uint64, collectionName string) (uint64, int64, error) {
+// DeleteApplicationCollection deletes a collection from an application document, keeping a copy in the trash
+func DeleteApplicationCollection(ctx context.Context, db *gorm.DB, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
 	var newVersion uint64
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+	now := time.Now().UTC()
+	err := transaction(db, ScopeApp, "delete_collection", func(tx *gorm.DB) error {
 		// Lock and check version
 		var doc models.ApplicationDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+		if err := lockingQuery(tx, ScopeApp).
 			Where("document_name = ?", documentName).
+			Where(notExpired, now).
 			First(&doc).Error; err != nil {
-			return err
+			return documentLookupError(err, opts)
+		}
+
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
 		}
 
-		if doc.DocumentVersion != version {
-			return fmt.Errorf("E_VERSION")
+		// Expired collections and properties are removed first, so they are neither found nor trashed
+		if _, err := pruneExpired(tx, "application", doc.DocumentID, now); err != nil {
+			return err
 		}
 
 		// Find collection associated with this document
@@ -51,19 +61,25 @@ func DeleteApplicationCollection(db *gorm.DB, documentName string, version uint6
 			Model(&doc).Where("collection_name = ?", collectionName).Association("Collections").Find(&collection)
 
 		if err != nil {
-			return fmt.Errorf("collection not found: %w", err) // GORM association find returns error? Usually nil if empty?
+			return err
 		}
 		// Check if it was actually found (GORM Find might not error on empty result for associations depending on usage,
 		// but Model Association Find usually fills struct or slice. If ID is 0, it wasn't validly found).
 		if collection.CollectionID == 0 {
 			// Try to find it explicitly to confirm error or just return not found
-			return fmt.Errorf("collection not found")
+			return fmt.Errorf("collection %w", ErrNotFound)
+		}
+
+		// Keep a copy in the trash for restore
+		if err := trashApplicationDocument(tx, doc, collectionName, opts.Actor); err != nil {
+			return err
 		}
 
 		// Remove association between document and collection using GORM
 		if err := tx.Model(&doc).Association("Collections").Delete(&collection); err != nil {
 			return err
 		}
+		recordChanges(tx, entityCollection, actionDeleted, 1)
 
 		// Check if collection is orphaned (not associated with any other documents)
 		var count int64
@@ -101,31 +117,49 @@ func DeleteApplicationCollection(db *gorm.DB, documentName string, version uint6
 			return result.Error
 		}
 		if result.RowsAffected == 0 {
-			return fmt.Errorf("E_VERSION - Failed to update document due to concurrent modification")
+			return errConcurrentModification
 		}
 		affectedRows = result.RowsAffected
+		recordChanges(tx, entityDocument, actionUpdated, affectedRows)
 
 		return nil
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
 	return newVersion, affectedRows, err
 }
 
-// DeleteApplicationDocument deletes an entire application document
-func DeleteApplicationDocument(db *gorm.DB, documentName string, version uint64) (uint64, int64, error) {
+// DeleteApplicationDocument deletes an entire application document, keeping a copy in the trash
+func DeleteApplicationDocument(ctx context.Context, db *gorm.DB, documentName string, version uint64, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+	now := time.Now().UTC()
+	err := transaction(db, ScopeApp, "delete_document", func(tx *gorm.DB) error {
 		// Lock and check version
 		var doc models.ApplicationDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+		if err := lockingQuery(tx, ScopeApp).
 			Where("document_name = ?", documentName).
+			Where(notExpired, now).
 			First(&doc).Error; err != nil {
+			return documentLookupError(err, opts)
+		}
+
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
+		}
+
+		// Expired collections and properties are removed first, so they are neither found nor trashed
+		if _, err := pruneExpired(tx, "application", doc.DocumentID, now); err != nil {
 			return err
 		}
 
-		if doc.DocumentVersion != version {
-			return fmt.Errorf("E_VERSION")
+		// Keep a copy in the trash for restore
+		if err := trashApplicationDocument(tx, doc, "", opts.Actor); err != nil {
+			return err
 		}
 
 		// Delete document (CASCADE will handle associations)
@@ -134,6 +168,7 @@ func DeleteApplicationDocument(db *gorm.DB, documentName string, version uint64)
 			return result.Error
 		}
 		affectedRows = result.RowsAffected
+		recordChanges(tx, entityDocument, actionDeleted, affectedRows)
 
 		// Cleanup orphaned collections and properties
 		if err := cleanupApplicationOrphans(tx); err != nil {
@@ -143,32 +178,43 @@ func DeleteApplicationDocument(db *gorm.DB, documentName string, version uint64)
 		return nil
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName})
+	}
+
 	return 0, affectedRows, err
 }
 
 // DeleteApplicationProperties deletes properties or collections from an application document
-func DeleteApplicationProperties(db *gorm.DB, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool) (uint64, int64, error) {
+func DeleteApplicationProperties(ctx context.Context, db *gorm.DB, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
 	if deleteDocument {
-		return DeleteApplicationDocument(db, documentName, version)
+		return DeleteApplicationDocument(ctx, db, documentName, version, opts)
 	}
 
 	var newVersion uint64
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+	now := time.Now().UTC()
+	err := transaction(db, ScopeApp, "delete_properties", func(tx *gorm.DB) error {
 		// Lock and check version
 		var doc models.ApplicationDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+		if err := lockingQuery(tx, ScopeApp).
 			Where("document_name = ?", documentName).
+			Where(notExpired, now).
 			First(&doc).Error; err != nil {
-			return err
+			return documentLookupError(err, opts)
 		}
 
-		if doc.DocumentVersion != version {
-			return fmt.Errorf("E_VERSION")
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
 		}
 
-		documentUpdated := false
+		// Expired collections and properties are removed too, covered by this delete's version bump
+		documentUpdated, err := pruneExpired(tx, "application", doc.DocumentID, now)
+		if err != nil {
+			return err
+		}
 
 		for _, coll := range collections {
 			// Find collection for this document
@@ -180,11 +226,15 @@ func DeleteApplicationProperties(db *gorm.DB, documentName string, version uint6
 				continue // Collection not found, skip
 			}
 
-			// If no properties specified, delete entire collection
+			// If no properties specified, delete entire collection, keeping a copy in the trash
 			if len(coll.Properties) == 0 {
+				if err := trashApplicationDocument(tx, doc, coll.Collection, opts.Actor); err != nil {
+					return err
+				}
 				if err := tx.Model(&doc).Association("Collections").Delete(&collection); err != nil {
 					return err
 				}
+				recordChanges(tx, entityCollection, actionDeleted, 1)
 				documentUpdated = true
 			} else {
 				// Delete specific properties
@@ -198,6 +248,7 @@ func DeleteApplicationProperties(db *gorm.DB, documentName string, version uint6
 						if err := tx.Model(&collection).Association("Properties").Delete(&property); err != nil {
 							return err
 						}
+						recordChanges(tx, entityProperty, actionDeleted, 1)
 						documentUpdated = true
 					}
 				}
@@ -218,9 +269,10 @@ func DeleteApplicationProperties(db *gorm.DB, documentName string, version uint6
 				return result.Error
 			}
 			if result.RowsAffected == 0 {
-				return fmt.Errorf("E_VERSION - Failed to update document due to concurrent modification")
+				return errConcurrentModification
 			}
 			affectedRows = result.RowsAffected
+			recordChanges(tx, entityDocument, actionUpdated, affectedRows)
 		} else {
 			newVersion = doc.DocumentVersion
 		}
@@ -228,24 +280,36 @@ func DeleteApplicationProperties(db *gorm.DB, documentName string, version uint6
 		return nil
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
 	return newVersion, affectedRows, err
 }
 
-// DeleteUserCollection deletes a collection from a user document
-func DeleteUserCollection(db *gorm.DB, userID, documentName string, version uint64, collectionName string) (uint64, int64, error) {
+// DeleteUserCollection deletes a collection from a user document, keeping a copy in the trash
+func DeleteUserCollection(ctx context.Context, db *gorm.DB, userID, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
 	var newVersion uint64
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+	now := time.Now().UTC()
+	err := transaction(db, ScopeUser, "delete_collection", func(tx *gorm.DB) error {
 		var doc models.UserDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+		if err := lockingQuery(tx, ScopeUser).
 			Where("user_id = ? AND document_name = ?", userID, documentName).
+			Where(notExpired, now).
 			First(&doc).Error; err != nil {
-			return err
+			return documentLookupError(err, opts)
+		}
+
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
 		}
 
-		if doc.DocumentVersion != version {
-			return fmt.Errorf("E_VERSION")
+		// Expired collections and properties are removed first, so they are neither found nor trashed
+		if _, err := pruneExpired(tx, "user", doc.DocumentID, now); err != nil {
+			return err
 		}
 
 		var collection models.UserCollection
@@ -253,15 +317,20 @@ func DeleteUserCollection(db *gorm.DB, userID, documentName string, version uint
 			Model(&doc).Where("collection_name = ?", collectionName).Association("Collections").Find(&collection)
 
 		if err != nil {
-			return fmt.Errorf("collection not found: %w", err)
+			return err
 		}
 		if collection.CollectionID == 0 {
-			return fmt.Errorf("collection not found")
+			return fmt.Errorf("collection %w", ErrNotFound)
+		}
+
+		if err := trashUserDocument(tx, doc, collectionName, opts.Actor); err != nil {
+			return err
 		}
 
 		if err := tx.Model(&doc).Association("Collections").Delete(&collection); err != nil {
 			return err
 		}
+		recordChanges(tx, entityCollection, actionDeleted, 1)
 
 		// Check if collection is orphaned
 		var count int64
@@ -292,30 +361,47 @@ func DeleteUserCollection(db *gorm.DB, userID, documentName string, version uint
 			return result.Error
 		}
 		if result.RowsAffected == 0 {
-			return fmt.Errorf("E_VERSION - Failed to update document due to concurrent modification")
+			return errConcurrentModification
 		}
 		affectedRows = result.RowsAffected
+		recordChanges(tx, entityDocument, actionUpdated, affectedRows)
 
 		return nil
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
 	return newVersion, affectedRows, err
 }
 
-// DeleteUserDocument deletes an entire user document
-func DeleteUserDocument(db *gorm.DB, userID, documentName string, version uint64) (uint64, int64, error) {
+// DeleteUserDocument deletes an entire user document, keeping a copy in the trash
+func DeleteUserDocument(ctx context.Context, db *gorm.DB, userID, documentName string, version uint64, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+	now := time.Now().UTC()
+	err := transaction(db, ScopeUser, "delete_document", func(tx *gorm.DB) error {
 		var doc models.UserDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+		if err := lockingQuery(tx, ScopeUser).
 			Where("user_id = ? AND document_name = ?", userID, documentName).
+			Where(notExpired, now).
 			First(&doc).Error; err != nil {
+			return documentLookupError(err, opts)
+		}
+
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
+		}
+
+		// Expired collections and properties are removed first, so they are neither found nor trashed
+		if _, err := pruneExpired(tx, "user", doc.DocumentID, now); err != nil {
 			return err
 		}
 
-		if doc.DocumentVersion != version {
-			return fmt.Errorf("E_VERSION")
+		if err := trashUserDocument(tx, doc, "", opts.Actor); err != nil {
+			return err
 		}
 
 		result := tx.Delete(&doc)
@@ -323,6 +409,7 @@ func DeleteUserDocument(db *gorm.DB, userID, documentName string, version uint64
 			return result.Error
 		}
 		affectedRows = result.RowsAffected
+		recordChanges(tx, entityDocument, actionDeleted, affectedRows)
 
 		if err := cleanupUserOrphans(tx); err != nil {
 			return err
@@ -331,31 +418,42 @@ func DeleteUserDocument(db *gorm.DB, userID, documentName string, version uint64
 		return nil
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName})
+	}
+
 	return 0, affectedRows, err
 }
 
 // DeleteUserProperties deletes properties or collections from a user document
-func DeleteUserProperties(db *gorm.DB, userID, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool) (uint64, int64, error) {
+func DeleteUserProperties(ctx context.Context, db *gorm.DB, userID, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
 	if deleteDocument {
-		return DeleteUserDocument(db, userID, documentName, version)
+		return DeleteUserDocument(ctx, db, userID, documentName, version, opts)
 	}
 
 	var newVersion uint64
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+	now := time.Now().UTC()
+	err := transaction(db, ScopeUser, "delete_properties", func(tx *gorm.DB) error {
 		var doc models.UserDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
+		if err := lockingQuery(tx, ScopeUser).
 			Where("user_id = ? AND document_name = ?", userID, documentName).
+			Where(notExpired, now).
 			First(&doc).Error; err != nil {
-			return err
+			return documentLookupError(err, opts)
 		}
 
-		if doc.DocumentVersion != version {
-			return fmt.Errorf("E_VERSION")
+		if versionMismatch(doc.DocumentVersion, version, opts) {
+			return ErrVersionConflict
 		}
 
-		documentUpdated := false
+		// Expired collections and properties are removed too, covered by this delete's version bump
+		documentUpdated, err := pruneExpired(tx, "user", doc.DocumentID, now)
+		if err != nil {
+			return err
+		}
 
 		for _, coll := range collections {
 			var collection models.UserCollection
@@ -367,9 +465,13 @@ func DeleteUserProperties(db *gorm.DB, userID, documentName string, version uint
 			}
 
 			if len(coll.Properties) == 0 {
+				if err := trashUserDocument(tx, doc, coll.Collection, opts.Actor); err != nil {
+					return err
+				}
 				if err := tx.Model(&doc).Association("Collections").Delete(&collection); err != nil {
 					return err
 				}
+				recordChanges(tx, entityCollection, actionDeleted, 1)
 				documentUpdated = true
 			} else {
 				for _, propName := range coll.Properties {
@@ -381,6 +483,7 @@ func DeleteUserProperties(db *gorm.DB, userID, documentName string, version uint
 						if err := tx.Model(&collection).Association("Properties").Delete(&property); err != nil {
 							return err
 						}
+						recordChanges(tx, entityProperty, actionDeleted, 1)
 						documentUpdated = true
 					}
 				}
@@ -399,9 +502,10 @@ func DeleteUserProperties(db *gorm.DB, userID, documentName string, version uint
 				return result.Error
 			}
 			if result.RowsAffected == 0 {
-				return fmt.Errorf("E_VERSION - Failed to update document due to concurrent modification")
+				return errConcurrentModification
 			}
 			affectedRows = result.RowsAffected
+			recordChanges(tx, entityDocument, actionUpdated, affectedRows)
 		} else {
 			newVersion = doc.DocumentVersion
 		}
@@ -409,6 +513,10 @@ func DeleteUserProperties(db *gorm.DB, userID, documentName string, version uint
 		return nil
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
 	return newVersion, affectedRows, err
 }
 
@@ -435,10 +543,12 @@ func cleanupApplicationOrphans(tx *gorm.DB) error {
 	// `application_documents_collections` table likely has `collection_id` (ref to collection) and `document_id` (ref to doc).
 
 	// Delete collections not associated with any document
-	if err := tx.Exec(`DELETE FROM application_collections 
-		WHERE collection_id NOT IN (SELECT collection_id FROM application_documents_collections)`).Error; err != nil {
-		return err
+	collections := tx.Exec(`DELETE FROM application_collections 
+		WHERE collection_id NOT IN (SELECT collection_id FROM application_documents_collections)`)
+	if collections.Error != nil {
+		return collections.Error
 	}
+	recordOrphans(tx, entityCollection, collections.RowsAffected)
 
 	// Delete collection-property associations for non-existent collections
 	// (This table `application_collections_properties` connects collection <-> property)
@@ -452,10 +562,12 @@ func cleanupApplicationOrphans(tx *gorm.DB) error {
 	}
 
 	// Delete properties not associated with any collection
-	if err := tx.Exec(`DELETE FROM application_properties 
-		WHERE property_id NOT IN (SELECT property_id FROM application_collections_properties)`).Error; err != nil {
-		return err
+	properties := tx.Exec(`DELETE FROM application_properties 
+		WHERE property_id NOT IN (SELECT property_id FROM application_collections_properties)`)
+	if properties.Error != nil {
+		return properties.Error
 	}
+	recordOrphans(tx, entityProperty, properties.RowsAffected)
 
 	return nil
 }
@@ -465,20 +577,24 @@ func cleanupUserOrphans(tx *gorm.DB) error {
 	// UserDocument -> Collections: `user_documents_collections` (`document_id`, `collection_id`)
 	// UserCollection -> Properties: `user_collections_properties` (`collection_id`, `property_id`)
 
-	if err := tx.Exec(`DELETE FROM user_collections 
-		WHERE collection_id NOT IN (SELECT collection_id FROM user_documents_collections)`).Error; err != nil {
-		return err
+	collections := tx.Exec(`DELETE FROM user_collections 
+		WHERE collection_id NOT IN (SELECT collection_id FROM user_documents_collections)`)
+	if collections.Error != nil {
+		return collections.Error
 	}
+	recordOrphans(tx, entityCollection, collections.RowsAffected)
 
 	if err := tx.Exec(`DELETE FROM user_collections_properties 
 		WHERE collection_id NOT IN (SELECT collection_id FROM user_collections)`).Error; err != nil {
 		return err
 	}
 
-	if err := tx.Exec(`DELETE FROM user_properties 
-		WHERE property_id NOT IN (SELECT property_id FROM user_collections_properties)`).Error; err != nil {
-		return err
+	properties := tx.Exec(`DELETE FROM user_properties 
+		WHERE property_id NOT IN (SELECT property_id FROM user_collections_properties)`)
+	if properties.Error != nil {
+		return properties.Error
 	}
+	recordOrphans(tx, entityProperty, properties.RowsAffected)
 
 	return nil
 }
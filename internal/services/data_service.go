@@ -20,8 +20,11 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/localnerve/jam-build-propsdb/internal/models"
 	"gorm.io/datatypes"
@@ -37,8 +40,11 @@ type DocumentResult map[string]interface{}
 
 // CollectionInput represents input for upsert operations
 type CollectionInput struct {
-	Collection string                 `json:"collection"`
-	Properties map[string]interface{} `json:"properties,omitempty"`
+	Collection     string                 `json:"collection"`
+	Properties     map[string]interface{} `json:"properties,omitempty"`
+	Expiry                                // Optional collection expiry
+	PropertyExpiry map[string]Expiry      `json:"propertyExpiry,omitempty"` // Optional expiry of properties in Properties or Operations
+	Operations     Operations             `json:"operations,omitempty"`     // Optional atomic operations on property values
 }
 
 // DeleteCollectionInput represents input for delete operations
@@ -48,17 +54,20 @@ type DeleteCollectionInput struct {
 }
 
 // GetApplicationProperties retrieves properties for a specific document and collection
-func GetApplicationProperties(db *gorm.DB, documentName, collectionName string) (DocumentResult, error) {
+func GetApplicationProperties(ctx context.Context, db *gorm.DB, documentName, collectionName string, opts ReadOptions) (DocumentResult, error) {
+	db = db.WithContext(ctx)
 	var doc models.ApplicationDocument
-	err := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
-		Preload("Collections", "collection_name = ?", collectionName).
-		Preload("Collections.Properties").
+	now := time.Now().UTC()
+	query := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
+		Preload("Collections", "collection_name = ? AND "+notExpired, collectionName, now)
+	err := preloadProperties(query, opts, now).
 		Where("document_name = ?", documentName).
+		Where(notExpired, now).
 		First(&doc).Error
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
@@ -70,30 +79,33 @@ func GetApplicationProperties(db *gorm.DB, documentName, collectionName string)
 		// We need to return not found if the specific collection requested isn't there,
 		// but the original query did an INNER JOIN so it would have returned empty if doc existed but coll didn't.
 		// However, to strictly match the semantics of "not found" for the *pair*, we should check.
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 
-	return reduceApplicationDocuments([]models.ApplicationDocument{doc}), nil
+	return reduceApplicationDocuments([]models.ApplicationDocument{doc}, opts), nil
 }
 
 // GetApplicationCollectionsAndProperties retrieves collections and properties for a document
-func GetApplicationCollectionsAndProperties(db *gorm.DB, documentName string, collections []string) (DocumentResult, error) {
+func GetApplicationCollectionsAndProperties(ctx context.Context, db *gorm.DB, documentName string, collections []string, opts ReadOptions) (DocumentResult, error) {
+	db = db.WithContext(ctx)
 	var doc models.ApplicationDocument
+	now := time.Now().UTC()
 	query := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
-		Where("document_name = ?", documentName)
+		Where("document_name = ?", documentName).
+		Where(notExpired, now)
 
 	if len(collections) > 0 && collections[0] != "" {
-		query = query.Preload("Collections", "collection_name IN ?", collections)
+		query = query.Preload("Collections", "collection_name IN ? AND "+notExpired, collections, now)
 	} else {
-		query = query.Preload("Collections")
+		query = query.Preload("Collections", notExpired, now)
 	}
 
-	err := query.Preload("Collections.Properties").
+	err := preloadProperties(query, opts, now).
 		First(&doc).Error
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
@@ -108,14 +120,15 @@ func GetApplicationCollectionsAndProperties(db *gorm.DB, documentName string, co
 	// The original query: "c.collection_name IN ?" was on the JOIN.
 	// If no rows returned, it returned "not found".
 	if len(collections) > 0 && collections[0] != "" && len(doc.Collections) == 0 {
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 
-	return reduceApplicationDocuments([]models.ApplicationDocument{doc}), nil
+	return reduceApplicationDocuments([]models.ApplicationDocument{doc}, opts), nil
 }
 
 // GetApplicationDocumentsCollectionsAndProperties retrieves all documents, collections, and properties
-func GetApplicationDocumentsCollectionsAndProperties(db *gorm.DB) (DocumentResult, error) {
+func GetApplicationDocumentsCollectionsAndProperties(ctx context.Context, db *gorm.DB, opts ReadOptions) (DocumentResult, error) {
+	db = db.WithContext(ctx)
 	var docs []models.ApplicationDocument
 
 	// We want all documents that have at least one collection usually,
@@ -124,13 +137,16 @@ func GetApplicationDocumentsCollectionsAndProperties(db *gorm.DB) (DocumentResul
 	// So it only returned documents that HAD collections.
 
 	// Fetch all documents with their collections and properties
-	if err := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
-		Preload("Collections").Preload("Collections.Properties").Find(&docs).Error; err != nil {
+	now := time.Now().UTC()
+	query := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
+		Where(notExpired, now).
+		Preload("Collections", notExpired, now)
+	if err := preloadProperties(query, opts, now).Find(&docs).Error; err != nil {
 		return nil, err
 	}
 
 	if len(docs) == 0 {
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 
 	// Filter out docs with no collections to match original INNER JOIN behavior if necessary?
@@ -138,69 +154,78 @@ func GetApplicationDocumentsCollectionsAndProperties(db *gorm.DB) (DocumentResul
 	// the previous Code probably wouldn't have it in the list if it was an INNER JOIN.
 	// Let's rely on the reducer to formatted it.
 
-	return reduceApplicationDocuments(docs), nil
+	return reduceApplicationDocuments(docs, opts), nil
 }
 
 // GetUserProperties retrieves properties for a specific user document and collection
-func GetUserProperties(db *gorm.DB, userID, documentName, collectionName string) (DocumentResult, error) {
+func GetUserProperties(ctx context.Context, db *gorm.DB, userID, documentName, collectionName string, opts ReadOptions) (DocumentResult, error) {
+	db = db.WithContext(ctx)
 	var doc models.UserDocument
-	err := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
-		Preload("Collections", "collection_name = ?", collectionName).
-		Preload("Collections.Properties").
+	now := time.Now().UTC()
+	query := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
+		Preload("Collections", "collection_name = ? AND "+notExpired, collectionName, now)
+	err := preloadProperties(query, opts, now).
 		Where("user_id = ? AND document_name = ?", userID, documentName).
+		Where(notExpired, now).
 		First(&doc).Error
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
 
 	if len(doc.Collections) == 0 {
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 
-	return reduceUserDocuments([]models.UserDocument{doc}), nil
+	return reduceUserDocuments([]models.UserDocument{doc}, opts), nil
 }
 
 // GetUserCollectionsAndProperties retrieves collections and properties for a user document
-func GetUserCollectionsAndProperties(db *gorm.DB, userID, documentName string, collections []string) (DocumentResult, error) {
+func GetUserCollectionsAndProperties(ctx context.Context, db *gorm.DB, userID, documentName string, collections []string, opts ReadOptions) (DocumentResult, error) {
+	db = db.WithContext(ctx)
 	var doc models.UserDocument
+	now := time.Now().UTC()
 	query := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
-		Where("user_id = ? AND document_name = ?", userID, documentName)
+		Where("user_id = ? AND document_name = ?", userID, documentName).
+		Where(notExpired, now)
 
 	if len(collections) > 0 && collections[0] != "" {
-		query = query.Preload("Collections", "collection_name IN ?", collections)
+		query = query.Preload("Collections", "collection_name IN ? AND "+notExpired, collections, now)
 	} else {
-		query = query.Preload("Collections")
+		query = query.Preload("Collections", notExpired, now)
 	}
 
-	err := query.Preload("Collections.Properties").
+	err := preloadProperties(query, opts, now).
 		First(&doc).Error
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
 
 	if len(collections) > 0 && collections[0] != "" && len(doc.Collections) == 0 {
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 
-	return reduceUserDocuments([]models.UserDocument{doc}), nil
+	return reduceUserDocuments([]models.UserDocument{doc}, opts), nil
 }
 
 // GetUserDocumentsCollectionsAndProperties retrieves all documents, collections, and properties for a user
-func GetUserDocumentsCollectionsAndProperties(db *gorm.DB, userID string) (DocumentResult, error) {
+func GetUserDocumentsCollectionsAndProperties(ctx context.Context, db *gorm.DB, userID string, opts ReadOptions) (DocumentResult, error) {
+	db = db.WithContext(ctx)
 	var docs []models.UserDocument
 
-	err := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
+	now := time.Now().UTC()
+	query := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)}).
 		Where("user_id = ?", userID).
-		Preload("Collections").
-		Preload("Collections.Properties").
+		Where(notExpired, now).
+		Preload("Collections", notExpired, now)
+	err := preloadProperties(query, opts, now).
 		Find(&docs).Error
 
 	if err != nil {
@@ -208,14 +233,14 @@ func GetUserDocumentsCollectionsAndProperties(db *gorm.DB, userID string) (Docum
 	}
 
 	if len(docs) == 0 {
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 
-	return reduceUserDocuments(docs), nil
+	return reduceUserDocuments(docs, opts), nil
 }
 
 // reduceApplicationDocuments converts application models to API output
-func reduceApplicationDocuments(docs []models.ApplicationDocument) DocumentResult {
+func reduceApplicationDocuments(docs []models.ApplicationDocument, opts ReadOptions) DocumentResult {
 	output := make(DocumentResult)
 
 	for _, doc := range docs {
@@ -225,13 +250,21 @@ func reduceApplicationDocuments(docs []models.ApplicationDocument) DocumentResul
 
 		docMap := make(map[string]interface{})
 		docMap["__version"] = fmt.Sprintf("%d", doc.DocumentVersion)
+		opts.expiring(doc.ExpiresAt)
 
 		for _, coll := range doc.Collections {
+			opts.expiring(coll.ExpiresAt)
 			collMap := make(map[string]interface{})
 			for _, prop := range coll.Properties {
 				var value interface{}
 				if err := json.Unmarshal(prop.PropertyValue.JSON, &value); err == nil {
-					collMap[prop.PropertyName] = value
+					if projected, ok := opts.Fields.apply(prop.PropertyName, value); ok {
+						collMap[prop.PropertyName] = projected
+						opts.expiring(prop.ExpiresAt)
+						if opts.IncludeMeta {
+							addPropertyMeta(docMap, coll.CollectionName, prop.PropertyName, prop.PropertyVersion, opts.actor(prop.ModifiedBy), prop.CreatedAt, prop.UpdatedAt, prop.ExpiresAt)
+						}
+					}
 				}
 			}
 			docMap[coll.CollectionName] = collMap
@@ -248,19 +281,27 @@ func reduceApplicationDocuments(docs []models.ApplicationDocument) DocumentResul
 }
 
 // reduceUserDocuments converts user models to API output
-func reduceUserDocuments(docs []models.UserDocument) DocumentResult {
+func reduceUserDocuments(docs []models.UserDocument, opts ReadOptions) DocumentResult {
 	output := make(DocumentResult)
 
 	for _, doc := range docs {
 		docMap := make(map[string]interface{})
 		docMap["__version"] = fmt.Sprintf("%d", doc.DocumentVersion)
+		opts.expiring(doc.ExpiresAt)
 
 		for _, coll := range doc.Collections {
+			opts.expiring(coll.ExpiresAt)
 			collMap := make(map[string]interface{})
 			for _, prop := range coll.Properties {
 				var value interface{}
 				if err := json.Unmarshal(prop.PropertyValue.JSON, &value); err == nil {
-					collMap[prop.PropertyName] = value
+					if projected, ok := opts.Fields.apply(prop.PropertyName, value); ok {
+						collMap[prop.PropertyName] = projected
+						opts.expiring(prop.ExpiresAt)
+						if opts.IncludeMeta {
+							addPropertyMeta(docMap, coll.CollectionName, prop.PropertyName, prop.PropertyVersion, opts.actor(prop.ModifiedBy), prop.CreatedAt, prop.UpdatedAt, prop.ExpiresAt)
+						}
+					}
 				}
 			}
 			docMap[coll.CollectionName] = collMap
@@ -272,245 +313,488 @@ func reduceUserDocuments(docs []models.UserDocument) DocumentResult {
 }
 
 // SetApplicationProperties upserts application document with collections and properties
-func SetApplicationProperties(db *gorm.DB, documentName string, version uint64, collections []CollectionInput) (uint64, int64, error) {
+func SetApplicationProperties(ctx context.Context, db *gorm.DB, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	if err := validateCollections(collections, opts); err != nil {
+		return 0, 0, err
+	}
+
 	var newVersion uint64
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Lock and check version
-		var doc models.ApplicationDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
-			Where("document_name = ?", documentName).
-			First(&doc).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// Document doesn't exist, version should be 0
-				if version != 0 {
-					return fmt.Errorf("E_VERSION")
-				}
-			} else {
-				return err
-			}
-		} else {
-			// Document exists, check version
-			if doc.DocumentVersion != version {
-				return fmt.Errorf("E_VERSION")
-			}
+	err := transaction(db, ScopeApp, "set", func(tx *gorm.DB) error {
+		var err error
+		newVersion, affectedRows, err = setApplicationProperties(tx, documentName, version, collections, opts, true)
+		return err
+	})
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// setApplicationProperties upserts a document with collections and properties within the transaction tx.
+// Collections the document does not hold yet are shared by name with other documents unless shared is false.
+func setApplicationProperties(tx *gorm.DB, documentName string, version uint64, collections []CollectionInput, opts WriteOptions, shared bool) (uint64, int64, error) {
+	var newVersion uint64
+	var affectedRows int64
+
+	now := time.Now().UTC()
+	expiry, err := resolveWriteExpiry(collections, opts, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Lock and check version
+	var doc models.ApplicationDocument
+	err = lockingQuery(tx, ScopeApp).
+		Where("document_name = ?", documentName).
+		First(&doc).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, err
+	}
+	exists := err == nil
+
+	// An expired document is already gone to readers, so remove it ahead of the sweeper and write a new one
+	if exists && expired(doc.ExpiresAt, now) {
+		if err := tx.Delete(&doc).Error; err != nil {
+			return 0, 0, err
+		}
+		recordChanges(tx, entityDocument, actionDeleted, 1)
+		if err := cleanupApplicationOrphans(tx); err != nil {
+			return 0, 0, err
+		}
+		exists = false
+		doc.DocumentVersion = 0
+	}
+
+	if baseVersionConflict(exists, doc.DocumentVersion, version, opts) {
+		return 0, 0, ErrVersionConflict
+	}
+	if opts.AnyVersion {
+		version = doc.DocumentVersion
+	}
+
+	// Insert or update document
+	doc = models.ApplicationDocument{DocumentName: documentName}
+	if err := tx.Where("document_name = ?", documentName).
+		Assign(models.ApplicationDocument{DocumentName: documentName}).
+		FirstOrCreate(&doc).Error; err != nil {
+		return 0, 0, err
+	}
+	if !exists {
+		recordChanges(tx, entityDocument, actionCreated, 1)
+	}
+
+	// Expired collections and properties are removed too, covered by this write's version bump
+	documentUpdated, err := pruneExpired(tx, "application", doc.DocumentID, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if expiryChanged(doc.ExpiresAt, expiry.document) {
+		if err := tx.Model(&doc).Update("expires_at", expiry.document).Error; err != nil {
+			return 0, 0, err
 		}
+		documentUpdated = true
+	}
 
-		// Insert or update document
-		doc = models.ApplicationDocument{DocumentName: documentName}
-		if err := tx.Where("document_name = ?", documentName).
-			Assign(models.ApplicationDocument{DocumentName: documentName}).
-			FirstOrCreate(&doc).Error; err != nil {
-			return err
+	// Atomic operations apply to the current values, read under the document lock
+	var operations resolvedOperations
+	if hasOperations(collections) {
+		content, err := loadApplicationContent(tx, doc.DocumentID, "", now)
+		if err != nil {
+			return 0, 0, err
 		}
+		if collections, operations, err = resolveOperations(collections, content); err != nil {
+			return 0, 0, err
+		}
+	}
 
-		documentUpdated := false
+	// Changed properties record the version this write produces
+	nextVersion := doc.DocumentVersion + 1
 
-		// Process collections
-		for _, coll := range collections {
-			var collection models.ApplicationCollection
+	// Process collections
+	for i, coll := range collections {
+		var collection models.ApplicationCollection
 
-			// Find or create collection
-			if err := tx.Where("collection_name = ?", coll.Collection).
-				FirstOrCreate(&collection, models.ApplicationCollection{CollectionName: coll.Collection}).Error; err != nil {
-				return err
+		// Prefer the collection this document holds, a copy holds its own apart from the shared one
+		if err := tx.Model(&doc).Where("collection_name = ?", coll.Collection).Association("Collections").Find(&collection); err != nil {
+			return 0, 0, err
+		}
+		// Property versions in a collection this document did not hold yet belong to other documents
+		owned := collection.CollectionID != 0
+
+		// Otherwise find the shared collection or create one, an expired one is left for the sweeper
+		if !owned && shared {
+			result := tx.Where("collection_name = ?", coll.Collection).
+				Where(notExpired, now).
+				Limit(1).
+				Find(&collection)
+			if result.Error != nil {
+				return 0, 0, result.Error
 			}
+		}
+		if collection.CollectionID == 0 {
+			collection = models.ApplicationCollection{CollectionName: coll.Collection}
+			if err := tx.Create(&collection).Error; err != nil {
+				return 0, 0, err
+			}
+			recordChanges(tx, entityCollection, actionCreated, 1)
+		}
 
-			// Associate collection with document if not already associated
-			var existingAssoc models.ApplicationDocument
-			err := tx.Preload("Collections", "collection_id = ?", collection.CollectionID).
-				Where("document_id = ?", doc.DocumentID).
-				First(&existingAssoc).Error
-
-			if err == gorm.ErrRecordNotFound || len(existingAssoc.Collections) == 0 {
-				if err := tx.Model(&doc).Association("Collections").Append(&collection); err != nil {
-					return err
-				}
-				documentUpdated = true
+		if expiryChanged(collection.ExpiresAt, expiry.collections[i]) {
+			if err := tx.Model(&collection).Update("expires_at", expiry.collections[i]).Error; err != nil {
+				return 0, 0, err
 			}
+			documentUpdated = true
+		}
 
-			// Process properties
-			for propName, propValue := range coll.Properties {
-				jsonValue, err := json.Marshal(propValue)
-				if err != nil {
-					return err
-				}
+		if !owned {
+			if err := tx.Model(&doc).Association("Collections").Append(&collection); err != nil {
+				return 0, 0, err
+			}
+			documentUpdated = true
+		}
 
-				var property models.ApplicationProperty
+		// Process properties
+		for propName, propValue := range coll.Properties {
+			jsonValue, err := json.Marshal(propValue)
+			if err != nil {
+				return 0, 0, fmt.Errorf("%w: property %s: %v", ErrValidation, propName, err)
+			}
 
-				// Check if property exists in this collection
-				var existingProp models.ApplicationCollection
-				err = tx.Preload("Properties", "property_name = ?", propName).
-					Where("collection_id = ?", collection.CollectionID).
-					First(&existingProp).Error
+			var property models.ApplicationProperty
+
+			// Check if property exists in this collection
+			var existingProp models.ApplicationCollection
+			err = tx.Preload("Properties", "property_name = ?", propName).
+				Where("collection_id = ?", collection.CollectionID).
+				First(&existingProp).Error
+
+			if errors.Is(err, gorm.ErrRecordNotFound) || len(existingProp.Properties) == 0 {
+				// Create new property
+				property = models.ApplicationProperty{
+					PropertyName:    propName,
+					PropertyValue:   models.JSON{JSON: datatypes.JSON(jsonValue)},
+					PropertyVersion: nextVersion,
+					ModifiedBy:      opts.Actor,
+					ExpiresAt:       expiry.properties[i][propName],
+				}
+				if err := tx.Create(&property).Error; err != nil {
+					return 0, 0, err
+				}
+				recordChanges(tx, entityProperty, actionCreated, 1)
 
-				if err == gorm.ErrRecordNotFound || len(existingProp.Properties) == 0 {
-					// Create new property
-					property = models.ApplicationProperty{
-						PropertyName:  propName,
-						PropertyValue: models.JSON{JSON: datatypes.JSON(jsonValue)},
-					}
-					if err := tx.Create(&property).Error; err != nil {
-						return err
+				// Associate property with collection
+				if err := tx.Model(&collection).Association("Properties").Append(&property); err != nil {
+					return 0, 0, err
+				}
+				documentUpdated = true
+			} else {
+				// Property exists, check if value changed
+				property = existingProp.Properties[0]
+				if updates := propertyUpdates(property.PropertyValue, jsonValue, property.ExpiresAt, expiry.properties[i][propName], nextVersion, opts); updates != nil {
+					if owned && !operations.atomic(i, propName) && propertyConflict(property.PropertyVersion, version, doc.DocumentVersion, opts) {
+						return 0, 0, ErrVersionConflict
 					}
-
-					// Associate property with collection
-					if err := tx.Model(&collection).Association("Properties").Append(&property); err != nil {
-						return err
+					if err := tx.Model(&property).Updates(updates).Error; err != nil {
+						return 0, 0, err
 					}
+					recordChanges(tx, entityProperty, actionUpdated, 1)
 					documentUpdated = true
-				} else {
-					// Property exists, check if value changed
-					property = existingProp.Properties[0]
-					if string(property.PropertyValue.JSON) != string(jsonValue) {
-						if err := tx.Model(&property).Update("property_value", models.JSON{JSON: datatypes.JSON(jsonValue)}).Error; err != nil {
-							return err
-						}
-						documentUpdated = true
-					}
 				}
 			}
 		}
 
-		// Update document version if changes were made
-		if documentUpdated {
-			newVersion = doc.DocumentVersion + 1
-			result := tx.Model(&doc).Where("document_version = ?", doc.DocumentVersion).
-				Update("document_version", newVersion)
-			if result.Error != nil {
-				return result.Error
+		// Remove properties unset by operations
+		for _, propName := range operations.unsetIn(i) {
+			var existingProp models.ApplicationCollection
+			if err := tx.Preload("Properties", "property_name = ?", propName).
+				Where("collection_id = ?", collection.CollectionID).
+				First(&existingProp).Error; err != nil {
+				return 0, 0, err
 			}
-			if result.RowsAffected == 0 {
-				return fmt.Errorf("E_VERSION - Failed to update document due to concurrent modification")
+			if len(existingProp.Properties) > 0 {
+				if err := tx.Model(&collection).Association("Properties").Delete(&existingProp.Properties[0]); err != nil {
+					return 0, 0, err
+				}
+				recordChanges(tx, entityProperty, actionDeleted, 1)
+				documentUpdated = true
 			}
-			affectedRows = result.RowsAffected
-		} else {
-			newVersion = doc.DocumentVersion
 		}
+	}
 
-		return nil
+	if operations.removed() {
+		if err := cleanupApplicationOrphans(tx); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Update document version if changes were made
+	if documentUpdated {
+		newVersion = nextVersion
+		result := tx.Model(&doc).Where("document_version = ?", doc.DocumentVersion).
+			Update("document_version", newVersion)
+		if result.Error != nil {
+			return 0, 0, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return 0, 0, errConcurrentModification
+		}
+		affectedRows = result.RowsAffected
+		if exists {
+			recordChanges(tx, entityDocument, actionUpdated, affectedRows)
+		}
+	} else {
+		newVersion = doc.DocumentVersion
+	}
+
+	return newVersion, affectedRows, nil
+}
+
+// SetUserProperties upserts user document with collections and properties
+func SetUserProperties(ctx context.Context, db *gorm.DB, userID, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	if err := validateCollections(collections, opts); err != nil {
+		return 0, 0, err
+	}
+
+	var newVersion uint64
+	var affectedRows int64
+
+	err := transaction(db, ScopeUser, "set", func(tx *gorm.DB) error {
+		var err error
+		newVersion, affectedRows, err = setUserProperties(tx, userID, documentName, version, collections, opts)
+		return err
 	})
 
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
 	return newVersion, affectedRows, err
 }
 
-// SetUserProperties upserts user document with collections and properties
-func SetUserProperties(db *gorm.DB, userID, documentName string, version uint64, collections []CollectionInput) (uint64, int64, error) {
+// setUserProperties upserts a document with collections and properties within the transaction tx
+func setUserProperties(tx *gorm.DB, userID, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
 	var newVersion uint64
 	var affectedRows int64
 
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Lock and check version
-		var doc models.UserDocument
-		if err := withLocking(tx).Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)}).
-			Where("user_id = ? AND document_name = ?", userID, documentName).
-			First(&doc).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				if version != 0 {
-					return fmt.Errorf("E_VERSION")
-				}
-			} else {
-				return err
+	now := time.Now().UTC()
+	expiry, err := resolveWriteExpiry(collections, opts, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Lock and check version
+	var doc models.UserDocument
+	err = lockingQuery(tx, ScopeUser).
+		Where("user_id = ? AND document_name = ?", userID, documentName).
+		First(&doc).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, err
+	}
+	exists := err == nil
+
+	// An expired document is already gone to readers, so remove it ahead of the sweeper and write a new one
+	if exists && expired(doc.ExpiresAt, now) {
+		if err := tx.Delete(&doc).Error; err != nil {
+			return 0, 0, err
+		}
+		recordChanges(tx, entityDocument, actionDeleted, 1)
+		if err := cleanupUserOrphans(tx); err != nil {
+			return 0, 0, err
+		}
+		exists = false
+		doc.DocumentVersion = 0
+	}
+
+	if baseVersionConflict(exists, doc.DocumentVersion, version, opts) {
+		return 0, 0, ErrVersionConflict
+	}
+	if opts.AnyVersion {
+		version = doc.DocumentVersion
+	}
+
+	// Insert or update document
+	doc = models.UserDocument{UserID: userID, DocumentName: documentName}
+	if err := tx.Where("user_id = ? AND document_name = ?", userID, documentName).
+		Assign(models.UserDocument{UserID: userID, DocumentName: documentName}).
+		FirstOrCreate(&doc).Error; err != nil {
+		return 0, 0, err
+	}
+	if !exists {
+		recordChanges(tx, entityDocument, actionCreated, 1)
+	}
+
+	// Expired collections and properties are removed too, covered by this write's version bump
+	documentUpdated, err := pruneExpired(tx, "user", doc.DocumentID, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if expiryChanged(doc.ExpiresAt, expiry.document) {
+		if err := tx.Model(&doc).Update("expires_at", expiry.document).Error; err != nil {
+			return 0, 0, err
+		}
+		documentUpdated = true
+	}
+
+	// Atomic operations apply to the current values, read under the document lock
+	var operations resolvedOperations
+	if hasOperations(collections) {
+		content, err := loadUserContent(tx, doc.DocumentID, "", now)
+		if err != nil {
+			return 0, 0, err
+		}
+		if collections, operations, err = resolveOperations(collections, content); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Changed properties record the version this write produces
+	nextVersion := doc.DocumentVersion + 1
+
+	// Process collections
+	for i, coll := range collections {
+		var collection models.UserCollection
+
+		// Look up collection specifically linked to THIS document
+		err := tx.Model(&doc).Where("collection_name = ?", coll.Collection).Association("Collections").Find(&collection)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// If not found for this document, create a NEW collection record
+		if collection.CollectionID == 0 {
+			collection = models.UserCollection{CollectionName: coll.Collection}
+			if err := tx.Create(&collection).Error; err != nil {
+				return 0, 0, err
 			}
-		} else {
-			if doc.DocumentVersion != version {
-				return fmt.Errorf("E_VERSION")
+			recordChanges(tx, entityCollection, actionCreated, 1)
+			// Link it to the document
+			if err := tx.Model(&doc).Association("Collections").Append(&collection); err != nil {
+				return 0, 0, err
 			}
+			documentUpdated = true
 		}
 
-		// Insert or update document
-		doc = models.UserDocument{UserID: userID, DocumentName: documentName}
-		if err := tx.Where("user_id = ? AND document_name = ?", userID, documentName).
-			Assign(models.UserDocument{UserID: userID, DocumentName: documentName}).
-			FirstOrCreate(&doc).Error; err != nil {
-			return err
+		if expiryChanged(collection.ExpiresAt, expiry.collections[i]) {
+			if err := tx.Model(&collection).Update("expires_at", expiry.collections[i]).Error; err != nil {
+				return 0, 0, err
+			}
+			documentUpdated = true
 		}
 
-		documentUpdated := false
+		for propName, propValue := range coll.Properties {
+			jsonValue, err := json.Marshal(propValue)
+			if err != nil {
+				return 0, 0, fmt.Errorf("%w: property %s: %v", ErrValidation, propName, err)
+			}
 
-		// Process collections
-		for _, coll := range collections {
-			var collection models.UserCollection
+			var property models.UserProperty
 
-			// Look up collection specifically linked to THIS document
-			err := tx.Model(&doc).Where("collection_name = ?", coll.Collection).Association("Collections").Find(&collection)
+			// Look up property specifically linked to THIS collection
+			err = tx.Model(&collection).Where("property_name = ?", propName).Association("Properties").Find(&property)
 			if err != nil {
-				return err
+				return 0, 0, err
 			}
 
-			// If not found for this document, create a NEW collection record
-			if collection.CollectionID == 0 {
-				collection = models.UserCollection{CollectionName: coll.Collection}
-				if err := tx.Create(&collection).Error; err != nil {
-					return err
+			if property.PropertyID == 0 {
+				// Create a new property for this collection
+				property = models.UserProperty{
+					PropertyName:    propName,
+					PropertyValue:   models.JSON{JSON: datatypes.JSON(jsonValue)},
+					PropertyVersion: nextVersion,
+					ModifiedBy:      opts.Actor,
+					ExpiresAt:       expiry.properties[i][propName],
 				}
-				// Link it to the document
-				if err := tx.Model(&doc).Association("Collections").Append(&collection); err != nil {
-					return err
+				if err := tx.Create(&property).Error; err != nil {
+					return 0, 0, err
 				}
-				documentUpdated = true
-			}
-
-			for propName, propValue := range coll.Properties {
-				jsonValue, err := json.Marshal(propValue)
-				if err != nil {
-					return err
+				recordChanges(tx, entityProperty, actionCreated, 1)
+				// Link it to the collection
+				if err := tx.Model(&collection).Association("Properties").Append(&property); err != nil {
+					return 0, 0, err
 				}
-
-				var property models.UserProperty
-
-				// Look up property specifically linked to THIS collection
-				err = tx.Model(&collection).Where("property_name = ?", propName).Association("Properties").Find(&property)
-				if err != nil {
-					return err
-				}
-
-				if property.PropertyID == 0 {
-					// Create a new property for this collection
-					property = models.UserProperty{
-						PropertyName:  propName,
-						PropertyValue: models.JSON{JSON: datatypes.JSON(jsonValue)},
-					}
-					if err := tx.Create(&property).Error; err != nil {
-						return err
+				documentUpdated = true
+			} else {
+				// Update value or expiry if different
+				if updates := propertyUpdates(property.PropertyValue, jsonValue, property.ExpiresAt, expiry.properties[i][propName], nextVersion, opts); updates != nil {
+					if !operations.atomic(i, propName) && propertyConflict(property.PropertyVersion, version, doc.DocumentVersion, opts) {
+						return 0, 0, ErrVersionConflict
 					}
-					// Link it to the collection
-					if err := tx.Model(&collection).Association("Properties").Append(&property); err != nil {
-						return err
+					if err := tx.Model(&property).Updates(updates).Error; err != nil {
+						return 0, 0, err
 					}
+					recordChanges(tx, entityProperty, actionUpdated, 1)
 					documentUpdated = true
-				} else {
-					// Update value if different
-					if string(property.PropertyValue.JSON) != string(jsonValue) {
-						if err := tx.Model(&property).Update("property_value", models.JSON{JSON: datatypes.JSON(jsonValue)}).Error; err != nil {
-							return err
-						}
-						documentUpdated = true
-					}
 				}
 			}
 		}
 
-		if documentUpdated {
-			newVersion = doc.DocumentVersion + 1
-			result := tx.Model(&doc).Where("document_version = ?", doc.DocumentVersion).
-				Update("document_version", newVersion)
-			if result.Error != nil {
-				return result.Error
+		// Remove properties unset by operations
+		for _, propName := range operations.unsetIn(i) {
+			var property models.UserProperty
+			if err := tx.Model(&collection).Where("property_name = ?", propName).Association("Properties").Find(&property); err != nil {
+				return 0, 0, err
 			}
-			if result.RowsAffected == 0 {
-				return fmt.Errorf("E_VERSION - Failed to update document due to concurrent modification")
+			if property.PropertyID != 0 {
+				if err := tx.Model(&collection).Association("Properties").Delete(&property); err != nil {
+					return 0, 0, err
+				}
+				recordChanges(tx, entityProperty, actionDeleted, 1)
+				documentUpdated = true
 			}
-			affectedRows = result.RowsAffected
-		} else {
-			newVersion = doc.DocumentVersion
 		}
+	}
+
+	if operations.removed() {
+		if err := cleanupUserOrphans(tx); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if documentUpdated {
+		newVersion = nextVersion
+		result := tx.Model(&doc).Where("document_version = ?", doc.DocumentVersion).
+			Update("document_version", newVersion)
+		if result.Error != nil {
+			return 0, 0, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return 0, 0, errConcurrentModification
+		}
+		affectedRows = result.RowsAffected
+		if exists {
+			recordChanges(tx, entityDocument, actionUpdated, affectedRows)
+		}
+	} else {
+		newVersion = doc.DocumentVersion
+	}
+
+	return newVersion, affectedRows, nil
+}
 
+// propertyUpdates returns the column updates for a property write, nil when neither the value nor the expiry changes
+func propertyUpdates(current models.JSON, jsonValue []byte, currentExpiry, expiresAt *time.Time, nextVersion uint64, opts WriteOptions) map[string]interface{} {
+	valueChanged := string(current.JSON) != string(jsonValue)
+	if !valueChanged && !expiryChanged(currentExpiry, expiresAt) {
 		return nil
-	})
+	}
 
-	return newVersion, affectedRows, err
+	updates := map[string]interface{}{
+		"property_value":   models.JSON{JSON: datatypes.JSON(jsonValue)},
+		"property_version": nextVersion,
+		"modified_by":      opts.Actor,
+	}
+	if expiresAt != nil {
+		updates["expires_at"] = expiresAt
+	}
+	return updates
 }
 
 // withLocking applies the correct locking clause based on the database driver (MSSQL vs others)
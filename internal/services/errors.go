@@ -0,0 +1,59 @@
+// errors.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Sentinel errors returned by the data services and Store implementations, wrapped with context.
+// Test for them with errors.Is. The messages match the legacy string errors.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrVersionConflict = errors.New("E_VERSION")
+	ErrValidation      = errors.New("invalid input")
+	ErrForbidden       = errors.New("forbidden")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrExists          = errors.New("already exists")
+)
+
+// errConcurrentModification reports a lost race to bump the document version
+var errConcurrentModification = fmt.Errorf("%w - Failed to update document due to concurrent modification", ErrVersionConflict)
+
+// lookupError classifies a failed document lookup, translating a missing record to ErrNotFound
+func lookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// validateCollections checks collection inputs before a mutation
+func validateCollections(collections []CollectionInput, opts WriteOptions) error {
+	for _, coll := range collections {
+		if coll.Collection == "" {
+			return fmt.Errorf("%w: collection name is required", ErrValidation)
+		}
+	}
+	return validateOperations(collections, opts)
+}
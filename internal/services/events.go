@@ -0,0 +1,70 @@
+// events.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import "sync"
+
+// Mutation scopes
+const (
+	ScopeApp  = "app"
+	ScopeUser = "user"
+)
+
+// MutationEvent describes a committed change to a document
+type MutationEvent struct {
+	Scope    string // ScopeApp or ScopeUser
+	UserID   string // Set for ScopeUser
+	Document string
+	Version  uint64 // The new document version, 0 if the document was deleted
+}
+
+var (
+	subscribersMu  sync.RWMutex
+	subscribers    = make(map[int]func(MutationEvent))
+	nextSubscriber int
+)
+
+// OnMutation registers fn to be called after every committed mutation.
+// fn runs synchronously on the mutating request, so it should be quick.
+// Call the returned function to unsubscribe.
+func OnMutation(fn func(MutationEvent)) func() {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	id := nextSubscriber
+	nextSubscriber++
+	subscribers[id] = fn
+
+	return func() {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+		delete(subscribers, id)
+	}
+}
+
+// publishMutation notifies subscribers of a committed mutation
+func publishMutation(event MutationEvent) {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
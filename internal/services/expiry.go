@@ -0,0 +1,281 @@
+// expiry.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/models"
+	"gorm.io/gorm"
+)
+
+// Expiry is an optional time-to-live in seconds, or an absolute expiry time, for a document, collection or property
+type Expiry struct {
+	TTL       int64      `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// notExpired is the query condition for rows whose expires_at has not passed the time argument
+const notExpired = "(expires_at IS NULL OR expires_at > ?)"
+
+// resolve returns the expiry time relative to now, or nil when none is set
+func (e Expiry) resolve(now time.Time) (*time.Time, error) {
+	switch {
+	case e.TTL < 0:
+		return nil, fmt.Errorf("%w: ttl must not be negative", ErrValidation)
+	case e.TTL > 0 && e.ExpiresAt != nil:
+		return nil, fmt.Errorf("%w: ttl and expiresAt cannot both be set", ErrValidation)
+	case e.TTL > 0:
+		expiresAt := now.Add(time.Duration(e.TTL) * time.Second).UTC()
+		return &expiresAt, nil
+	case e.ExpiresAt != nil:
+		if !e.ExpiresAt.After(now) {
+			return nil, fmt.Errorf("%w: expiresAt must be in the future", ErrValidation)
+		}
+		expiresAt := e.ExpiresAt.UTC()
+		return &expiresAt, nil
+	}
+	return nil, nil
+}
+
+// writeExpiry holds the resolved expiry times of a write, nil where none was given
+type writeExpiry struct {
+	document    *time.Time
+	collections []*time.Time            // By collection input index
+	properties  []map[string]*time.Time // By collection input index, then property name
+}
+
+// resolveWriteExpiry resolves the document, collection and property expiry of a write
+func resolveWriteExpiry(collections []CollectionInput, opts WriteOptions, now time.Time) (writeExpiry, error) {
+	var err error
+	result := writeExpiry{
+		collections: make([]*time.Time, len(collections)),
+		properties:  make([]map[string]*time.Time, len(collections)),
+	}
+
+	if result.document, err = opts.Expiry.resolve(now); err != nil {
+		return result, err
+	}
+
+	for i, coll := range collections {
+		if result.collections[i], err = coll.Expiry.resolve(now); err != nil {
+			return result, fmt.Errorf("collection %s: %w", coll.Collection, err)
+		}
+
+		result.properties[i] = make(map[string]*time.Time, len(coll.PropertyExpiry))
+		operated := coll.Operations.properties()
+		for propName, expiry := range coll.PropertyExpiry {
+			if _, ok := coll.Properties[propName]; !ok && !operated[propName] {
+				return result, fmt.Errorf("%w: propertyExpiry for %s without a property value", ErrValidation, propName)
+			}
+			if result.properties[i][propName], err = expiry.resolve(now); err != nil {
+				return result, fmt.Errorf("property %s: %w", propName, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// expired reports whether an optional expiry time has passed
+func expired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(now)
+}
+
+// expiryChanged reports whether a write sets an expiry different from the current one
+func expiryChanged(current, next *time.Time) bool {
+	return next != nil && (current == nil || !current.Equal(*next))
+}
+
+// pruneExpired removes the expired collections and properties of a locked document.
+// prefix is the table prefix of the scope, "application" or "user".
+func pruneExpired(tx *gorm.DB, prefix string, documentID uint64, now time.Time) (bool, error) {
+	collections := tx.Exec(fmt.Sprintf(`DELETE FROM %[1]s_documents_collections
+		WHERE document_id = ? AND collection_id IN (SELECT collection_id FROM %[1]s_collections WHERE expires_at <= ?)`, prefix),
+		documentID, now)
+	if collections.Error != nil {
+		return false, collections.Error
+	}
+
+	properties := tx.Exec(fmt.Sprintf(`DELETE FROM %[1]s_collections_properties
+		WHERE collection_id IN (SELECT collection_id FROM %[1]s_documents_collections WHERE document_id = ?)
+		AND property_id IN (SELECT property_id FROM %[1]s_properties WHERE expires_at <= ?)`, prefix),
+		documentID, now)
+	if properties.Error != nil {
+		return false, properties.Error
+	}
+
+	recordChanges(tx, entityCollection, actionDeleted, collections.RowsAffected)
+	recordChanges(tx, entityProperty, actionDeleted, properties.RowsAffected)
+	if collections.RowsAffected+properties.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if prefix == "application" {
+		return true, cleanupApplicationOrphans(tx)
+	}
+	return true, cleanupUserOrphans(tx)
+}
+
+// expiredDocuments lists the ids of documents that are expired or hold expired collections or properties
+func expiredDocuments(db *gorm.DB, prefix string, now time.Time) ([]uint64, error) {
+	var ids []uint64
+	err := db.Raw(fmt.Sprintf(`SELECT document_id FROM %[1]s_documents WHERE expires_at <= ?
+		UNION
+		SELECT dc.document_id FROM %[1]s_documents_collections dc
+		JOIN %[1]s_collections c ON c.collection_id = dc.collection_id
+		WHERE c.expires_at <= ?
+		UNION
+		SELECT dc.document_id FROM %[1]s_documents_collections dc
+		JOIN %[1]s_collections_properties cp ON cp.collection_id = dc.collection_id
+		JOIN %[1]s_properties p ON p.property_id = cp.property_id
+		WHERE p.expires_at <= ?`, prefix), now, now, now).Scan(&ids).Error
+	return ids, err
+}
+
+// SweepExpired removes expired documents, collections and properties, bumping the version of each changed document
+// and publishing its mutation event. It returns the number of documents changed.
+func SweepExpired(ctx context.Context, db *gorm.DB, now time.Time) (int64, error) {
+	db = db.WithContext(ctx)
+	now = now.UTC()
+	var swept int64
+
+	appIDs, err := expiredDocuments(db, "application", now)
+	if err != nil {
+		return swept, err
+	}
+	for _, id := range appIDs {
+		var event MutationEvent
+		err := transaction(db, ScopeApp, "sweep", func(tx *gorm.DB) error {
+			var doc models.ApplicationDocument
+			if err := lockingQuery(tx, ScopeApp).
+				Where("document_id = ?", id).
+				First(&doc).Error; err != nil {
+				return lookupError(err)
+			}
+			event = MutationEvent{Scope: ScopeApp, Document: doc.DocumentName}
+
+			if expired(doc.ExpiresAt, now) {
+				if err := tx.Delete(&doc).Error; err != nil {
+					return err
+				}
+				recordChanges(tx, entityDocument, actionDeleted, 1)
+				return cleanupApplicationOrphans(tx)
+			}
+
+			// App collections are shared, so another document's sweep may already have removed the expired data
+			if _, err := pruneExpired(tx, "application", doc.DocumentID, now); err != nil {
+				return err
+			}
+			event.Version = doc.DocumentVersion + 1
+			if err := tx.Model(&doc).Update("document_version", event.Version).Error; err != nil {
+				return err
+			}
+			recordChanges(tx, entityDocument, actionUpdated, 1)
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return swept, err
+		}
+		swept++
+		publishMutation(event)
+	}
+
+	userIDs, err := expiredDocuments(db, "user", now)
+	if err != nil {
+		return swept, err
+	}
+	for _, id := range userIDs {
+		var event MutationEvent
+		changed := false
+		err := transaction(db, ScopeUser, "sweep", func(tx *gorm.DB) error {
+			var doc models.UserDocument
+			if err := lockingQuery(tx, ScopeUser).
+				Where("document_id = ?", id).
+				First(&doc).Error; err != nil {
+				return lookupError(err)
+			}
+			event = MutationEvent{Scope: ScopeUser, UserID: doc.UserID, Document: doc.DocumentName}
+
+			if expired(doc.ExpiresAt, now) {
+				changed = true
+				if err := tx.Delete(&doc).Error; err != nil {
+					return err
+				}
+				recordChanges(tx, entityDocument, actionDeleted, 1)
+				return cleanupUserOrphans(tx)
+			}
+
+			pruned, err := pruneExpired(tx, "user", doc.DocumentID, now)
+			if err != nil || !pruned {
+				return err
+			}
+			changed = true
+			event.Version = doc.DocumentVersion + 1
+			if err := tx.Model(&doc).Update("document_version", event.Version).Error; err != nil {
+				return err
+			}
+			recordChanges(tx, entityDocument, actionUpdated, 1)
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return swept, err
+		}
+		if changed {
+			swept++
+			publishMutation(event)
+		}
+	}
+
+	return swept, nil
+}
+
+// ExpirySweeper periodically removes expired data from Store
+type ExpirySweeper struct {
+	Store Store
+	periodicTask
+}
+
+// Sweep removes expired data once
+func (s *ExpirySweeper) Sweep(ctx context.Context) {
+	swept, err := s.Store.SweepExpired(ctx, time.Now())
+	if err != nil {
+		slog.Error("Failed to sweep expired data", "error", err)
+		return
+	}
+	if swept > 0 {
+		slog.Info("Removed expired data", "documents", swept)
+	}
+}
+
+// Start sweeps every interval until Stop
+func (s *ExpirySweeper) Start(interval time.Duration) {
+	s.start(interval, s.Sweep)
+}
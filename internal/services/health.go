@@ -20,71 +20,362 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
 	"github.com/localnerve/jam-build-propsdb/internal/utils"
 	"gorm.io/gorm"
 )
 
+// DefaultHealthTimeout bounds each dependency check when a HealthChecker has no Timeout
+const DefaultHealthTimeout = 2 * time.Second
+
+// Health check components, also the keys of ReadinessResult.Checks
+const (
+	ComponentAppDatabase  = "app-db"
+	ComponentUserDatabase = "user-db"
+	ComponentAuthorizer   = "authorizer"
+	ComponentSchema       = "schema"
+	ComponentReplicas     = "replicas"
+)
+
+// Components lists every health check component
+var Components = []string{ComponentAppDatabase, ComponentUserDatabase, ComponentAuthorizer, ComponentSchema, ComponentReplicas}
+
 // HealthCheckResult represents the result of a health check
 type HealthCheckResult struct {
 	Status       string            `json:"status"`
-	Database     string            `json:"database"`
-	Authorizer   string            `json:"authorizer"`
+	Database     string            `json:"database,omitempty"`
+	UserDatabase string            `json:"user_database,omitempty"`
+	Authorizer   string            `json:"authorizer,omitempty"`
+	Migrations   string            `json:"migrations,omitempty"`
+	Replicas     map[string]string `json:"replicas,omitempty"`
 	Details      map[string]string `json:"details,omitempty"`
 	ErrorMessage string            `json:"error,omitempty"`
 }
 
-// HealthCheck performs a comprehensive health check of the service
-func HealthCheck(cfg *config.Config, db *gorm.DB) HealthCheckResult {
+// ReadinessResult is the outcome of a readiness check, Checks maps each dependency to "ok" or its error.
+// Degraded reports ejected read replicas, which do not make the service unready.
+type ReadinessResult struct {
+	Ready    bool              `json:"ready"`
+	Degraded bool              `json:"degraded,omitempty"`
+	Checks   map[string]string `json:"checks"`
+}
+
+// HealthChecker checks the service dependencies for the health endpoints. Checks run concurrently,
+// each bounded by Timeout, and results are reused for CacheTTL so frequent probes do not reach
+// the databases and Authorizer on every request.
+type HealthChecker struct {
+	Config   *config.Config
+	AppDB    *gorm.DB
+	UserDB   *gorm.DB               // Optional, checked when set
+	Replicas []*database.ReplicaSet // Optional, unhealthy replicas only degrade the service
+	Timeout  time.Duration          // per check, DefaultHealthTimeout when zero
+	CacheTTL time.Duration          // how long results are reused, zero checks every time
+
+	// Migrations also checks for tables and columns AutoMigrate has not created
+	Migrations bool
+
+	// Components limits the checks to those listed, when empty every configured one runs
+	Components []string
+
+	mu       sync.Mutex
+	ready    ReadinessResult
+	readyAt  time.Time
+	health   HealthCheckResult
+	healthAt time.Time
+}
+
+// HealthCheck performs a comprehensive health check of the service.
+// Unreachable read replicas are ejected and make the status "degraded", since reads fall back to the primary.
+func HealthCheck(ctx context.Context, cfg *config.Config, db *gorm.DB, replicaSets ...*database.ReplicaSet) HealthCheckResult {
+	checker := &HealthChecker{Config: cfg, AppDB: db, Replicas: replicaSets}
+	return checker.check(ctx)
+}
+
+// Ready reports whether the service can take traffic: both database pools and the Authorizer are
+// reachable and no migrations are pending, or the listed Components pass. Read replicas are not required,
+// ejected ones make the result Degraded.
+func (h *HealthChecker) Ready(ctx context.Context) ReadinessResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.CacheTTL > 0 && time.Since(h.readyAt) < h.CacheTTL {
+		return h.ready
+	}
+
+	errs := h.run(ctx, h.dependencyChecks(false))
+	result := ReadinessResult{Ready: true, Checks: make(map[string]string, len(errs))}
+	for name, err := range errs {
+		if err != nil {
+			result.Ready = false
+			result.Checks[name] = err.Error()
+			slog.WarnContext(ctx, "Readiness check failed", "check", name, "error", err)
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+
+	// Replicas are reported from their background checks, not pinged here
+	if h.enabled(ComponentReplicas) && len(h.Replicas) > 0 {
+		result.Checks[ComponentReplicas] = "ok"
+		if ejected := h.ejectedReplicas(); len(ejected) > 0 {
+			result.Degraded = true
+			result.Checks[ComponentReplicas] = "ejected: " + strings.Join(ejected, ", ")
+		}
+	}
+
+	h.ready, h.readyAt = result, time.Now()
+	return result
+}
+
+// Health performs the detailed health check, reusing a result younger than CacheTTL
+func (h *HealthChecker) Health(ctx context.Context) HealthCheckResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.CacheTTL > 0 && time.Since(h.healthAt) < h.CacheTTL {
+		return h.health
+	}
+
+	h.health, h.healthAt = h.check(ctx), time.Now()
+	return h.health
+}
+
+// enabled reports whether a component is checked: listed in Components or, when none are, configured
+func (h *HealthChecker) enabled(component string) bool {
+	if len(h.Components) > 0 {
+		return slices.Contains(h.Components, component)
+	}
+	switch component {
+	case ComponentUserDatabase:
+		return h.UserDB != nil
+	case ComponentSchema:
+		return h.Migrations
+	case ComponentReplicas:
+		return len(h.Replicas) > 0
+	}
+	return true
+}
+
+// dependencyChecks returns the checks of the enabled components, the replica checks only when asked
+func (h *HealthChecker) dependencyChecks(replicas bool) map[string]func(context.Context) error {
+	checks := make(map[string]func(context.Context) error)
+	if h.enabled(ComponentAppDatabase) {
+		checks[ComponentAppDatabase] = func(ctx context.Context) error {
+			return pingDatabase(ctx, h.AppDB)
+		}
+	}
+	if h.enabled(ComponentUserDatabase) {
+		checks[ComponentUserDatabase] = func(ctx context.Context) error {
+			return pingDatabase(ctx, h.UserDB)
+		}
+	}
+	if h.enabled(ComponentAuthorizer) {
+		checks[ComponentAuthorizer] = func(context.Context) error {
+			return utils.PingService(h.Config.AuthzURL, h.timeout())
+		}
+	}
+	if h.enabled(ComponentSchema) {
+		checks[ComponentSchema] = func(ctx context.Context) error {
+			if h.AppDB == nil {
+				return fmt.Errorf("not configured")
+			}
+			pending, err := database.PendingMigrations(h.AppDB.WithContext(ctx))
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("pending: %s", strings.Join(pending, ", "))
+			}
+			return nil
+		}
+	}
+	if replicas && h.enabled(ComponentReplicas) {
+		checks[ComponentReplicas] = func(context.Context) error {
+			for _, replicaSet := range h.Replicas {
+				if replicaSet != nil {
+					replicaSet.Check()
+				}
+			}
+			return nil
+		}
+	}
+	return checks
+}
+
+// check runs the enabled component checks, and builds the detailed result
+func (h *HealthChecker) check(ctx context.Context) HealthCheckResult {
 	result := HealthCheckResult{
 		Status:  "healthy",
 		Details: make(map[string]string),
 	}
+	unhealthy := func(message string) {
+		result.Status = "unhealthy"
+		if result.ErrorMessage == "" {
+			result.ErrorMessage = message
+		} else {
+			result.ErrorMessage += "; " + message
+		}
+	}
+
+	checks := h.dependencyChecks(true)
+	errs := h.run(ctx, checks)
 
 	// Check database connectivity
-	sqlDB, err := db.DB()
-	if err != nil {
-		result.Status = "unhealthy"
-		result.Database = "error"
-		result.Details["database_error"] = err.Error()
-		result.ErrorMessage = fmt.Sprintf("Database connection error: %v", err)
-		log.Printf("Health check failed - database connection: %v", err)
-	} else {
-		if err := sqlDB.Ping(); err != nil {
-			result.Status = "unhealthy"
+	if err, ok := errs[ComponentAppDatabase]; ok {
+		if err != nil {
 			result.Database = "unreachable"
-			result.Details["database_ping_error"] = err.Error()
-			result.ErrorMessage = fmt.Sprintf("Database ping failed: %v", err)
-			log.Printf("Health check failed - database ping: %v", err)
+			result.Details["database_error"] = err.Error()
+			unhealthy(fmt.Sprintf("Database ping failed: %v", err))
+			slog.Error("Health check failed - database ping", "error", err)
 		} else {
 			result.Database = "ok"
-			result.Details["database_type"] = cfg.DBType
-			result.Details["database_name"] = cfg.DBAppDatabase
+			result.Details["database_type"] = h.Config.DBType
+			result.Details["database_name"] = h.Config.DBAppDatabase
+		}
+	}
+
+	if err, ok := errs[ComponentUserDatabase]; ok {
+		if err != nil {
+			result.UserDatabase = "unreachable"
+			result.Details["user_database_error"] = err.Error()
+			unhealthy(fmt.Sprintf("User database ping failed: %v", err))
+			slog.Error("Health check failed - user database ping", "error", err)
+		} else {
+			result.UserDatabase = "ok"
 		}
 	}
 
 	// Check Authorizer connectivity
-	if err := utils.PingAuthorizer(cfg.AuthzURL); err != nil {
-		result.Status = "unhealthy"
-		result.Authorizer = "unreachable"
-		result.Details["authorizer_error"] = err.Error()
-		if result.ErrorMessage == "" {
-			result.ErrorMessage = fmt.Sprintf("Authorizer ping failed: %v", err)
+	if err, ok := errs[ComponentAuthorizer]; ok {
+		if err != nil {
+			result.Authorizer = "unreachable"
+			result.Details["authorizer_error"] = err.Error()
+			unhealthy(fmt.Sprintf("Authorizer ping failed: %v", err))
+			slog.Error("Health check failed - authorizer ping", "error", err)
 		} else {
-			result.ErrorMessage += fmt.Sprintf("; Authorizer ping failed: %v", err)
+			result.Authorizer = "ok"
+			result.Details["authorizer_url"] = h.Config.AuthzURL
+		}
+	}
+
+	if err, ok := errs[ComponentSchema]; ok {
+		if err != nil {
+			result.Migrations = "pending"
+			result.Details["migrations_error"] = err.Error()
+			unhealthy(fmt.Sprintf("Migrations check failed: %v", err))
+			slog.Error("Health check failed - migrations", "error", err)
+		} else {
+			result.Migrations = "ok"
+		}
+	}
+
+	// Check read replicas
+	for _, replicaSet := range h.Replicas {
+		if replicaSet == nil || !h.enabled(ComponentReplicas) {
+			continue
+		}
+		for _, replica := range replicaSet.Status() {
+			if result.Replicas == nil {
+				result.Replicas = make(map[string]string)
+			}
+			if replica.Healthy {
+				result.Replicas[replica.Name] = "ok"
+				continue
+			}
+			result.Replicas[replica.Name] = "ejected"
+			result.Details[replica.Name+"_error"] = replica.Error
+			if result.Status == "healthy" {
+				result.Status = "degraded"
+			}
+			slog.Warn("Health check degraded", "replica", replica.Name, "error", replica.Error)
 		}
-		log.Printf("Health check failed - authorizer ping: %v", err)
-	} else {
-		result.Authorizer = "ok"
-		result.Details["authorizer_url"] = cfg.AuthzURL
 	}
 
 	if result.Status == "healthy" {
-		log.Println("Health check passed - all systems operational")
+		slog.Debug("Health check passed - all systems operational")
 	}
 
 	return result
 }
+
+// ejectedReplicas returns the names of the replicas failing their last check
+func (h *HealthChecker) ejectedReplicas() []string {
+	var ejected []string
+	for _, replicaSet := range h.Replicas {
+		if replicaSet == nil {
+			continue
+		}
+		for _, replica := range replicaSet.Status() {
+			if !replica.Healthy {
+				ejected = append(ejected, replica.Name)
+			}
+		}
+	}
+	return ejected
+}
+
+// run runs the checks concurrently and returns their errors by name. A check still running at the
+// timeout is reported as timed out and left to finish on its own.
+func (h *HealthChecker) run(ctx context.Context, checks map[string]func(context.Context) error) map[string]error {
+	timeout := h.timeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[string]error, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+
+			done := make(chan error, 1)
+			go func() { done <- check(ctx) }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = fmt.Errorf("timed out after %s", timeout)
+			}
+
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// timeout returns the per check timeout
+func (h *HealthChecker) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
+	}
+	return DefaultHealthTimeout
+}
+
+// pingDatabase pings the pool of db
+func pingDatabase(ctx context.Context, db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("not configured")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("connection error: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
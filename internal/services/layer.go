@@ -0,0 +1,205 @@
+// layer.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LayerMode is how a user collection combines with the app collection of the same name
+type LayerMode string
+
+const (
+	LayerMerge   LayerMode = "merge"   // Deep merge objects, user values win
+	LayerReplace LayerMode = "replace" // The user collection replaces the app collection
+)
+
+// Keys added to a layered document
+const (
+	AppVersionKey = "__appVersion" // Version of the app document layered under the user document
+	ProvenanceKey = "__provenance" // Layer of each property, when LayerOptions.Provenance is set
+)
+
+// Provenance of a property in a layered document
+const (
+	ProvenanceApp    = "app"    // App default only
+	ProvenanceUser   = "user"   // User value only, or a user value replacing the default
+	ProvenanceMerged = "merged" // User object deep merged over an app object
+)
+
+// LayerOptions configure how a user document is layered over an app document
+type LayerOptions struct {
+	Default     LayerMode            // Mode of collections without a rule, LayerMerge when empty
+	Collections map[string]LayerMode // Mode by collection name
+	Provenance  bool                 // Adds the layer of each property under ProvenanceKey
+}
+
+// ParseLayerRules parses merge rules as collection:mode, with *:mode or a bare mode setting the default
+func ParseLayerRules(rules []string) (LayerOptions, error) {
+	opts := LayerOptions{Default: LayerMerge, Collections: make(map[string]LayerMode)}
+	for _, rule := range rules {
+		collection, mode, found := strings.Cut(rule, ":")
+		if !found {
+			collection, mode = "*", rule
+		}
+		layerMode := LayerMode(mode)
+		if layerMode != LayerMerge && layerMode != LayerReplace {
+			return opts, fmt.Errorf("%w: merge rule %q must use %s or %s", ErrValidation, rule, LayerMerge, LayerReplace)
+		}
+		if collection == "*" {
+			opts.Default = layerMode
+		} else {
+			opts.Collections[collection] = layerMode
+		}
+	}
+	return opts, nil
+}
+
+// mode returns the layer mode of a collection
+func (o LayerOptions) mode(collection string) LayerMode {
+	if mode, ok := o.Collections[collection]; ok {
+		return mode
+	}
+	if o.Default == "" {
+		return LayerMerge
+	}
+	return o.Default
+}
+
+// LayerDocument layers a user document over the app document of the same name, as read by
+// GetUserCollectionsAndProperties and GetApplicationCollectionsAndProperties. Either result may be nil
+// when its document does not exist. __version is the user document version, "0" without one,
+// and AppVersionKey holds the app document version.
+func LayerDocument(documentName string, app, user DocumentResult, opts LayerOptions) DocumentResult {
+	appDoc, _ := app[documentName].(map[string]interface{})
+	userDoc, _ := user[documentName].(map[string]interface{})
+
+	docMap := map[string]interface{}{
+		"__version":   layerVersion(userDoc),
+		AppVersionKey: layerVersion(appDoc),
+	}
+	appMeta, _ := appDoc[MetaKey].(DocumentMeta)
+	userMeta, _ := userDoc[MetaKey].(DocumentMeta)
+	meta := make(DocumentMeta)
+	provenance := make(map[string]map[string]string)
+
+	// Collections only in the app document are defaults throughout
+	for collection, value := range appDoc {
+		appColl, ok := value.(map[string]interface{})
+		if !ok || isLayerKey(collection) {
+			continue
+		}
+		if _, ok := userDoc[collection]; ok {
+			continue
+		}
+		docMap[collection] = appColl
+		provenance[collection] = make(map[string]string, len(appColl))
+		for property := range appColl {
+			provenance[collection][property] = ProvenanceApp
+			addLayerMeta(meta, appMeta, collection, property)
+		}
+	}
+
+	for collection, value := range userDoc {
+		userColl, ok := value.(map[string]interface{})
+		if !ok || isLayerKey(collection) {
+			continue
+		}
+		appColl, _ := appDoc[collection].(map[string]interface{})
+		if opts.mode(collection) == LayerReplace {
+			appColl = nil
+		}
+
+		collMap := make(map[string]interface{}, len(appColl)+len(userColl))
+		provenance[collection] = make(map[string]string, len(collMap))
+		for property, appValue := range appColl {
+			if _, ok := userColl[property]; !ok {
+				collMap[property] = appValue
+				provenance[collection][property] = ProvenanceApp
+				addLayerMeta(meta, appMeta, collection, property)
+			}
+		}
+		for property, userValue := range userColl {
+			appValue, inApp := appColl[property]
+			merged, deep := mergeLayerValue(appValue, userValue)
+			collMap[property] = merged
+			provenance[collection][property] = ProvenanceUser
+			if inApp && deep {
+				provenance[collection][property] = ProvenanceMerged
+			}
+			addLayerMeta(meta, userMeta, collection, property)
+		}
+		docMap[collection] = collMap
+	}
+
+	if appMeta != nil || userMeta != nil {
+		docMap[MetaKey] = meta
+	}
+	if opts.Provenance {
+		docMap[ProvenanceKey] = provenance
+	}
+
+	return DocumentResult{documentName: docMap}
+}
+
+// mergeLayerValue deep merges a user value over an app value, reporting whether objects were merged.
+// Anything but two objects is replaced by the user value.
+func mergeLayerValue(appValue, userValue interface{}) (interface{}, bool) {
+	appObject, appOk := appValue.(map[string]interface{})
+	userObject, userOk := userValue.(map[string]interface{})
+	if !appOk || !userOk {
+		return userValue, false
+	}
+
+	merged := make(map[string]interface{}, len(appObject)+len(userObject))
+	for key, value := range appObject {
+		merged[key] = value
+	}
+	for key, value := range userObject {
+		merged[key], _ = mergeLayerValue(appObject[key], value)
+	}
+	return merged, true
+}
+
+// layerVersion returns the version of a document map, "0" when there is none
+func layerVersion(docMap map[string]interface{}) string {
+	if version, ok := docMap["__version"].(string); ok {
+		return version
+	}
+	return "0"
+}
+
+// isLayerKey reports whether a document key holds metadata rather than a collection
+func isLayerKey(key string) bool {
+	return key == "__version" || key == MetaKey || key == AppVersionKey || key == ProvenanceKey
+}
+
+// addLayerMeta copies the metadata of a property from the layer it came from
+func addLayerMeta(meta, from DocumentMeta, collection, property string) {
+	propMeta, ok := from[collection][property]
+	if !ok {
+		return
+	}
+	if meta[collection] == nil {
+		meta[collection] = make(map[string]PropertyMeta)
+	}
+	meta[collection][property] = propMeta
+}
@@ -0,0 +1,1004 @@
+// memory_store.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/models"
+)
+
+// MemoryStore is a Store held entirely in memory, for fast handler tests and embedded use.
+// Like the application tables, app collections are shared by name across documents,
+// while user collections belong to a single document.
+type MemoryStore struct {
+	mu             sync.RWMutex
+	appDocuments   map[string]*memoryDocument
+	appCollections map[string]*memoryCollection
+	userDocuments  map[string]map[string]*memoryDocument // userID -> documentName -> document
+	appTrash       []memoryTrash
+	userTrash      map[string][]memoryTrash // userID -> deleted documents and collections
+	nextTrashID    uint64
+}
+
+// memoryDocument is a versioned document and its collections by name
+type memoryDocument struct {
+	version     uint64
+	collections map[string]*memoryCollection
+	expiresAt   *time.Time
+}
+
+// memoryCollection holds properties by name
+type memoryCollection struct {
+	properties map[string]*memoryProperty
+	expiresAt  *time.Time
+}
+
+// memoryProperty is a JSON encoded property value and the metadata of its last change
+type memoryProperty struct {
+	value      []byte
+	version    uint64
+	modifiedBy string
+	createdAt  time.Time
+	updatedAt  time.Time
+	expiresAt  *time.Time
+}
+
+// memoryTrash is a deleted document or collection, in deletion order
+type memoryTrash struct {
+	id         uint64
+	document   string
+	collection string
+	version    uint64
+	content    models.JSON
+	deletedBy  string
+	deletedAt  time.Time
+}
+
+// NewMemoryStore creates an empty in-memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		appDocuments:   make(map[string]*memoryDocument),
+		appCollections: make(map[string]*memoryCollection),
+		userDocuments:  make(map[string]map[string]*memoryDocument),
+		userTrash:      make(map[string][]memoryTrash),
+	}
+}
+
+// newMemoryCollection creates an empty collection
+func newMemoryCollection() *memoryCollection {
+	return &memoryCollection{properties: make(map[string]*memoryProperty)}
+}
+
+// GetApplicationProperties retrieves properties for a specific document and collection
+func (m *MemoryStore) GetApplicationProperties(_ context.Context, documentName, collectionName string, opts ReadOptions) (DocumentResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getMemoryProperties(m.appDocuments, documentName, collectionName, opts, time.Now())
+}
+
+// GetApplicationCollectionsAndProperties retrieves collections and properties for a document
+func (m *MemoryStore) GetApplicationCollectionsAndProperties(_ context.Context, documentName string, collections []string, opts ReadOptions) (DocumentResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getMemoryCollectionsAndProperties(m.appDocuments, documentName, collections, opts, time.Now())
+}
+
+// GetApplicationDocumentsCollectionsAndProperties retrieves all documents, collections, and properties
+func (m *MemoryStore) GetApplicationDocumentsCollectionsAndProperties(_ context.Context, opts ReadOptions) (DocumentResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getMemoryDocuments(m.appDocuments, opts, time.Now())
+}
+
+// SetApplicationProperties upserts application document with collections and properties
+func (m *MemoryStore) SetApplicationProperties(_ context.Context, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	newVersion, affectedRows, err := setMemoryProperties(m.appDocuments, documentName, version, collections, opts, m.appCollection)
+	m.cleanupAppCollections()
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// DeleteApplicationCollection deletes a collection from an application document
+func (m *MemoryStore) DeleteApplicationCollection(_ context.Context, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	newVersion, affectedRows, err := deleteMemoryCollection(m.appDocuments, documentName, version, collectionName, opts, m.appTrasher(documentName, opts))
+	m.cleanupAppCollections()
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// DeleteApplicationProperties deletes properties or collections from an application document
+func (m *MemoryStore) DeleteApplicationProperties(_ context.Context, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	newVersion, affectedRows, err := deleteMemoryProperties(m.appDocuments, documentName, version, collections, deleteDocument, opts, m.appTrasher(documentName, opts))
+	m.cleanupAppCollections()
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// CopyApplicationDocument copies an application document to a new document
+func (m *MemoryStore) CopyApplicationDocument(_ context.Context, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error) {
+	if err := validateTargetDocument(documentName, targetName); err != nil {
+		return 0, 0, err
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	doc, err := liveMemoryVersion(m.appDocuments, documentName, version, opts, now)
+	if err == nil {
+		err = claimMemoryDocument(m.appDocuments, targetName, now)
+	}
+	var newVersion uint64
+	var affectedRows int64
+	if err == nil {
+		// The copy gets collections of its own, so writing to it leaves the source alone
+		newVersion, affectedRows, err = setMemoryProperties(m.appDocuments, targetName, 0, memoryContent(doc, "", now).collectionInputs(), WriteOptions{Actor: opts.Actor}, func(string) *memoryCollection {
+			return newMemoryCollection()
+		})
+	}
+	m.cleanupAppCollections()
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: targetName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// RenameApplicationDocument renames an application document
+func (m *MemoryStore) RenameApplicationDocument(_ context.Context, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error) {
+	if err := validateTargetDocument(documentName, targetName); err != nil {
+		return 0, 0, err
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	doc, err := liveMemoryVersion(m.appDocuments, documentName, version, opts, now)
+	if err == nil {
+		err = claimMemoryDocument(m.appDocuments, targetName, now)
+	}
+	if err == nil {
+		pruneMemoryDocument(doc, now)
+		delete(m.appDocuments, documentName)
+		m.appDocuments[strings.Clone(targetName)] = doc
+		doc.version++
+	}
+	m.cleanupAppCollections()
+	m.mu.Unlock()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName})
+	publishMutation(MutationEvent{Scope: ScopeApp, Document: targetName, Version: doc.version})
+
+	return doc.version, 1, nil
+}
+
+// GetUserProperties retrieves properties for a specific user document and collection
+func (m *MemoryStore) GetUserProperties(_ context.Context, userID, documentName, collectionName string, opts ReadOptions) (DocumentResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getMemoryProperties(m.userDocuments[userID], documentName, collectionName, opts, time.Now())
+}
+
+// GetUserCollectionsAndProperties retrieves collections and properties for a user document
+func (m *MemoryStore) GetUserCollectionsAndProperties(_ context.Context, userID, documentName string, collections []string, opts ReadOptions) (DocumentResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getMemoryCollectionsAndProperties(m.userDocuments[userID], documentName, collections, opts, time.Now())
+}
+
+// GetUserDocumentsCollectionsAndProperties retrieves all documents, collections, and properties for a user
+func (m *MemoryStore) GetUserDocumentsCollectionsAndProperties(_ context.Context, userID string, opts ReadOptions) (DocumentResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return getMemoryDocuments(m.userDocuments[userID], opts, time.Now())
+}
+
+// SetUserProperties upserts user document with collections and properties
+func (m *MemoryStore) SetUserProperties(_ context.Context, userID, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	docs, ok := m.userDocuments[userID]
+	if !ok {
+		docs = make(map[string]*memoryDocument)
+		m.userDocuments[userID] = docs
+	}
+	newVersion, affectedRows, err := setMemoryProperties(docs, documentName, version, collections, opts, func(string) *memoryCollection {
+		return newMemoryCollection()
+	})
+	m.cleanupUser(userID)
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// DeleteUserCollection deletes a collection from a user document
+func (m *MemoryStore) DeleteUserCollection(_ context.Context, userID, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	newVersion, affectedRows, err := deleteMemoryCollection(m.userDocuments[userID], documentName, version, collectionName, opts, m.userTrasher(userID, documentName, opts))
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// DeleteUserProperties deletes properties or collections from a user document
+func (m *MemoryStore) DeleteUserProperties(_ context.Context, userID, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	newVersion, affectedRows, err := deleteMemoryProperties(m.userDocuments[userID], documentName, version, collections, deleteDocument, opts, m.userTrasher(userID, documentName, opts))
+	m.cleanupUser(userID)
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// CreateUserDocumentFromTemplate creates a user document from an application document
+func (m *MemoryStore) CreateUserDocumentFromTemplate(_ context.Context, userID, documentName, templateName string, opts WriteOptions) (uint64, int64, error) {
+	if documentName == "" {
+		return 0, 0, fmt.Errorf("%w: document name is required", ErrValidation)
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	docs, ok := m.userDocuments[userID]
+	if !ok {
+		docs = make(map[string]*memoryDocument)
+		m.userDocuments[userID] = docs
+	}
+	var newVersion uint64
+	var affectedRows int64
+	var err error
+	template, ok := liveMemoryDocument(m.appDocuments, templateName, now)
+	if !ok {
+		err = fmt.Errorf("template %w", ErrNotFound)
+	} else {
+		err = claimMemoryDocument(docs, documentName, now)
+	}
+	if err == nil {
+		newVersion, affectedRows, err = setMemoryProperties(docs, documentName, 0, memoryContent(template, "", now).collectionInputs(), WriteOptions{Actor: opts.Actor}, func(string) *memoryCollection {
+			return newMemoryCollection()
+		})
+	}
+	m.cleanupUser(userID)
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// GetApplicationTrash lists the deleted application documents and collections, most recent first
+func (m *MemoryStore) GetApplicationTrash(_ context.Context) ([]TrashEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return memoryTrashEntries(m.appTrash)
+}
+
+// RestoreApplicationTrash restores a deleted application document or collection from the trash
+func (m *MemoryStore) RestoreApplicationTrash(_ context.Context, documentName, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	trash, newVersion, affectedRows, err := restoreMemoryTrash(m.appTrash, m.appDocuments, documentName, collectionName, opts, m.appCollection)
+	m.appTrash = trash
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// GetUserTrash lists a user's deleted documents and collections, most recent first
+func (m *MemoryStore) GetUserTrash(_ context.Context, userID string) ([]TrashEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return memoryTrashEntries(m.userTrash[userID])
+}
+
+// RestoreUserTrash restores a deleted user document or collection from the trash
+func (m *MemoryStore) RestoreUserTrash(_ context.Context, userID, documentName, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	m.mu.Lock()
+	docs, ok := m.userDocuments[userID]
+	if !ok {
+		docs = make(map[string]*memoryDocument)
+		m.userDocuments[userID] = docs
+	}
+	trash, newVersion, affectedRows, err := restoreMemoryTrash(m.userTrash[userID], docs, documentName, collectionName, opts, func(string) *memoryCollection {
+		return newMemoryCollection()
+	})
+	m.setUserTrash(userID, trash)
+	m.cleanupUser(userID)
+	m.mu.Unlock()
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// PurgeTrash permanently removes trash deleted before cutoff
+func (m *MemoryStore) PurgeTrash(_ context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	keep := func(entries []memoryTrash) []memoryTrash {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.deletedAt.Before(cutoff) {
+				purged++
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		return kept
+	}
+
+	m.appTrash = keep(m.appTrash)
+	for userID, entries := range m.userTrash {
+		m.setUserTrash(userID, keep(entries))
+	}
+
+	return purged, nil
+}
+
+// SweepExpired removes expired documents, collections and properties, bumping the version of each changed document
+func (m *MemoryStore) SweepExpired(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	// App collections are shared, so find every affected document before pruning any
+	events := sweepMemoryDocuments(m.appDocuments, MutationEvent{Scope: ScopeApp}, now)
+	m.cleanupAppCollections()
+	for userID, docs := range m.userDocuments {
+		events = append(events, sweepMemoryDocuments(docs, MutationEvent{Scope: ScopeUser, UserID: userID}, now)...)
+		m.cleanupUser(userID)
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		publishMutation(event)
+	}
+
+	return int64(len(events)), nil
+}
+
+// appTrasher returns the function that copies an app document's collection, or the whole document, to the trash.
+// The caller holds the lock
+func (m *MemoryStore) appTrasher(documentName string, opts WriteOptions) func(*memoryDocument, string) {
+	return func(doc *memoryDocument, collectionName string) {
+		m.appTrash = append(m.appTrash, m.newMemoryTrash(doc, documentName, collectionName, opts))
+	}
+}
+
+// userTrasher returns the function that copies a user document's collection, or the whole document, to the trash.
+// The caller holds the lock
+func (m *MemoryStore) userTrasher(userID, documentName string, opts WriteOptions) func(*memoryDocument, string) {
+	return func(doc *memoryDocument, collectionName string) {
+		m.userTrash[userID] = append(m.userTrash[userID], m.newMemoryTrash(doc, documentName, collectionName, opts))
+	}
+}
+
+// newMemoryTrash snapshots a document's collection, or all its collections when collectionName is empty.
+// The caller holds the lock
+func (m *MemoryStore) newMemoryTrash(doc *memoryDocument, documentName, collectionName string, opts WriteOptions) memoryTrash {
+	// Property values are already valid JSON, so encoding cannot fail
+	content, _ := memoryContent(doc, collectionName, time.Now()).encode()
+
+	m.nextTrashID++
+	return memoryTrash{
+		id:         m.nextTrashID,
+		document:   strings.Clone(documentName),
+		collection: strings.Clone(collectionName),
+		version:    doc.version,
+		content:    content,
+		deletedBy:  opts.Actor,
+		deletedAt:  time.Now().UTC(),
+	}
+}
+
+// memoryContent snapshots the unexpired collections of a document, or one of them when collectionName is given
+func memoryContent(doc *memoryDocument, collectionName string, now time.Time) trashContent {
+	t := make(trashContent)
+	for name, coll := range doc.collections {
+		if (collectionName != "" && name != collectionName) || expired(coll.expiresAt, now) {
+			continue
+		}
+		properties := make(map[string]json.RawMessage, len(coll.properties))
+		for propName, prop := range coll.properties {
+			if !expired(prop.expiresAt, now) {
+				properties[propName] = json.RawMessage(prop.value)
+			}
+		}
+		t[name] = properties
+	}
+	return t
+}
+
+// setUserTrash replaces a user's trash, dropping the user when it is empty. The caller holds the lock
+func (m *MemoryStore) setUserTrash(userID string, entries []memoryTrash) {
+	if len(entries) == 0 {
+		delete(m.userTrash, userID)
+		return
+	}
+	m.userTrash[userID] = entries
+}
+
+// appCollection returns the shared app collection for name, creating it if needed.
+// An expired collection is replaced, it stays with the documents still holding it until they are pruned.
+// The caller holds the lock
+func (m *MemoryStore) appCollection(name string) *memoryCollection {
+	coll, ok := m.appCollections[name]
+	if !ok || expired(coll.expiresAt, time.Now()) {
+		coll = newMemoryCollection()
+		m.appCollections[strings.Clone(name)] = coll
+	}
+	return coll
+}
+
+// cleanupAppCollections removes app collections no document refers to. The caller holds the lock
+func (m *MemoryStore) cleanupAppCollections() {
+	referenced := make(map[*memoryCollection]bool)
+	for _, doc := range m.appDocuments {
+		for _, coll := range doc.collections {
+			referenced[coll] = true
+		}
+	}
+	for name, coll := range m.appCollections {
+		if !referenced[coll] {
+			delete(m.appCollections, name)
+		}
+	}
+}
+
+// cleanupUser removes a user with no documents. The caller holds the lock
+func (m *MemoryStore) cleanupUser(userID string) {
+	if len(m.userDocuments[userID]) == 0 {
+		delete(m.userDocuments, userID)
+	}
+}
+
+// liveMemoryDocument returns a document that exists and has not expired
+func liveMemoryDocument(docs map[string]*memoryDocument, documentName string, now time.Time) (*memoryDocument, bool) {
+	doc, ok := docs[documentName]
+	if !ok || expired(doc.expiresAt, now) {
+		return nil, false
+	}
+	return doc, true
+}
+
+// liveMemoryVersion returns a live document at version, ErrVersionConflict when it is at another version
+func liveMemoryVersion(docs map[string]*memoryDocument, documentName string, version uint64, opts WriteOptions, now time.Time) (*memoryDocument, error) {
+	doc, ok := liveMemoryDocument(docs, documentName, now)
+	if !ok {
+		return nil, missingMemoryDocument(opts)
+	}
+	if versionMismatch(doc.version, version, opts) {
+		return doc, ErrVersionConflict
+	}
+	return doc, nil
+}
+
+// missingMemoryDocument is the error for a write to a document that does not exist, see documentLookupError
+func missingMemoryDocument(opts WriteOptions) error {
+	if opts.AnyVersion {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+// claimMemoryDocument fails with ErrExists if docs has a live document named documentName, removing an expired one
+func claimMemoryDocument(docs map[string]*memoryDocument, documentName string, now time.Time) error {
+	doc, ok := docs[documentName]
+	if !ok {
+		return nil
+	}
+	if !expired(doc.expiresAt, now) {
+		return fmt.Errorf("document %s %w", documentName, ErrExists)
+	}
+	delete(docs, documentName)
+	return nil
+}
+
+// liveMemoryCollection reports whether a document holds a collection that has not expired
+func liveMemoryCollection(doc *memoryDocument, collectionName string, now time.Time) bool {
+	coll := doc.collections[collectionName]
+	return coll != nil && !expired(coll.expiresAt, now)
+}
+
+// getMemoryProperties reads one collection of a document
+func getMemoryProperties(docs map[string]*memoryDocument, documentName, collectionName string, opts ReadOptions, now time.Time) (DocumentResult, error) {
+	doc, ok := liveMemoryDocument(docs, documentName, now)
+	if !ok || !liveMemoryCollection(doc, collectionName, now) {
+		return nil, ErrNotFound
+	}
+
+	return reduceMemoryDocuments(map[string]*memoryDocument{documentName: doc}, []string{collectionName}, opts, now), nil
+}
+
+// getMemoryCollectionsAndProperties reads a document, optionally filtered to some collections
+func getMemoryCollectionsAndProperties(docs map[string]*memoryDocument, documentName string, collections []string, opts ReadOptions, now time.Time) (DocumentResult, error) {
+	doc, ok := liveMemoryDocument(docs, documentName, now)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if len(collections) > 0 && collections[0] != "" {
+		found := false
+		for _, name := range collections {
+			if liveMemoryCollection(doc, name, now) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrNotFound
+		}
+	} else {
+		collections = nil
+	}
+
+	return reduceMemoryDocuments(map[string]*memoryDocument{documentName: doc}, collections, opts, now), nil
+}
+
+// getMemoryDocuments reads every live document in docs
+func getMemoryDocuments(docs map[string]*memoryDocument, opts ReadOptions, now time.Time) (DocumentResult, error) {
+	live := make(map[string]*memoryDocument, len(docs))
+	for documentName, doc := range docs {
+		if !expired(doc.expiresAt, now) {
+			live[documentName] = doc
+		}
+	}
+	if len(live) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return reduceMemoryDocuments(live, nil, opts, now), nil
+}
+
+// reduceMemoryDocuments converts documents to API output, limited to collections when given.
+// Expired collections and properties are left out.
+func reduceMemoryDocuments(docs map[string]*memoryDocument, collections []string, opts ReadOptions, now time.Time) DocumentResult {
+	var selected map[string]bool
+	if collections != nil {
+		selected = make(map[string]bool, len(collections))
+		for _, name := range collections {
+			selected[name] = true
+		}
+	}
+
+	output := make(DocumentResult)
+	for documentName, doc := range docs {
+		docMap := make(map[string]interface{})
+		docMap["__version"] = fmt.Sprintf("%d", doc.version)
+		opts.expiring(doc.expiresAt)
+
+		for collectionName, coll := range doc.collections {
+			if (selected != nil && !selected[collectionName]) || expired(coll.expiresAt, now) {
+				continue
+			}
+			opts.expiring(coll.expiresAt)
+
+			collMap := make(map[string]interface{})
+			for propName, prop := range coll.properties {
+				if expired(prop.expiresAt, now) {
+					continue
+				}
+				var value interface{}
+				if err := json.Unmarshal(prop.value, &value); err == nil {
+					if projected, ok := opts.Fields.apply(propName, value); ok {
+						collMap[propName] = projected
+						opts.expiring(prop.expiresAt)
+						if opts.IncludeMeta {
+							addPropertyMeta(docMap, collectionName, propName, prop.version, opts.actor(prop.modifiedBy), prop.createdAt, prop.updatedAt, prop.expiresAt)
+						}
+					}
+				}
+			}
+			docMap[collectionName] = collMap
+		}
+		output[documentName] = docMap
+	}
+
+	return output
+}
+
+// setMemoryProperties upserts a document's collections and properties, bumping the version on change.
+// collectionFor supplies the collection to add when a document does not have it yet.
+func setMemoryProperties(docs map[string]*memoryDocument, documentName string, version uint64, collections []CollectionInput, opts WriteOptions, collectionFor func(string) *memoryCollection) (uint64, int64, error) {
+	if err := validateCollections(collections, opts); err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	expiry, err := resolveWriteExpiry(collections, opts, now.UTC())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// An expired document is already gone to readers, so remove it ahead of the sweeper and write a new one
+	doc, exists := docs[documentName]
+	if exists && expired(doc.expiresAt, now) {
+		delete(docs, documentName)
+		exists = false
+	}
+
+	var current uint64
+	if exists {
+		current = doc.version
+	}
+	if baseVersionConflict(exists, current, version, opts) {
+		return 0, 0, ErrVersionConflict
+	}
+	if opts.AnyVersion {
+		version = current
+	}
+
+	// Atomic operations apply to the current values
+	var operations resolvedOperations
+	if hasOperations(collections) {
+		content := make(trashContent)
+		if exists {
+			content = memoryContent(doc, "", now)
+		}
+		if collections, operations, err = resolveOperations(collections, content); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Encode everything first, so an invalid value changes nothing
+	encoded := make([]map[string][]byte, len(collections))
+	for i, coll := range collections {
+		encoded[i] = make(map[string][]byte, len(coll.Properties))
+		for propName, propValue := range coll.Properties {
+			jsonValue, err := json.Marshal(propValue)
+			if err != nil {
+				return 0, 0, fmt.Errorf("%w: property %s: %v", ErrValidation, propName, err)
+			}
+			encoded[i][propName] = jsonValue
+
+			if exists && doc.collections[coll.Collection] != nil {
+				prop := doc.collections[coll.Collection].properties[propName]
+				if prop != nil && !expired(prop.expiresAt, now) && !bytes.Equal(prop.value, jsonValue) &&
+					!operations.atomic(i, propName) && propertyConflict(prop.version, version, current, opts) {
+					return 0, 0, ErrVersionConflict
+				}
+			}
+		}
+	}
+
+	if !exists {
+		doc = &memoryDocument{collections: make(map[string]*memoryCollection)}
+		// Names may alias request buffers, so keep copies
+		docs[strings.Clone(documentName)] = doc
+	}
+
+	// Expired collections and properties are removed too, covered by this write's version bump
+	documentUpdated := pruneMemoryDocument(doc, now)
+	if expiryChanged(doc.expiresAt, expiry.document) {
+		doc.expiresAt = expiry.document
+		documentUpdated = true
+	}
+
+	for i, coll := range collections {
+		collection, ok := doc.collections[coll.Collection]
+		if !ok {
+			collection = collectionFor(coll.Collection)
+			doc.collections[strings.Clone(coll.Collection)] = collection
+			documentUpdated = true
+		}
+		if expiryChanged(collection.expiresAt, expiry.collections[i]) {
+			collection.expiresAt = expiry.collections[i]
+			documentUpdated = true
+		}
+
+		for propName, jsonValue := range encoded[i] {
+			prop, ok := collection.properties[propName]
+			expiresAt := expiry.properties[i][propName]
+			if ok && bytes.Equal(prop.value, jsonValue) && !expiryChanged(prop.expiresAt, expiresAt) {
+				continue
+			}
+			if !ok {
+				prop = &memoryProperty{createdAt: now}
+				collection.properties[strings.Clone(propName)] = prop
+			}
+			prop.value = jsonValue
+			prop.version = current + 1
+			prop.modifiedBy = opts.Actor
+			prop.updatedAt = now
+			if expiresAt != nil {
+				prop.expiresAt = expiresAt
+			}
+			documentUpdated = true
+		}
+
+		for _, propName := range operations.unsetIn(i) {
+			if _, ok := collection.properties[propName]; ok {
+				delete(collection.properties, propName)
+				documentUpdated = true
+			}
+		}
+	}
+
+	if !documentUpdated {
+		return doc.version, 0, nil
+	}
+
+	doc.version++
+	return doc.version, 1, nil
+}
+
+// pruneMemoryDocument removes a document's expired collections and properties, reporting whether any were removed
+func pruneMemoryDocument(doc *memoryDocument, now time.Time) bool {
+	pruned := false
+	for name, coll := range doc.collections {
+		if expired(coll.expiresAt, now) {
+			delete(doc.collections, name)
+			pruned = true
+			continue
+		}
+		for propName, prop := range coll.properties {
+			if expired(prop.expiresAt, now) {
+				delete(coll.properties, propName)
+				pruned = true
+			}
+		}
+	}
+	return pruned
+}
+
+// deleteMemoryCollection removes a collection from a document, passing it to trash first
+func deleteMemoryCollection(docs map[string]*memoryDocument, documentName string, version uint64, collectionName string, opts WriteOptions, trash func(*memoryDocument, string)) (uint64, int64, error) {
+	now := time.Now()
+	doc, ok := liveMemoryDocument(docs, documentName, now)
+	if !ok {
+		return 0, 0, missingMemoryDocument(opts)
+	}
+	if versionMismatch(doc.version, version, opts) {
+		return 0, 0, ErrVersionConflict
+	}
+
+	// Expired collections and properties are removed first, so they are neither found nor trashed
+	pruneMemoryDocument(doc, now)
+	if _, ok := doc.collections[collectionName]; !ok {
+		return 0, 0, fmt.Errorf("collection %w", ErrNotFound)
+	}
+
+	trash(doc, collectionName)
+	delete(doc.collections, collectionName)
+	doc.version++
+
+	return doc.version, 1, nil
+}
+
+// deleteMemoryProperties removes a document, or collections and properties from it.
+// Whole documents and collections are passed to trash before removal.
+func deleteMemoryProperties(docs map[string]*memoryDocument, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions, trash func(*memoryDocument, string)) (uint64, int64, error) {
+	now := time.Now()
+	doc, ok := liveMemoryDocument(docs, documentName, now)
+	if !ok {
+		return 0, 0, missingMemoryDocument(opts)
+	}
+	if versionMismatch(doc.version, version, opts) {
+		return 0, 0, ErrVersionConflict
+	}
+
+	// Expired collections and properties are removed first, so they are neither found nor trashed
+	documentUpdated := pruneMemoryDocument(doc, now)
+
+	if deleteDocument {
+		trash(doc, "")
+		delete(docs, documentName)
+		return 0, 1, nil
+	}
+
+	for _, coll := range collections {
+		collection, ok := doc.collections[coll.Collection]
+		if !ok {
+			continue // Collection not found, skip
+		}
+
+		// If no properties specified, delete entire collection
+		if len(coll.Properties) == 0 {
+			trash(doc, coll.Collection)
+			delete(doc.collections, coll.Collection)
+			documentUpdated = true
+			continue
+		}
+
+		for _, propName := range coll.Properties {
+			if _, ok := collection.properties[propName]; ok {
+				delete(collection.properties, propName)
+				documentUpdated = true
+			}
+		}
+	}
+
+	if !documentUpdated {
+		return doc.version, 0, nil
+	}
+
+	doc.version++
+	return doc.version, 1, nil
+}
+
+// hasExpired reports whether a document is expired or holds expired collections or properties
+func (doc *memoryDocument) hasExpired(now time.Time) bool {
+	if expired(doc.expiresAt, now) {
+		return true
+	}
+	for _, coll := range doc.collections {
+		if expired(coll.expiresAt, now) {
+			return true
+		}
+		for _, prop := range coll.properties {
+			if expired(prop.expiresAt, now) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sweepMemoryDocuments removes expired data from docs, returning an event for each changed document
+func sweepMemoryDocuments(docs map[string]*memoryDocument, scope MutationEvent, now time.Time) []MutationEvent {
+	var affected []string
+	for documentName, doc := range docs {
+		if doc.hasExpired(now) {
+			affected = append(affected, documentName)
+		}
+	}
+
+	events := make([]MutationEvent, 0, len(affected))
+	for _, documentName := range affected {
+		event := scope
+		event.Document = documentName
+
+		doc := docs[documentName]
+		if expired(doc.expiresAt, now) {
+			delete(docs, documentName)
+		} else {
+			pruneMemoryDocument(doc, now)
+			doc.version++
+			event.Version = doc.version
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// memoryTrashEntries lists trash entries, most recent first
+func memoryTrashEntries(entries []memoryTrash) ([]TrashEntry, error) {
+	result := make([]TrashEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		trashEntry, err := newTrashEntry(entry.id, entry.document, entry.collection, entry.version, entry.content, entry.deletedBy, entry.deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, trashEntry)
+	}
+	return result, nil
+}
+
+// restoreMemoryTrash restores the most recent trash entry for a document, or for one of its collections,
+// returning the remaining trash. Existing documents and collections are never overwritten.
+func restoreMemoryTrash(entries []memoryTrash, docs map[string]*memoryDocument, documentName, collectionName string, opts WriteOptions, collectionFor func(string) *memoryCollection) ([]memoryTrash, uint64, int64, error) {
+	index := -1
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].document == documentName && (collectionName == "" || entries[i].collection == collectionName) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return entries, 0, 0, ErrNotFound
+	}
+	entry := entries[index]
+
+	var current uint64
+	now := time.Now()
+	doc, live := liveMemoryDocument(docs, documentName, now)
+	if live {
+		if entry.collection == "" {
+			return entries, 0, 0, fmt.Errorf("document %w", ErrExists)
+		}
+		if liveMemoryCollection(doc, entry.collection, now) {
+			return entries, 0, 0, fmt.Errorf("collection %w", ErrExists)
+		}
+		current = doc.version
+	}
+
+	t, err := decodeTrashContent(entry.content)
+	if err != nil {
+		return entries, 0, 0, err
+	}
+
+	// Restore past the deleted version, so writes based on it or earlier still conflict
+	previous, had := docs[documentName]
+	if current < entry.version {
+		if live {
+			doc.version = entry.version
+		} else {
+			docs[strings.Clone(documentName)] = &memoryDocument{version: entry.version, collections: make(map[string]*memoryCollection)}
+		}
+		current = entry.version
+	}
+
+	newVersion, affectedRows, err := setMemoryProperties(docs, documentName, current, t.collectionInputs(), opts, collectionFor)
+	if err != nil {
+		if live {
+			doc.version = previous.version
+		} else if had {
+			docs[documentName] = previous
+		} else {
+			delete(docs, documentName)
+		}
+		return entries, 0, 0, err
+	}
+
+	return append(entries[:index], entries[index+1:]...), newVersion, affectedRows, nil
+}
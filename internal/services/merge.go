@@ -0,0 +1,102 @@
+// merge.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MergeStrategy selects how a write with a stale base version is handled
+type MergeStrategy string
+
+const (
+	// MergeNone rejects any write whose base version is not the current document version
+	MergeNone MergeStrategy = ""
+	// MergeProperties accepts a stale base version when none of the written properties changed since it
+	MergeProperties MergeStrategy = "merge"
+	// MergeAtomic accepts any base version for writes made only of atomic operations
+	MergeAtomic MergeStrategy = "atomic"
+)
+
+// WriteOptions controls optional mutation behavior
+type WriteOptions struct {
+	MergeStrategy MergeStrategy
+	Actor         string // Recorded as the last writer of changed properties
+	Expiry        Expiry // Optional document expiry
+	AnyVersion    bool   // Applies to whatever version the document has, If-Match: *. A missing document conflicts
+}
+
+// ParseMergeStrategy parses the mergeStrategy request value, "none", "merge" or "atomic"
+func ParseMergeStrategy(value string) (MergeStrategy, error) {
+	switch value {
+	case "", "none":
+		return MergeNone, nil
+	case string(MergeProperties):
+		return MergeProperties, nil
+	case string(MergeAtomic):
+		return MergeAtomic, nil
+	}
+	return MergeNone, fmt.Errorf("%w: unknown mergeStrategy %q", ErrValidation, value)
+}
+
+// baseVersionConflict reports whether a write based on version conflicts with the document's current version.
+// In merge mode an older base is allowed through to the per-property check, propertyConflict.
+// Atomic writes apply to whatever the current version is.
+func baseVersionConflict(exists bool, current, version uint64, opts WriteOptions) bool {
+	if opts.AnyVersion {
+		return !exists
+	}
+	if opts.MergeStrategy == MergeAtomic {
+		return false
+	}
+	if !exists {
+		return version != 0
+	}
+	if current == version {
+		return false
+	}
+	return opts.MergeStrategy != MergeProperties || version > current
+}
+
+// versionMismatch reports whether a write based on version does not apply to an existing document at current
+func versionMismatch(current, version uint64, opts WriteOptions) bool {
+	return !opts.AnyVersion && current != version
+}
+
+// documentLookupError is lookupError for the document a write applies to.
+// With AnyVersion a missing document is a version conflict, as the write expected one to exist.
+func documentLookupError(err error, opts WriteOptions) error {
+	err = lookupError(err)
+	if opts.AnyVersion && errors.Is(err, ErrNotFound) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// propertyConflict reports whether a property being changed was itself changed after the base version.
+// Only a merge write on a stale base is checked, and a version past current was written by another
+// document sharing the app collection, so it is not this document's change.
+func propertyConflict(propertyVersion, version, current uint64, opts WriteOptions) bool {
+	if opts.MergeStrategy != MergeProperties || version >= current {
+		return false
+	}
+	return propertyVersion > version && propertyVersion <= current
+}
@@ -0,0 +1,63 @@
+// meta.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetaKey is the document key holding property metadata when ReadOptions.IncludeMeta is set
+const MetaKey = "__meta"
+
+// DocumentMeta holds property metadata by collection, then property name
+type DocumentMeta map[string]map[string]PropertyMeta
+
+// PropertyMeta describes when, at which document version, and by whom a property last changed
+type PropertyMeta struct {
+	Version    string     `json:"version"`
+	ModifiedBy string     `json:"modifiedBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// addPropertyMeta records the metadata of a property included in a document result
+func addPropertyMeta(docMap map[string]interface{}, collection, property string, version uint64, modifiedBy string, createdAt, updatedAt time.Time, expiresAt *time.Time) {
+	meta, ok := docMap[MetaKey].(DocumentMeta)
+	if !ok {
+		meta = make(DocumentMeta)
+		docMap[MetaKey] = meta
+	}
+	if meta[collection] == nil {
+		meta[collection] = make(map[string]PropertyMeta)
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+	meta[collection][property] = PropertyMeta{
+		Version:    fmt.Sprintf("%d", version),
+		ModifiedBy: modifiedBy,
+		CreatedAt:  createdAt.UTC(),
+		UpdatedAt:  updatedAt.UTC(),
+		ExpiresAt:  expiresAt,
+	}
+}
@@ -0,0 +1,166 @@
+// metrics.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Changed entities
+const (
+	entityDocument   = "document"
+	entityCollection = "collection"
+	entityProperty   = "property"
+)
+
+// Change actions
+const (
+	actionCreated = "created"
+	actionUpdated = "updated"
+	actionDeleted = "deleted"
+)
+
+var (
+	versionConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "propsdb_version_conflicts_total",
+		Help: "Number of writes rejected for a stale document or property version",
+	}, []string{"scope"})
+	dataChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "propsdb_data_changes_total",
+		Help: "Number of documents, collections and properties created, updated or deleted by committed transactions",
+	}, []string{"scope", "entity", "action"})
+	orphansRemoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "propsdb_orphans_removed_total",
+		Help: "Number of collections and properties no longer referenced, removed by committed transactions",
+	}, []string{"scope", "entity"})
+	transactionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "propsdb_transaction_duration_seconds",
+		Help:    "Duration of data transactions, by outcome: committed, conflict or error",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"scope", "operation", "outcome"})
+	lockWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "propsdb_lock_wait_seconds",
+		Help:    "Duration of the statement locking a document row, mostly the wait for the lock under contention",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"scope"})
+	authValidationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "propsdb_auth_validation_duration_seconds",
+		Help:    "Duration of Authorizer session and token validation, by method and outcome: valid or invalid",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+)
+
+// changeKey identifies a counted change
+type changeKey struct {
+	entity string
+	action string
+}
+
+// changeTally collects the changes of a transaction, counted only once it commits
+type changeTally struct {
+	changes map[changeKey]int64
+	orphans map[string]int64
+}
+
+// tallyKey is the context key of a transaction's changeTally
+type tallyKey struct{}
+
+// transaction runs fn in a transaction on db, recording its duration and outcome, any version conflict,
+// and the changes fn records once they commit
+func transaction(db *gorm.DB, scope, operation string, fn func(tx *gorm.DB) error) error {
+	tally := &changeTally{changes: make(map[changeKey]int64), orphans: make(map[string]int64)}
+	ctx := context.WithValue(db.Statement.Context, tallyKey{}, tally)
+
+	start := time.Now()
+	err := db.WithContext(ctx).Transaction(fn)
+
+	outcome := "committed"
+	switch {
+	case errors.Is(err, ErrVersionConflict):
+		outcome = "conflict"
+		versionConflicts.WithLabelValues(scope).Inc()
+	case err != nil:
+		outcome = "error"
+	}
+	transactionDuration.WithLabelValues(scope, operation, outcome).Observe(time.Since(start).Seconds())
+
+	if err == nil {
+		for key, count := range tally.changes {
+			dataChanges.WithLabelValues(scope, key.entity, key.action).Add(float64(count))
+		}
+		for entity, count := range tally.orphans {
+			orphansRemoved.WithLabelValues(scope, entity).Add(float64(count))
+		}
+	}
+
+	return err
+}
+
+// tallyOf returns the changeTally of the transaction tx, nil outside transaction()
+func tallyOf(tx *gorm.DB) *changeTally {
+	tally, _ := tx.Statement.Context.Value(tallyKey{}).(*changeTally)
+	return tally
+}
+
+// recordChanges counts changes to entities made in the transaction tx
+func recordChanges(tx *gorm.DB, entity, action string, count int64) {
+	if tally := tallyOf(tx); tally != nil && count > 0 {
+		tally.changes[changeKey{entity, action}] += count
+	}
+}
+
+// recordOrphans counts entities removed by orphan cleanup in the transaction tx
+func recordOrphans(tx *gorm.DB, entity string, count int64) {
+	if tally := tallyOf(tx); tally != nil && count > 0 {
+		tally.orphans[entity] += count
+	}
+}
+
+// lockingQuery starts a lookup locking the document rows it reads, with a quiet logger since a missing
+// document is expected, that records the duration of the locking statement
+func lockingQuery(tx *gorm.DB, scope string) *gorm.DB {
+	return withLocking(tx).Session(&gorm.Session{
+		Logger: lockWaitLogger{Interface: tx.Logger.LogMode(logger.Silent), scope: scope},
+	})
+}
+
+// lockWaitLogger observes the duration of each statement it traces as lock wait
+type lockWaitLogger struct {
+	logger.Interface
+	scope string
+}
+
+// LogMode keeps the lock wait observation at any log level
+func (l lockWaitLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return lockWaitLogger{Interface: l.Interface.LogMode(level), scope: l.scope}
+}
+
+// Trace observes the statement duration, then passes it on
+func (l lockWaitLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	lockWait.WithLabelValues(l.scope).Observe(time.Since(begin).Seconds())
+	l.Interface.Trace(ctx, begin, fc, err)
+}
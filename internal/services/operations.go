@@ -0,0 +1,471 @@
+// operations.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operations are atomic updates of a collection's property values, applied to the current values
+// inside the write transaction. Keys are operators, and values map a path to the operand.
+// A path is a property name, optionally followed by a dot separated JSON path inside its value.
+type Operations map[string]map[string]interface{}
+
+// Atomic operators
+const (
+	OpInc      = "$inc"      // Adds a number
+	OpMin      = "$min"      // Keeps the smaller number
+	OpMax      = "$max"      // Keeps the larger number
+	OpPush     = "$push"     // Appends a value, or the values of {"$each": [...]}, to an array
+	OpAddToSet = "$addToSet" // Appends like $push, skipping values already present
+	OpPull     = "$pull"     // Removes array elements equal to the value
+	OpUnset    = "$unset"    // Removes a property, object key or array element. The operand is ignored
+)
+
+// operators lists the atomic operators in the order they are applied
+var operators = []string{OpUnset, OpInc, OpMin, OpMax, OpPush, OpAddToSet, OpPull}
+
+// operation is one operator applied to one path
+type operation struct {
+	operator string
+	path     []string
+	operand  interface{}
+}
+
+// list validates the operations and returns them in a stable order
+func (o Operations) list() ([]operation, error) {
+	var list []operation
+	for operator := range o {
+		if !isOperator(operator) {
+			return nil, fmt.Errorf("%w: unknown operator %s", ErrValidation, operator)
+		}
+	}
+
+	for _, operator := range operators {
+		paths := make([]string, 0, len(o[operator]))
+		for path := range o[operator] {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			segments := strings.Split(path, ".")
+			for _, segment := range segments {
+				if segment == "" {
+					return nil, fmt.Errorf("%w: %s path %q is invalid", ErrValidation, operator, path)
+				}
+			}
+			operand, err := normalizeOperand(o[operator][path])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s %s: %v", ErrValidation, operator, path, err)
+			}
+			list = append(list, operation{operator: operator, path: segments, operand: operand})
+		}
+	}
+
+	// Overlapping paths would make the result depend on the order of operators
+	for i := range list {
+		for j := i + 1; j < len(list); j++ {
+			if pathsOverlap(list[i].path, list[j].path) {
+				return nil, fmt.Errorf("%w: operations on %s and %s overlap", ErrValidation,
+					strings.Join(list[i].path, "."), strings.Join(list[j].path, "."))
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// properties returns the names of the properties the operations touch
+func (o Operations) properties() map[string]bool {
+	names := make(map[string]bool)
+	for _, paths := range o {
+		for path := range paths {
+			name, _, _ := strings.Cut(path, ".")
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// isOperator reports whether operator is a supported atomic operator
+func isOperator(operator string) bool {
+	for _, known := range operators {
+		if operator == known {
+			return true
+		}
+	}
+	return false
+}
+
+// pathsOverlap reports whether one path is equal to or inside the other
+func pathsOverlap(a, b []string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeOperand round trips an operand through JSON, so numbers compare as json.Number like stored values
+func normalizeOperand(operand interface{}) (interface{}, error) {
+	data, err := json.Marshal(operand)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(data)
+}
+
+// decodeJSON decodes a JSON value, keeping numbers exact
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// validateOperations checks the operations of a write. An atomic write may contain only operations.
+func validateOperations(collections []CollectionInput, opts WriteOptions) error {
+	for _, coll := range collections {
+		if opts.MergeStrategy == MergeAtomic && len(coll.Properties) > 0 {
+			return fmt.Errorf("%w: mergeStrategy %s allows only operations, collection %s has properties", ErrValidation, MergeAtomic, coll.Collection)
+		}
+		if _, err := coll.Operations.list(); err != nil {
+			return fmt.Errorf("collection %s: %w", coll.Collection, err)
+		}
+		for name := range coll.Operations.properties() {
+			if _, ok := coll.Properties[name]; ok {
+				return fmt.Errorf("%w: property %s is both set and operated on", ErrValidation, name)
+			}
+		}
+	}
+	return nil
+}
+
+// resolvedOperations are the property changes computed from a write's operations, by collection input index
+type resolvedOperations struct {
+	written []map[string]bool // Properties written by operations, exempt from the stale version check
+	unset   [][]string        // Properties removed by operations
+}
+
+// atomic reports whether operations wrote the named property of the collection input at index i
+func (r resolvedOperations) atomic(i int, name string) bool {
+	return i < len(r.written) && r.written[i][name]
+}
+
+// unsetIn returns the properties removed by operations from the collection input at index i
+func (r resolvedOperations) unsetIn(i int) []string {
+	if i < len(r.unset) {
+		return r.unset[i]
+	}
+	return nil
+}
+
+// removed reports whether operations removed any property
+func (r resolvedOperations) removed() bool {
+	for _, names := range r.unset {
+		if len(names) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOperations reports whether any collection of a write has operations
+func hasOperations(collections []CollectionInput) bool {
+	for _, coll := range collections {
+		if len(coll.Operations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveOperations applies the operations of each collection to its current property values, in content.
+// It returns the collections with the resulting values added to their properties.
+func resolveOperations(collections []CollectionInput, content trashContent) ([]CollectionInput, resolvedOperations, error) {
+	resolved := resolvedOperations{
+		written: make([]map[string]bool, len(collections)),
+		unset:   make([][]string, len(collections)),
+	}
+
+	result := make([]CollectionInput, len(collections))
+	for i, coll := range collections {
+		result[i] = coll
+		if len(coll.Operations) == 0 {
+			continue
+		}
+
+		values, unset, err := applyOperations(coll.Operations, content[coll.Collection])
+		if err != nil {
+			return nil, resolved, fmt.Errorf("collection %s: %w", coll.Collection, err)
+		}
+
+		properties := make(map[string]interface{}, len(coll.Properties)+len(values))
+		for name, value := range coll.Properties {
+			properties[name] = value
+		}
+		resolved.written[i] = make(map[string]bool, len(values))
+		for name, value := range values {
+			properties[name] = value
+			resolved.written[i][name] = true
+		}
+		result[i].Properties = properties
+		resolved.unset[i] = unset
+	}
+
+	return result, resolved, nil
+}
+
+// applyOperations applies operations to the current values of a collection's properties, given as JSON.
+// It returns the new value of each property kept, and the names of properties removed.
+func applyOperations(ops Operations, current map[string]json.RawMessage) (map[string]interface{}, []string, error) {
+	list, err := ops.list()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]interface{})
+	exists := make(map[string]bool)
+	for _, op := range list {
+		name := op.path[0]
+		if _, loaded := exists[name]; !loaded {
+			exists[name] = false
+			if raw, ok := current[name]; ok {
+				value, err := decodeJSON(raw)
+				if err != nil {
+					return nil, nil, err
+				}
+				values[name] = value
+				exists[name] = true
+			}
+		}
+
+		value, keep, err := applyAt(values[name], exists[name], op.path[1:], op.apply)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s %s: %v", ErrValidation, op.operator, strings.Join(op.path, "."), err)
+		}
+		if keep {
+			values[name] = value
+			exists[name] = true
+		} else {
+			delete(values, name)
+			exists[name] = false
+		}
+	}
+
+	var unset []string
+	for name, ok := range exists {
+		if !ok {
+			if _, was := current[name]; was {
+				unset = append(unset, name)
+			}
+		}
+	}
+	sort.Strings(unset)
+
+	return values, unset, nil
+}
+
+// applyAt applies fn at path inside value, creating objects along the way for values that are kept.
+// It returns the new value and whether it is kept.
+func applyAt(value interface{}, exists bool, path []string, fn func(interface{}, bool) (interface{}, bool, error)) (interface{}, bool, error) {
+	if len(path) == 0 {
+		return fn(value, exists)
+	}
+
+	if !exists {
+		child, keep, err := applyAt(nil, false, path[1:], fn)
+		if err != nil || !keep {
+			return nil, false, err
+		}
+		return map[string]interface{}{path[0]: child}, true, nil
+	}
+
+	switch container := value.(type) {
+	case map[string]interface{}:
+		child, ok := container[path[0]]
+		next, keep, err := applyAt(child, ok, path[1:], fn)
+		if err != nil {
+			return nil, false, err
+		}
+		if keep {
+			container[path[0]] = next
+		} else {
+			delete(container, path[0])
+		}
+		return container, true, nil
+	case []interface{}:
+		index, err := strconv.Atoi(path[0])
+		if err != nil || index < 0 || index > len(container) {
+			return nil, false, fmt.Errorf("%s is not an index of the array", path[0])
+		}
+		var child interface{}
+		if index < len(container) {
+			child = container[index]
+		}
+		next, keep, err := applyAt(child, index < len(container), path[1:], fn)
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case keep && index == len(container):
+			container = append(container, next)
+		case keep:
+			container[index] = next
+		case index < len(container):
+			container = append(container[:index], container[index+1:]...)
+		}
+		return container, true, nil
+	}
+	return nil, false, fmt.Errorf("%s is not inside an object or array", path[0])
+}
+
+// apply applies the operation to a value, returning the new value and whether it is kept
+func (op operation) apply(value interface{}, exists bool) (interface{}, bool, error) {
+	switch op.operator {
+	case OpUnset:
+		return nil, false, nil
+	case OpInc, OpMin, OpMax:
+		operand, ok := op.operand.(json.Number)
+		if !ok {
+			return nil, false, fmt.Errorf("operand must be a number")
+		}
+		if !exists {
+			return operand, true, nil
+		}
+		current, ok := value.(json.Number)
+		if !ok {
+			return nil, false, fmt.Errorf("value is not a number")
+		}
+		return combineNumbers(op.operator, current, operand), true, nil
+	case OpPush, OpAddToSet:
+		items := op.eachOperand()
+		array, err := arrayValue(value, exists)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, item := range items {
+			if op.operator == OpAddToSet && containsValue(array, item) {
+				continue
+			}
+			array = append(array, item)
+		}
+		return array, true, nil
+	case OpPull:
+		if !exists {
+			return nil, false, nil
+		}
+		array, err := arrayValue(value, exists)
+		if err != nil {
+			return nil, false, err
+		}
+		kept := make([]interface{}, 0, len(array))
+		for _, item := range array {
+			if !reflect.DeepEqual(item, op.operand) {
+				kept = append(kept, item)
+			}
+		}
+		return kept, true, nil
+	}
+	return nil, false, fmt.Errorf("unknown operator")
+}
+
+// eachOperand returns the values to add, from {"$each": [...]} or the single operand
+func (op operation) eachOperand() []interface{} {
+	if object, ok := op.operand.(map[string]interface{}); ok && len(object) == 1 {
+		if each, ok := object["$each"].([]interface{}); ok {
+			return each
+		}
+	}
+	return []interface{}{op.operand}
+}
+
+// arrayValue returns an existing array value, or an empty one when there is none
+func arrayValue(value interface{}, exists bool) ([]interface{}, error) {
+	if !exists {
+		return []interface{}{}, nil
+	}
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an array")
+	}
+	return array, nil
+}
+
+// containsValue reports whether array holds a value equal to item
+func containsValue(array []interface{}, item interface{}) bool {
+	for _, element := range array {
+		if reflect.DeepEqual(element, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// combineNumbers applies $inc, $min or $max to two numbers, in integers when both are integers
+func combineNumbers(operator string, current, operand json.Number) json.Number {
+	a, aErr := current.Int64()
+	b, bErr := operand.Int64()
+	if aErr == nil && bErr == nil {
+		switch operator {
+		case OpInc:
+			return json.Number(strconv.FormatInt(a+b, 10))
+		case OpMin:
+			if b < a {
+				return operand
+			}
+		case OpMax:
+			if b > a {
+				return operand
+			}
+		}
+		return current
+	}
+
+	x, _ := current.Float64()
+	y, _ := operand.Float64()
+	switch operator {
+	case OpInc:
+		return json.Number(strconv.FormatFloat(x+y, 'g', -1, 64))
+	case OpMin:
+		if y < x {
+			return operand
+		}
+	case OpMax:
+		if y > x {
+			return operand
+		}
+	}
+	return current
+}
@@ -0,0 +1,68 @@
+// periodic.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// periodicTask runs a function at startup and every interval until stopped
+type periodicTask struct {
+	stop context.CancelFunc
+	done sync.WaitGroup
+}
+
+// start runs fn now and then every interval, until stop, which cancels the context of a run in progress.
+// Starting a running task does nothing
+func (p *periodicTask) start(interval time.Duration, fn func(context.Context)) {
+	if interval <= 0 || p.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stop = cancel
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		fn(ctx)
+		for {
+			select {
+			case <-ticker.C:
+				fn(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the task, canceling a run in progress, and waits for it
+func (p *periodicTask) Stop() {
+	if p.stop != nil {
+		p.stop()
+		p.done.Wait()
+		p.stop = nil
+	}
+}
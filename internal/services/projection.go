@@ -0,0 +1,182 @@
+// projection.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReadOptions controls the shape of GET results
+type ReadOptions struct {
+	Fields      Projection
+	IncludeMeta bool            // Adds property metadata to each document under MetaKey
+	OmitActors  bool            // Leaves modifiedBy out of the property metadata, for public reads
+	OnExpiry    func(time.Time) // Optional, called with the expiry of each expiring document, collection and property read
+}
+
+// actor returns modifiedBy, or nothing if actors are omitted
+func (o ReadOptions) actor(modifiedBy string) string {
+	if o.OmitActors {
+		return ""
+	}
+	return modifiedBy
+}
+
+// expiring reports expiresAt to OnExpiry, if both are set
+func (o ReadOptions) expiring(expiresAt *time.Time) {
+	if o.OnExpiry != nil && expiresAt != nil {
+		o.OnExpiry(*expiresAt)
+	}
+}
+
+// Projection selects properties, and optionally JSON paths inside property values.
+// Keys are property names, values are the paths requested inside that property.
+// A nil path selects the whole property value. A nil Projection selects everything.
+type Projection map[string][][]string
+
+// ParseProjection builds a Projection from field expressions of the form "property[.path.in.value]"
+func ParseProjection(fields []string) Projection {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	projection := make(Projection)
+	for _, field := range fields {
+		var segments []string
+		for _, segment := range strings.Split(field, ".") {
+			if segment = strings.TrimSpace(segment); segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+		if len(segments) == 0 {
+			continue
+		}
+
+		name := segments[0]
+		if len(segments) == 1 {
+			projection[name] = append(projection[name], nil)
+		} else {
+			projection[name] = append(projection[name], segments[1:])
+		}
+	}
+
+	if len(projection) == 0 {
+		return nil
+	}
+	return projection
+}
+
+// PropertyNames returns the selected property names in a stable order
+func (p Projection) PropertyNames() []string {
+	if len(p) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// apply projects a property value, returning false if nothing selected remains
+func (p Projection) apply(name string, value interface{}) (interface{}, bool) {
+	if p == nil {
+		return value, true
+	}
+
+	paths, ok := p[name]
+	if !ok {
+		return nil, false
+	}
+
+	var projected interface{}
+	for _, path := range paths {
+		if path == nil {
+			// The whole value was asked for, so any narrower paths are already covered
+			return value, true
+		}
+		if found, ok := lookupPath(value, path); ok {
+			projected = mergePath(projected, path, found)
+		}
+	}
+
+	return projected, projected != nil
+}
+
+// lookupPath walks a decoded JSON value along path, indexing objects by key and arrays by position
+func lookupPath(value interface{}, path []string) (interface{}, bool) {
+	current := value
+	for _, segment := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// mergePath places value into target at path, creating intermediate objects as needed
+func mergePath(target interface{}, path []string, value interface{}) interface{} {
+	root, ok := target.(map[string]interface{})
+	if !ok {
+		root = make(map[string]interface{})
+	}
+
+	node := root
+	for _, segment := range path[:len(path)-1] {
+		child, ok := node[segment].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			node[segment] = child
+		}
+		node = child
+	}
+	node[path[len(path)-1]] = value
+
+	return root
+}
+
+// preloadProperties preloads unexpired collection properties, pushing the projection's property names into the query
+func preloadProperties(query *gorm.DB, opts ReadOptions, now time.Time) *gorm.DB {
+	if names := opts.Fields.PropertyNames(); len(names) > 0 {
+		return query.Preload("Collections.Properties", "property_name IN ? AND "+notExpired, names, now)
+	}
+	return query.Preload("Collections.Properties", notExpired, now)
+}
@@ -0,0 +1,237 @@
+// store.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"gorm.io/gorm"
+)
+
+// Store is the data access interface for application and user documents.
+// Implementations share the semantics of the GORM services, verified by the conformance tests.
+type Store interface {
+	GetApplicationProperties(ctx context.Context, documentName, collectionName string, opts ReadOptions) (DocumentResult, error)
+	GetApplicationCollectionsAndProperties(ctx context.Context, documentName string, collections []string, opts ReadOptions) (DocumentResult, error)
+	GetApplicationDocumentsCollectionsAndProperties(ctx context.Context, opts ReadOptions) (DocumentResult, error)
+	SetApplicationProperties(ctx context.Context, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error)
+	DeleteApplicationCollection(ctx context.Context, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error)
+	DeleteApplicationProperties(ctx context.Context, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error)
+	GetApplicationTrash(ctx context.Context) ([]TrashEntry, error)
+	RestoreApplicationTrash(ctx context.Context, documentName, collectionName string, opts WriteOptions) (uint64, int64, error)
+	CopyApplicationDocument(ctx context.Context, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error)
+	RenameApplicationDocument(ctx context.Context, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error)
+
+	GetUserProperties(ctx context.Context, userID, documentName, collectionName string, opts ReadOptions) (DocumentResult, error)
+	GetUserCollectionsAndProperties(ctx context.Context, userID, documentName string, collections []string, opts ReadOptions) (DocumentResult, error)
+	GetUserDocumentsCollectionsAndProperties(ctx context.Context, userID string, opts ReadOptions) (DocumentResult, error)
+	SetUserProperties(ctx context.Context, userID, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error)
+	DeleteUserCollection(ctx context.Context, userID, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error)
+	DeleteUserProperties(ctx context.Context, userID, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error)
+	GetUserTrash(ctx context.Context, userID string) ([]TrashEntry, error)
+	RestoreUserTrash(ctx context.Context, userID, documentName, collectionName string, opts WriteOptions) (uint64, int64, error)
+	CreateUserDocumentFromTemplate(ctx context.Context, userID, documentName, templateName string, opts WriteOptions) (uint64, int64, error)
+
+	PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error)
+
+	SweepExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// GormStore is the Store backed by a GORM database
+type GormStore struct {
+	DB      *gorm.DB
+	Timeout time.Duration // Optional, bounds each call in addition to the deadline of its context
+}
+
+// run calls fn with the call's context bound to Timeout, failing fast while the pool is unavailable
+func run[T any](ctx context.Context, s GormStore, fn func(context.Context, *gorm.DB) (T, error)) (T, error) {
+	if err := database.Available(s.DB); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	return fn(ctx, s.DB)
+}
+
+// write is run for mutations, which return the new document version and the affected count
+func write(ctx context.Context, s GormStore, fn func(context.Context, *gorm.DB) (uint64, int64, error)) (uint64, int64, error) {
+	var version uint64
+	var affected int64
+	_, err := run(ctx, s, func(ctx context.Context, db *gorm.DB) (struct{}, error) {
+		var err error
+		version, affected, err = fn(ctx, db)
+		return struct{}{}, err
+	})
+	return version, affected, err
+}
+
+// GetApplicationProperties retrieves properties for a specific document and collection
+func (s GormStore) GetApplicationProperties(ctx context.Context, documentName, collectionName string, opts ReadOptions) (DocumentResult, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (DocumentResult, error) {
+		return GetApplicationProperties(ctx, db, documentName, collectionName, opts)
+	})
+}
+
+// GetApplicationCollectionsAndProperties retrieves collections and properties for a document
+func (s GormStore) GetApplicationCollectionsAndProperties(ctx context.Context, documentName string, collections []string, opts ReadOptions) (DocumentResult, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (DocumentResult, error) {
+		return GetApplicationCollectionsAndProperties(ctx, db, documentName, collections, opts)
+	})
+}
+
+// GetApplicationDocumentsCollectionsAndProperties retrieves all documents, collections, and properties
+func (s GormStore) GetApplicationDocumentsCollectionsAndProperties(ctx context.Context, opts ReadOptions) (DocumentResult, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (DocumentResult, error) {
+		return GetApplicationDocumentsCollectionsAndProperties(ctx, db, opts)
+	})
+}
+
+// SetApplicationProperties upserts application document with collections and properties
+func (s GormStore) SetApplicationProperties(ctx context.Context, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return SetApplicationProperties(ctx, db, documentName, version, collections, opts)
+	})
+}
+
+// DeleteApplicationCollection deletes a collection from an application document
+func (s GormStore) DeleteApplicationCollection(ctx context.Context, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return DeleteApplicationCollection(ctx, db, documentName, version, collectionName, opts)
+	})
+}
+
+// DeleteApplicationProperties deletes properties or collections from an application document
+func (s GormStore) DeleteApplicationProperties(ctx context.Context, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return DeleteApplicationProperties(ctx, db, documentName, version, collections, deleteDocument, opts)
+	})
+}
+
+// GetApplicationTrash lists the deleted application documents and collections, most recent first
+func (s GormStore) GetApplicationTrash(ctx context.Context) ([]TrashEntry, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) ([]TrashEntry, error) {
+		return GetApplicationTrash(ctx, db)
+	})
+}
+
+// RestoreApplicationTrash restores a deleted application document or collection from the trash
+func (s GormStore) RestoreApplicationTrash(ctx context.Context, documentName, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return RestoreApplicationTrash(ctx, db, documentName, collectionName, opts)
+	})
+}
+
+// CopyApplicationDocument copies an application document to a new document
+func (s GormStore) CopyApplicationDocument(ctx context.Context, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return CopyApplicationDocument(ctx, db, documentName, version, targetName, opts)
+	})
+}
+
+// RenameApplicationDocument renames an application document
+func (s GormStore) RenameApplicationDocument(ctx context.Context, documentName string, version uint64, targetName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return RenameApplicationDocument(ctx, db, documentName, version, targetName, opts)
+	})
+}
+
+// GetUserProperties retrieves properties for a specific user document and collection
+func (s GormStore) GetUserProperties(ctx context.Context, userID, documentName, collectionName string, opts ReadOptions) (DocumentResult, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (DocumentResult, error) {
+		return GetUserProperties(ctx, db, userID, documentName, collectionName, opts)
+	})
+}
+
+// GetUserCollectionsAndProperties retrieves collections and properties for a user document
+func (s GormStore) GetUserCollectionsAndProperties(ctx context.Context, userID, documentName string, collections []string, opts ReadOptions) (DocumentResult, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (DocumentResult, error) {
+		return GetUserCollectionsAndProperties(ctx, db, userID, documentName, collections, opts)
+	})
+}
+
+// GetUserDocumentsCollectionsAndProperties retrieves all documents, collections, and properties for a user
+func (s GormStore) GetUserDocumentsCollectionsAndProperties(ctx context.Context, userID string, opts ReadOptions) (DocumentResult, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (DocumentResult, error) {
+		return GetUserDocumentsCollectionsAndProperties(ctx, db, userID, opts)
+	})
+}
+
+// SetUserProperties upserts user document with collections and properties
+func (s GormStore) SetUserProperties(ctx context.Context, userID, documentName string, version uint64, collections []CollectionInput, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return SetUserProperties(ctx, db, userID, documentName, version, collections, opts)
+	})
+}
+
+// DeleteUserCollection deletes a collection from a user document
+func (s GormStore) DeleteUserCollection(ctx context.Context, userID, documentName string, version uint64, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return DeleteUserCollection(ctx, db, userID, documentName, version, collectionName, opts)
+	})
+}
+
+// DeleteUserProperties deletes properties or collections from a user document
+func (s GormStore) DeleteUserProperties(ctx context.Context, userID, documentName string, version uint64, collections []DeleteCollectionInput, deleteDocument bool, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return DeleteUserProperties(ctx, db, userID, documentName, version, collections, deleteDocument, opts)
+	})
+}
+
+// GetUserTrash lists a user's deleted documents and collections, most recent first
+func (s GormStore) GetUserTrash(ctx context.Context, userID string) ([]TrashEntry, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) ([]TrashEntry, error) {
+		return GetUserTrash(ctx, db, userID)
+	})
+}
+
+// RestoreUserTrash restores a deleted user document or collection from the trash
+func (s GormStore) RestoreUserTrash(ctx context.Context, userID, documentName, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return RestoreUserTrash(ctx, db, userID, documentName, collectionName, opts)
+	})
+}
+
+// CreateUserDocumentFromTemplate creates a user document from an application document
+func (s GormStore) CreateUserDocumentFromTemplate(ctx context.Context, userID, documentName, templateName string, opts WriteOptions) (uint64, int64, error) {
+	return write(ctx, s, func(ctx context.Context, db *gorm.DB) (uint64, int64, error) {
+		return CreateUserDocumentFromTemplate(ctx, db, userID, documentName, templateName, opts)
+	})
+}
+
+// PurgeTrash permanently removes trash deleted before cutoff
+func (s GormStore) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (int64, error) {
+		return PurgeTrash(ctx, db, cutoff)
+	})
+}
+
+// SweepExpired removes data that expired before now
+func (s GormStore) SweepExpired(ctx context.Context, now time.Time) (int64, error) {
+	return run(ctx, s, func(ctx context.Context, db *gorm.DB) (int64, error) {
+		return SweepExpired(ctx, db, now)
+	})
+}
@@ -0,0 +1,466 @@
+// trash.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/localnerve/jam-build-propsdb/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TrashEntry is a deleted document, or a collection deleted from a document, awaiting restore or purge
+type TrashEntry struct {
+	ID          string                            `json:"id"`
+	Document    string                            `json:"document"`
+	Collection  string                            `json:"collection,omitempty"` // Empty when the whole document was deleted
+	Version     string                            `json:"version"`              // Document version at deletion
+	DeletedAt   time.Time                         `json:"deletedAt"`
+	DeletedBy   string                            `json:"deletedBy"`
+	Collections map[string]map[string]interface{} `json:"collections"`
+}
+
+// trashContent is the stored form of deleted collections, { collection: { property: value }}
+type trashContent map[string]map[string]json.RawMessage
+
+// encode marshals the content for a trash row
+func (t trashContent) encode() (models.JSON, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return models.JSON{}, err
+	}
+	return models.JSON{JSON: datatypes.JSON(data)}, nil
+}
+
+// collectionInputs converts the content to the input of a restoring write
+func (t trashContent) collectionInputs() []CollectionInput {
+	collections := make([]CollectionInput, 0, len(t))
+	for name, properties := range t {
+		input := CollectionInput{Collection: name, Properties: make(map[string]interface{}, len(properties))}
+		for propName, value := range properties {
+			input.Properties[propName] = value
+		}
+		collections = append(collections, input)
+	}
+	return collections
+}
+
+// decodeTrashContent unmarshals the content of a trash row
+func decodeTrashContent(content models.JSON) (trashContent, error) {
+	var t trashContent
+	if len(content.JSON) == 0 {
+		return t, nil
+	}
+	if err := json.Unmarshal(content.JSON, &t); err != nil {
+		return nil, fmt.Errorf("invalid trash content: %w", err)
+	}
+	return t, nil
+}
+
+// rawPropertyValue returns a stored property value as JSON, null when empty
+func rawPropertyValue(value models.JSON) json.RawMessage {
+	if len(value.JSON) == 0 {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(value.JSON)
+}
+
+// newTrashEntry builds the API form of a trash row
+func newTrashEntry(id uint64, documentName, collectionName string, version uint64, content models.JSON, deletedBy string, deletedAt time.Time) (TrashEntry, error) {
+	t, err := decodeTrashContent(content)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	collections := make(map[string]map[string]interface{}, len(t))
+	for name, properties := range t {
+		collMap := make(map[string]interface{}, len(properties))
+		for propName, raw := range properties {
+			var value interface{}
+			if err := json.Unmarshal(raw, &value); err == nil {
+				collMap[propName] = value
+			}
+		}
+		collections[name] = collMap
+	}
+
+	return TrashEntry{
+		ID:          strconv.FormatUint(id, 10),
+		Document:    documentName,
+		Collection:  collectionName,
+		Version:     strconv.FormatUint(version, 10),
+		DeletedAt:   deletedAt,
+		DeletedBy:   deletedBy,
+		Collections: collections,
+	}, nil
+}
+
+// loadApplicationContent loads the unexpired collections of a document, or one of them when collectionName is given
+func loadApplicationContent(tx *gorm.DB, documentID uint64, collectionName string, now time.Time) (trashContent, error) {
+	query := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)})
+	if collectionName != "" {
+		query = query.Preload("Collections", "collection_name = ? AND "+notExpired, collectionName, now)
+	} else {
+		query = query.Preload("Collections", notExpired, now)
+	}
+
+	var loaded models.ApplicationDocument
+	if err := query.Preload("Collections.Properties", notExpired, now).
+		Where("document_id = ?", documentID).
+		First(&loaded).Error; err != nil {
+		return nil, err
+	}
+
+	t := make(trashContent, len(loaded.Collections))
+	for _, coll := range loaded.Collections {
+		properties := make(map[string]json.RawMessage, len(coll.Properties))
+		for _, prop := range coll.Properties {
+			properties[prop.PropertyName] = rawPropertyValue(prop.PropertyValue)
+		}
+		t[coll.CollectionName] = properties
+	}
+	return t, nil
+}
+
+// trashApplicationDocument copies a document, or one of its collections when collectionName is given, to the trash
+func trashApplicationDocument(tx *gorm.DB, doc models.ApplicationDocument, collectionName, actor string) error {
+	t, err := loadApplicationContent(tx, doc.DocumentID, collectionName, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	content, err := t.encode()
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.ApplicationTrash{
+		DocumentName:    doc.DocumentName,
+		CollectionName:  collectionName,
+		DocumentVersion: doc.DocumentVersion,
+		Content:         content,
+		DeletedBy:       actor,
+		DeletedAt:       time.Now().UTC(),
+	}).Error
+}
+
+// loadUserContent loads the unexpired collections of a document, or one of them when collectionName is given
+func loadUserContent(tx *gorm.DB, documentID uint64, collectionName string, now time.Time) (trashContent, error) {
+	query := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)})
+	if collectionName != "" {
+		query = query.Preload("Collections", "collection_name = ? AND "+notExpired, collectionName, now)
+	} else {
+		query = query.Preload("Collections", notExpired, now)
+	}
+
+	var loaded models.UserDocument
+	if err := query.Preload("Collections.Properties", notExpired, now).
+		Where("document_id = ?", documentID).
+		First(&loaded).Error; err != nil {
+		return nil, err
+	}
+
+	t := make(trashContent, len(loaded.Collections))
+	for _, coll := range loaded.Collections {
+		properties := make(map[string]json.RawMessage, len(coll.Properties))
+		for _, prop := range coll.Properties {
+			properties[prop.PropertyName] = rawPropertyValue(prop.PropertyValue)
+		}
+		t[coll.CollectionName] = properties
+	}
+	return t, nil
+}
+
+// trashUserDocument copies a user document, or one of its collections when collectionName is given, to the trash
+func trashUserDocument(tx *gorm.DB, doc models.UserDocument, collectionName, actor string) error {
+	t, err := loadUserContent(tx, doc.DocumentID, collectionName, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	content, err := t.encode()
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.UserTrash{
+		UserID:          doc.UserID,
+		DocumentName:    doc.DocumentName,
+		CollectionName:  collectionName,
+		DocumentVersion: doc.DocumentVersion,
+		Content:         content,
+		DeletedBy:       actor,
+		DeletedAt:       time.Now().UTC(),
+	}).Error
+}
+
+// GetApplicationTrash lists the deleted application documents and collections, most recent first
+func GetApplicationTrash(ctx context.Context, db *gorm.DB) ([]TrashEntry, error) {
+	db = db.WithContext(ctx)
+	var rows []models.ApplicationTrash
+	if err := db.Order("deleted_at DESC, trash_id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]TrashEntry, 0, len(rows))
+	for _, row := range rows {
+		entry, err := newTrashEntry(row.TrashID, row.DocumentName, row.CollectionName, row.DocumentVersion, row.Content, row.DeletedBy, row.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// GetUserTrash lists a user's deleted documents and collections, most recent first
+func GetUserTrash(ctx context.Context, db *gorm.DB, userID string) ([]TrashEntry, error) {
+	db = db.WithContext(ctx)
+	var rows []models.UserTrash
+	if err := db.Where("user_id = ?", userID).Order("deleted_at DESC, trash_id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]TrashEntry, 0, len(rows))
+	for _, row := range rows {
+		entry, err := newTrashEntry(row.TrashID, row.DocumentName, row.CollectionName, row.DocumentVersion, row.Content, row.DeletedBy, row.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// RestoreApplicationTrash restores the most recently deleted copy of an application document,
+// or of one of its collections when collectionName is given, and removes it from the trash.
+// A deleted document is only restored when no document of that name exists, and a deleted collection
+// only when its document does not have it, otherwise ErrExists is returned.
+func RestoreApplicationTrash(ctx context.Context, db *gorm.DB, documentName, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	var newVersion uint64
+	var affectedRows int64
+
+	now := time.Now().UTC()
+	err := transaction(db, ScopeApp, "restore", func(tx *gorm.DB) error {
+		var entry models.ApplicationTrash
+		query := tx.Where("document_name = ?", documentName)
+		if collectionName != "" {
+			query = query.Where("collection_name = ?", collectionName)
+		}
+		if err := query.Order("deleted_at DESC, trash_id DESC").First(&entry).Error; err != nil {
+			return lookupError(err)
+		}
+
+		t, err := decodeTrashContent(entry.Content)
+		if err != nil {
+			return err
+		}
+
+		var doc models.ApplicationDocument
+		err = lockingQuery(tx, ScopeApp).
+			Where("document_name = ?", documentName).
+			Where(notExpired, now).
+			First(&doc).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if entry.CollectionName == "" {
+				return fmt.Errorf("document %w", ErrExists)
+			}
+			if tx.Model(&doc).Where("collection_name = ?", entry.CollectionName).Where(notExpired, now).Association("Collections").Count() > 0 {
+				return fmt.Errorf("collection %w", ErrExists)
+			}
+		}
+
+		// Restore past the deleted version, so writes based on it or earlier still conflict
+		version := doc.DocumentVersion
+		if version < entry.DocumentVersion {
+			if doc.DocumentID != 0 {
+				err = tx.Model(&doc).Update("document_version", entry.DocumentVersion).Error
+			} else {
+				err = restoreApplicationDocument(tx, documentName, entry.DocumentVersion, now)
+			}
+			if err != nil {
+				return err
+			}
+			version = entry.DocumentVersion
+		}
+
+		newVersion, affectedRows, err = setApplicationProperties(tx, documentName, version, t.collectionInputs(), opts, true)
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&entry).Error
+	})
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeApp, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// restoreApplicationDocument creates an empty application document at the version it was deleted at
+func restoreApplicationDocument(tx *gorm.DB, documentName string, version uint64, now time.Time) error {
+	if err := claimApplicationDocument(tx, documentName, now); err != nil {
+		return err
+	}
+	if err := tx.Create(&models.ApplicationDocument{DocumentName: documentName, DocumentVersion: version}).Error; err != nil {
+		return err
+	}
+	recordChanges(tx, entityDocument, actionCreated, 1)
+	return nil
+}
+
+// restoreUserDocument creates an empty user document at the version it was deleted at
+func restoreUserDocument(tx *gorm.DB, userID, documentName string, version uint64, now time.Time) error {
+	if err := claimUserDocument(tx, userID, documentName, now); err != nil {
+		return err
+	}
+	if err := tx.Create(&models.UserDocument{UserID: userID, DocumentName: documentName, DocumentVersion: version}).Error; err != nil {
+		return err
+	}
+	recordChanges(tx, entityDocument, actionCreated, 1)
+	return nil
+}
+
+// RestoreUserTrash restores the most recently deleted copy of a user document,
+// or of one of its collections when collectionName is given, and removes it from the trash.
+// Existing data is never overwritten, as with RestoreApplicationTrash.
+func RestoreUserTrash(ctx context.Context, db *gorm.DB, userID, documentName, collectionName string, opts WriteOptions) (uint64, int64, error) {
+	db = db.WithContext(ctx)
+	var newVersion uint64
+	var affectedRows int64
+
+	now := time.Now().UTC()
+	err := transaction(db, ScopeUser, "restore", func(tx *gorm.DB) error {
+		var entry models.UserTrash
+		query := tx.Where("user_id = ? AND document_name = ?", userID, documentName)
+		if collectionName != "" {
+			query = query.Where("collection_name = ?", collectionName)
+		}
+		if err := query.Order("deleted_at DESC, trash_id DESC").First(&entry).Error; err != nil {
+			return lookupError(err)
+		}
+
+		t, err := decodeTrashContent(entry.Content)
+		if err != nil {
+			return err
+		}
+
+		var doc models.UserDocument
+		err = lockingQuery(tx, ScopeUser).
+			Where("user_id = ? AND document_name = ?", userID, documentName).
+			Where(notExpired, now).
+			First(&doc).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if entry.CollectionName == "" {
+				return fmt.Errorf("document %w", ErrExists)
+			}
+			if tx.Model(&doc).Where("collection_name = ?", entry.CollectionName).Where(notExpired, now).Association("Collections").Count() > 0 {
+				return fmt.Errorf("collection %w", ErrExists)
+			}
+		}
+
+		// Restore past the deleted version, as for application documents
+		version := doc.DocumentVersion
+		if version < entry.DocumentVersion {
+			if doc.DocumentID != 0 {
+				err = tx.Model(&doc).Update("document_version", entry.DocumentVersion).Error
+			} else {
+				err = restoreUserDocument(tx, userID, documentName, entry.DocumentVersion, now)
+			}
+			if err != nil {
+				return err
+			}
+			version = entry.DocumentVersion
+		}
+
+		newVersion, affectedRows, err = setUserProperties(tx, userID, documentName, version, t.collectionInputs(), opts)
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&entry).Error
+	})
+
+	if err == nil && affectedRows > 0 {
+		publishMutation(MutationEvent{Scope: ScopeUser, UserID: userID, Document: documentName, Version: newVersion})
+	}
+
+	return newVersion, affectedRows, err
+}
+
+// PurgeTrash permanently removes application and user trash deleted before cutoff, returning the entries removed
+func PurgeTrash(ctx context.Context, db *gorm.DB, cutoff time.Time) (int64, error) {
+	db = db.WithContext(ctx)
+	app := db.Where("deleted_at < ?", cutoff).Delete(&models.ApplicationTrash{})
+	if app.Error != nil {
+		return 0, app.Error
+	}
+
+	user := db.Where("deleted_at < ?", cutoff).Delete(&models.UserTrash{})
+	if user.Error != nil {
+		return app.RowsAffected, user.Error
+	}
+
+	return app.RowsAffected + user.RowsAffected, nil
+}
+
+// TrashPurger periodically removes trash older than Retention from Store
+type TrashPurger struct {
+	Store     Store
+	Retention time.Duration
+	periodicTask
+}
+
+// Purge removes trash older than the retention once
+func (p *TrashPurger) Purge(ctx context.Context) {
+	purged, err := p.Store.PurgeTrash(ctx, time.Now().Add(-p.Retention))
+	if err != nil {
+		slog.Error("Failed to purge trash", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.Info("Purged trash", "entries", purged, "retention", p.Retention.String())
+	}
+}
+
+// Start purges every interval until Stop. A zero retention keeps trash until it is restored
+func (p *TrashPurger) Start(interval time.Duration) {
+	if p.Retention <= 0 {
+		return
+	}
+	p.start(interval, p.Purge)
+}
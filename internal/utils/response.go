@@ -21,11 +21,44 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
+// MIMEApplicationProblemJSON is the RFC 7807 problem details media type
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// WantsProblem reports whether the client opted into problem details with its Accept header
+func WantsProblem(c *fiber.Ctx) bool {
+	return strings.Contains(c.Get(fiber.HeaderAccept), MIMEApplicationProblemJSON)
+}
+
+// ProblemResponse sends an RFC 7807 problem details response.
+// errorType becomes a "urn:propsdb:problem:<type>" type URI, or "about:blank" if empty.
+func ProblemResponse(c *fiber.Ctx, status int, detail, errorType string, extensions fiber.Map) error {
+	problemType := "about:blank"
+	if errorType != "" {
+		problemType = "urn:propsdb:problem:" + errorType
+	}
+
+	problem := fiber.Map{
+		"type":      problemType,
+		"title":     utils.StatusMessage(status),
+		"status":    status,
+		"detail":    detail,
+		"instance":  c.OriginalURL(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	for key, value := range extensions {
+		problem[key] = value
+	}
+
+	return c.Status(status).JSON(problem, MIMEApplicationProblemJSON)
+}
+
 // SuccessResponse sends a standard success response
 func SuccessResponse(c *fiber.Ctx, data interface{}, status int) error {
 	return c.Status(status).JSON(data)
@@ -33,6 +66,9 @@ func SuccessResponse(c *fiber.Ctx, data interface{}, status int) error {
 
 // ErrorResponse sends a standard error response matching Node.js format
 func ErrorResponse(c *fiber.Ctx, message string, status int, errorType string) error {
+	if WantsProblem(c) {
+		return ProblemResponse(c, status, message, errorType, nil)
+	}
 	return c.Status(status).JSON(fiber.Map{
 		"status":    status,
 		"message":   message,
@@ -45,19 +81,46 @@ func ErrorResponse(c *fiber.Ctx, message string, status int, errorType string) e
 
 // VersionErrorResponse sends a version conflict error (409)
 func VersionErrorResponse(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-		"status":       fiber.StatusConflict,
-		"message":      "E_VERSION - Refresh and reconcile with current version and retry.",
-		"ok":           false,
-		"versionError": true,
-		"timestamp":    time.Now().UTC().Format(time.RFC3339),
-		"url":          c.OriginalURL(),
-		"type":         "version",
-	})
+	return VersionConflictResponse(c, fiber.StatusConflict, nil)
+}
+
+// PreconditionFailedResponse sends a version conflict error (412) for an If-Match mismatch
+func PreconditionFailedResponse(c *fiber.Ctx) error {
+	return VersionConflictResponse(c, fiber.StatusPreconditionFailed, nil)
+}
+
+// VersionConflictResponse sends a version conflict error (409 or 412) with optional details,
+// such as the current server state, merged into the body
+func VersionConflictResponse(c *fiber.Ctx, status int, details fiber.Map) error {
+	message := "E_VERSION - Refresh and reconcile with current version and retry."
+	if status == fiber.StatusPreconditionFailed {
+		message = "E_VERSION - If-Match does not match the current version. Refresh and retry."
+	}
+
+	body := fiber.Map{"versionError": true}
+	for key, value := range details {
+		body[key] = value
+	}
+
+	if WantsProblem(c) {
+		return ProblemResponse(c, status, message, "version", body)
+	}
+
+	body["status"] = status
+	body["message"] = message
+	body["ok"] = false
+	body["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	body["url"] = c.OriginalURL()
+	body["type"] = "version"
+
+	return c.Status(status).JSON(body)
 }
 
 // NotFoundResponse sends a 404 not found response
 func NotFoundResponse(c *fiber.Ctx, message string) error {
+	if WantsProblem(c) {
+		return ProblemResponse(c, fiber.StatusNotFound, message, "", nil)
+	}
 	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 		"status":    fiber.StatusNotFound,
 		"message":   message,
@@ -78,6 +141,32 @@ func MutationSuccessResponse(c *fiber.Ctx, newVersion uint64, affectedRows int64
 	})
 }
 
+// ETagMatches reports whether an If-None-Match header value matches etag, using weak comparison
+func ETagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ProblemResponseStruct defines the schema for RFC 7807 problem details responses
+type ProblemResponseStruct struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	Status       int    `json:"status"`
+	Detail       string `json:"detail"`
+	Instance     string `json:"instance"`
+	Timestamp    string `json:"timestamp"`
+	VersionError bool   `json:"versionError,omitempty"`
+}
+
 // ErrorResponseStruct defines the schema for error responses
 type ErrorResponseStruct struct {
 	Status       int    `json:"status"`
@@ -89,6 +178,22 @@ type ErrorResponseStruct struct {
 	VersionError bool   `json:"versionError,omitempty"`
 }
 
+// VersionConflictResponseStruct defines the schema for version conflicts that requested conflict details
+type VersionConflictResponseStruct struct {
+	ErrorResponseStruct
+	CurrentVersion string                            `json:"currentVersion,omitempty"`
+	Collections    map[string]map[string]interface{} `json:"collections,omitempty"`
+	Changes        []ConflictChangeStruct            `json:"changes,omitempty"`
+}
+
+// ConflictChangeStruct defines the schema for a property changed after the client's base version
+type ConflictChangeStruct struct {
+	Collection string `json:"collection"`
+	Property   string `json:"property"`
+	Version    string `json:"version"`
+	ModifiedBy string `json:"modifiedBy"`
+}
+
 // SuccessResponseStruct defines the schema for mutation success responses
 type SuccessResponseStruct struct {
 	Message      string `json:"message"`
@@ -104,7 +104,7 @@ func testHealthCheck(t *testing.T, tc *helpers.TestContainers) {
 	defer database.Close(gormDB)
 
 	// 3. Perform the health check
-	result := services.HealthCheck(cfg, gormDB)
+	result := services.HealthCheck(t.Context(), cfg, gormDB)
 
 	// 4. Verify the result
 	if result.Status != "healthy" {
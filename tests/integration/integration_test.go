@@ -228,7 +228,7 @@ func testCreateAndRetrieveDocument(t *testing.T, db *gorm.DB) {
 		},
 	}
 
-	newVersion, _, err := services.SetApplicationProperties(db, "app1", 0, collections)
+	newVersion, _, err := services.SetApplicationProperties(t.Context(), db, "app1", 0, collections, services.WriteOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create document: %v", err)
 	}
@@ -238,7 +238,7 @@ func testCreateAndRetrieveDocument(t *testing.T, db *gorm.DB) {
 	}
 
 	// Retrieve document
-	result, err := services.GetApplicationCollectionsAndProperties(db, "app1", []string{})
+	result, err := services.GetApplicationCollectionsAndProperties(t.Context(), db, "app1", []string{}, services.ReadOptions{})
 	if err != nil {
 		t.Fatalf("Failed to retrieve document: %v", err)
 	}
@@ -270,14 +270,14 @@ func testVersionControl(t *testing.T, db *gorm.DB) {
 		},
 	}
 
-	_, _, err := services.SetApplicationProperties(db, "versiontest", 0, collections)
+	_, _, err := services.SetApplicationProperties(t.Context(), db, "versiontest", 0, collections, services.WriteOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create document: %v", err)
 	}
 
 	// Try to update with wrong version
 	collections[0].Properties["value"] = "updated"
-	_, _, err = services.SetApplicationProperties(db, "versiontest", 0, collections)
+	_, _, err = services.SetApplicationProperties(t.Context(), db, "versiontest", 0, collections, services.WriteOptions{})
 	if err == nil {
 		t.Error("Expected version conflict error")
 	}
@@ -287,7 +287,7 @@ func testVersionControl(t *testing.T, db *gorm.DB) {
 	}
 
 	// Update with correct version
-	_, _, err = services.SetApplicationProperties(db, "versiontest", 1, collections)
+	_, _, err = services.SetApplicationProperties(t.Context(), db, "versiontest", 1, collections, services.WriteOptions{})
 	if err != nil {
 		t.Errorf("Failed to update with correct version: %v", err)
 	}
@@ -312,19 +312,19 @@ func testDeleteOperations(t *testing.T, db *gorm.DB) {
 		},
 	}
 
-	_, _, err := services.SetApplicationProperties(db, "deletetest", 0, collections)
+	_, _, err := services.SetApplicationProperties(t.Context(), db, "deletetest", 0, collections, services.WriteOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create document: %v", err)
 	}
 
 	// Delete a collection
-	_, _, err = services.DeleteApplicationCollection(db, "deletetest", 1, "coll1")
+	_, _, err = services.DeleteApplicationCollection(t.Context(), db, "deletetest", 1, "coll1", services.WriteOptions{})
 	if err != nil {
 		t.Fatalf("Failed to delete collection: %v", err)
 	}
 
 	// Verify collection is deleted
-	result, err := services.GetApplicationCollectionsAndProperties(db, "deletetest", []string{})
+	result, err := services.GetApplicationCollectionsAndProperties(t.Context(), db, "deletetest", []string{}, services.ReadOptions{})
 	if err != nil {
 		t.Fatalf("Failed to retrieve document: %v", err)
 	}
@@ -400,7 +400,7 @@ func TestHealthCheck(t *testing.T) {
 	defer database.Close(db)
 
 	// Run health check
-	result := services.HealthCheck(cfg, db)
+	result := services.HealthCheck(t.Context(), cfg, db)
 
 	// Database should be healthy
 	if result.Database != "ok" {
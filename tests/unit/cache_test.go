@@ -0,0 +1,261 @@
+// cache_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/cache"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/middleware"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestLRU tests eviction order and expiry of the in-memory cache
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(2)
+
+	_ = lru.Set(ctx, "a", []byte("1"), 0)
+	_ = lru.Set(ctx, "b", []byte("2"), 0)
+	if _, ok, _ := lru.Get(ctx, "a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+
+	// b is now least recently used
+	_ = lru.Set(ctx, "c", []byte("3"), 0)
+	if _, ok, _ := lru.Get(ctx, "b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if value, ok, _ := lru.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Errorf("Expected a=1, got %q %v", value, ok)
+	}
+
+	_ = lru.Set(ctx, "d", []byte("4"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := lru.Get(ctx, "d"); ok {
+		t.Error("Expected d to be expired")
+	}
+
+	// Add only stores missing or expired keys
+	if added, _ := lru.Add(ctx, "a", []byte("x"), 0); added {
+		t.Error("Expected Add to keep the existing a")
+	}
+	if added, _ := lru.Add(ctx, "d", []byte("5"), 0); !added {
+		t.Error("Expected Add to replace the expired d")
+	}
+	_ = lru.Delete(ctx, "d")
+	if _, ok, _ := lru.Get(ctx, "d"); ok {
+		t.Error("Expected d to be deleted")
+	}
+
+	_ = lru.Purge(ctx)
+	if lru.Len() != 0 {
+		t.Errorf("Expected empty cache after purge, got %d entries", lru.Len())
+	}
+}
+
+// TestAppCache tests cache hits, key separation and invalidation on app mutations
+func TestAppCache(t *testing.T) {
+	db := setupTestDB(t)
+
+	helpers.CreateTestDocument(t, db, "cachedoc", 1)
+	helpers.CreateTestCollection(t, db, "cachedoc", "settings", map[string]interface{}{
+		"theme": "dark",
+		"color": "blue",
+	})
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db}
+	appRoutes := app.Group("/api/data/app")
+	appRoutes.Use(middleware.AppCache(middleware.AppCacheConfig{
+		Cache:  cache.NewLRU(100),
+		TTL:    time.Minute,
+		MaxAge: 30,
+	}))
+	appRoutes.Get("/:document/:collection", handler.GetAppProperties)
+	appRoutes.Post("/:document", handler.SetAppProperties)
+
+	get := func(url, ifNoneMatch string) (int, string, string) {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		if resp.StatusCode == 200 && resp.Header.Get("Cache-Control") != "public, max-age=30, must-revalidate" {
+			t.Errorf("Unexpected Cache-Control %q", resp.Header.Get("Cache-Control"))
+		}
+		return resp.StatusCode, resp.Header.Get("X-Cache"), resp.Header.Get("ETag")
+	}
+
+	url := "/api/data/app/cachedoc/settings"
+	if status, state, _ := get(url, ""); status != 200 || state != "MISS" {
+		t.Fatalf("Expected 200 MISS, got %d %s", status, state)
+	}
+	status, state, etag := get(url, "")
+	if status != 200 || state != "HIT" {
+		t.Fatalf("Expected 200 HIT, got %d %s", status, state)
+	}
+	if status, state, _ := get(url, etag); status != 304 || state != "HIT" {
+		t.Errorf("Expected 304 HIT for matching If-None-Match, got %d %s", status, state)
+	}
+	if _, state, _ := get(url+"?fields=theme", ""); state != "MISS" {
+		t.Errorf("Expected a separate entry for fields, got %s", state)
+	}
+
+	// A committed app mutation invalidates the cache
+	req := httptest.NewRequest("POST", "/api/data/app/cachedoc", strings.NewReader(
+		`{"version":"1","collections":[{"collection":"settings","properties":{"theme":"light"}}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 200)
+
+	status, state, newTag := get(url, "")
+	if status != 200 || state != "MISS" {
+		t.Errorf("Expected 200 MISS after mutation, got %d %s", status, state)
+	}
+	if newTag == etag || !strings.HasPrefix(newTag, `"2-`) {
+		t.Errorf("Expected a new version 2 ETag after mutation, got %s", newTag)
+	}
+}
+
+// sharedCache is a Generational cache standing in for Redis, purged by other instances
+type sharedCache struct {
+	*cache.LRU
+	generation atomic.Int64
+}
+
+func (s *sharedCache) Generation(context.Context) (string, error) {
+	return strconv.FormatInt(s.generation.Load(), 10), nil
+}
+
+func (s *sharedCache) GetIn(ctx context.Context, generation, key string) ([]byte, bool, error) {
+	return s.LRU.Get(ctx, generation+":"+key)
+}
+
+func (s *sharedCache) SetIn(ctx context.Context, generation, key string, value []byte, ttl time.Duration) error {
+	return s.LRU.Set(ctx, generation+":"+key, value, ttl)
+}
+
+func (s *sharedCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	generation, _ := s.Generation(ctx)
+	return s.GetIn(ctx, generation, key)
+}
+
+func (s *sharedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	generation, _ := s.Generation(ctx)
+	return s.SetIn(ctx, generation, key, value, ttl)
+}
+
+func (s *sharedCache) Purge(context.Context) error {
+	s.generation.Add(1)
+	return nil
+}
+
+// TestAppCacheSharedPurge tests a response read before another instance's purge is not stored after it
+func TestAppCacheSharedPurge(t *testing.T) {
+	shared := &sharedCache{LRU: cache.NewLRU(100)}
+	app := fiber.New()
+	app.Use(middleware.AppCache(middleware.AppCacheConfig{Cache: shared, TTL: time.Minute}))
+
+	reads := 0
+	app.Get("/doc", func(c *fiber.Ctx) error {
+		reads++
+		if reads == 1 {
+			// Another instance commits while this one reads
+			shared.Purge(c.UserContext())
+		}
+		return c.SendString("read " + strconv.Itoa(reads))
+	})
+
+	for i, want := range []string{"MISS", "MISS", "HIT"} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/doc", nil))
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		if state := resp.Header.Get("X-Cache"); state != want {
+			t.Errorf("Request %d: expected %s, got %s", i+1, want, state)
+		}
+	}
+	if reads != 2 {
+		t.Errorf("Expected the stale read not to be cached, got %d reads", reads)
+	}
+}
+
+// TestAppCacheExpiry tests a response holding expiring data is cached no longer than its earliest expiry
+func TestAppCacheExpiry(t *testing.T) {
+	store := services.NewMemoryStore()
+	_, _, err := store.SetApplicationProperties(t.Context(), "home", 0, []services.CollectionInput{
+		{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}},
+		{Collection: "banner", Properties: map[string]interface{}{"text": "sale"}, Expiry: services.Expiry{TTL: 1}},
+	}, services.WriteOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(middleware.AppCache(middleware.AppCacheConfig{Cache: cache.NewLRU(100), TTL: time.Minute, MaxAge: 30}))
+	app.Get("/api/data/app/:document", (&handlers.AppDataHandler{Store: store}).GetAppCollectionsAndProperties)
+
+	get := func() (string, string, string) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/home", nil))
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		helpers.AssertStatus(t, resp, 200)
+		var body strings.Builder
+		_, _ = io.Copy(&body, resp.Body)
+		return resp.Header.Get("X-Cache"), resp.Header.Get("Cache-Control"), body.String()
+	}
+
+	for _, want := range []string{"MISS", "HIT"} {
+		state, cacheControl, body := get()
+		if state != want || !strings.Contains(body, "sale") {
+			t.Fatalf("Expected %s with the banner, got %s %s", want, state, body)
+		}
+		if cacheControl != "public, max-age=0, must-revalidate" && cacheControl != "public, max-age=1, must-revalidate" {
+			t.Errorf("Expected max-age capped at the banner expiry, got %q", cacheControl)
+		}
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	state, cacheControl, body := get()
+	if state != "MISS" || strings.Contains(body, "sale") {
+		t.Errorf("Expected a MISS without the expired banner, got %s %s", state, body)
+	}
+	if cacheControl != "public, max-age=30, must-revalidate" {
+		t.Errorf("Unexpected Cache-Control %q", cacheControl)
+	}
+}
@@ -0,0 +1,163 @@
+// conditional_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestGetAppProperties_ETag tests ETag emission and If-None-Match handling
+func TestGetAppProperties_ETag(t *testing.T) {
+	db := setupTestDB(t)
+
+	helpers.CreateTestDocument(t, db, "etagdoc", 3)
+	helpers.CreateTestCollection(t, db, "etagdoc", "settings", map[string]interface{}{
+		"theme": "dark",
+		"size":  "large",
+	})
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db}
+	app.Get("/api/data/app/:document/:collection", handler.GetAppProperties)
+	app.Get("/api/data/app", handler.GetAppDocumentsCollectionsAndProperties)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/etagdoc/settings", nil))
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 200)
+
+	etag := resp.Header.Get("ETag")
+	if !strings.HasPrefix(etag, `"3-`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("Expected strong ETag with version prefix, got %q", etag)
+	}
+
+	// A different projection is a different representation
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/data/app/etagdoc/settings?fields=theme", nil))
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	if other := resp.Header.Get("ETag"); other == etag || !strings.HasPrefix(other, `"3-`) {
+		t.Errorf("Expected a distinct versioned ETag for the projection, got %q", other)
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		ifNoneMatch string
+		status      int
+	}{
+		{name: "matching tag", url: "/api/data/app/etagdoc/settings", ifNoneMatch: etag, status: 304},
+		{name: "weak match", url: "/api/data/app/etagdoc/settings", ifNoneMatch: "W/" + etag, status: 304},
+		{name: "tag in list", url: "/api/data/app/etagdoc/settings", ifNoneMatch: `"2-abc", ` + etag, status: 304},
+		{name: "wildcard", url: "/api/data/app/etagdoc/settings", ifNoneMatch: "*", status: 304},
+		{name: "stale tag", url: "/api/data/app/etagdoc/settings", ifNoneMatch: `"2-abc"`, status: 200},
+		{name: "all documents stale tag", url: "/api/data/app", ifNoneMatch: etag, status: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to execute request: %v", err)
+			}
+
+			helpers.AssertStatus(t, resp, tt.status)
+			if resp.Header.Get("ETag") == "" {
+				t.Error("Expected ETag header")
+			}
+			if tt.status == 304 {
+				helpers.AssertNoContent(t, resp)
+			}
+		})
+	}
+}
+
+// TestMutations_IfMatch tests If-Match as the expected version for mutations
+func TestMutations_IfMatch(t *testing.T) {
+	db := setupTestDB(t)
+
+	helpers.CreateTestDocument(t, db, "matchdoc", 2)
+	helpers.CreateTestCollection(t, db, "matchdoc", "settings", map[string]interface{}{
+		"theme": "dark",
+	})
+	helpers.CreateTestCollection(t, db, "matchdoc", "extra", map[string]interface{}{
+		"flag": true,
+	})
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db}
+	app.Post("/api/data/app/:document", handler.SetAppProperties)
+	app.Delete("/api/data/app/:document/:collection", handler.DeleteAppCollection)
+
+	setBody := `{"collections":[{"collection":"settings","properties":{"theme":"light"}}]}`
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		ifMatch string
+		body    string
+		status  int
+	}{
+		{name: "stale tag", method: "POST", url: "/api/data/app/matchdoc", ifMatch: `"1-0123456789abcdef"`, body: setBody, status: 412},
+		{name: "stale body version", method: "POST", url: "/api/data/app/matchdoc", body: `{"version":"1","collections":[{"collection":"settings","properties":{"theme":"light"}}]}`, status: 409},
+		{name: "malformed header", method: "POST", url: "/api/data/app/matchdoc", ifMatch: "2", body: setBody, status: 400},
+		{name: "header wins over body", method: "POST", url: "/api/data/app/matchdoc", ifMatch: `"2-0123456789abcdef"`, body: `{"version":"1","collections":[{"collection":"settings","properties":{"theme":"light"}}]}`, status: 200},
+		{name: "delete without body", method: "DELETE", url: "/api/data/app/matchdoc/extra", ifMatch: `"3"`, status: 200},
+		{name: "any version", method: "POST", url: "/api/data/app/matchdoc", ifMatch: "*", body: setBody, status: 200},
+		{name: "any version of a missing document", method: "POST", url: "/api/data/app/nomatchdoc", ifMatch: "*", body: setBody, status: 412},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to execute request: %v", err)
+			}
+			helpers.AssertStatus(t, resp, tt.status)
+
+			if tt.status == 412 {
+				var result map[string]interface{}
+				helpers.ParseJSON(t, resp, &result)
+				if result["versionError"] != true {
+					t.Errorf("Expected versionError in 412 response, got %v", result)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// config_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+)
+
+// setRequiredEnv sets the required settings, clearing those the tests layer
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("DB_APP_DATABASE", "jam_build")
+	t.Setenv("DB_APP_USER", "app")
+	t.Setenv("DB_USER", "user")
+	t.Setenv("AUTHZ_URL", "http://authorizer:8080")
+	t.Setenv("AUTHZ_CLIENT_ID", "client")
+	for _, env := range []string{"CONFIG_FILE", "PORT", "DB_PORT", "DB_HOST", "DB_PASSWORD", "DB_PASSWORD_FILE",
+		"DB_APP_PASSWORD", "DB_APP_PASSWORD_FILE", "DB_APP_REPLICAS", "APP_CACHE_SIZE", "LOG_LEVEL", "DB_DSN", "DB_USER_DSN", "DB_TLS_MODE", "DB_TLS_CERT",
+		"LISTEN", "TLS_CERT", "TLS_KEY", "SECURITY_HEADERS", "HSTS_MAX_AGE", "HSTS_PRELOAD"} {
+		t.Setenv(env, "")
+	}
+}
+
+// writeFile writes a file in the test's temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// TestConfigLoad tests layering, secrets from files, strict validation and printing
+func TestConfigLoad(t *testing.T) {
+	t.Run("layers", func(t *testing.T) {
+		setRequiredEnv(t)
+		file := writeFile(t, "propsdb.yaml", "port: \"4000\"\ndb_port: 3307\ndb_host: file-host\napp_cache_size: 50\ndb_app_replicas:\n  - replica-a\n  - replica-b\n")
+		t.Setenv("CONFIG_FILE", file)
+		t.Setenv("DB_HOST", "env-host")
+		t.Setenv("APP_CACHE_SIZE", "60")
+
+		cfg, err := config.Load("--app-cache-size", "70")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.IdempotencySize != 10000 || cfg.Port != "4000" || cfg.DBPort != "3307" {
+			t.Errorf("Expected defaults and file values, got %+v", cfg)
+		}
+		if cfg.DBHost != "env-host" || cfg.AppCacheSize != 70 {
+			t.Errorf("Expected environment over file and flag over environment, got host %s size %d", cfg.DBHost, cfg.AppCacheSize)
+		}
+		if len(cfg.DBAppReplicas) != 2 || cfg.DBAppReplicas[1] != "replica-b" {
+			t.Errorf("Expected file list, got %v", cfg.DBAppReplicas)
+		}
+	})
+
+	t.Run("toml file", func(t *testing.T) {
+		setRequiredEnv(t)
+		file := writeFile(t, "propsdb.toml", "log_level = \"debug\"\ntracing_sample_ratio = 0.25\n")
+
+		cfg, err := config.Load("--config", file)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.LogLevel != "debug" || cfg.TracingSampleRatio != 0.25 {
+			t.Errorf("Expected toml values, got %s %v", cfg.LogLevel, cfg.TracingSampleRatio)
+		}
+	})
+
+	t.Run("secret files", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_APP_PASSWORD_FILE", writeFile(t, "app_password", "s3cret\n"))
+		file := writeFile(t, "propsdb.yaml", "db_password_file: "+writeFile(t, "password", "other")+"\n")
+
+		cfg, err := config.Load("--config", file)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.DBAppPassword != "s3cret" || cfg.DBPassword != "other" {
+			t.Errorf("Expected secrets from files, got %q %q", cfg.DBAppPassword, cfg.DBPassword)
+		}
+
+		t.Setenv("DB_APP_PASSWORD", "inline")
+		if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "DB_APP_PASSWORD_FILE are both set") {
+			t.Errorf("Expected both set error, got %v", err)
+		}
+	})
+
+	t.Run("aggregated errors", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_USER", "")
+		t.Setenv("APP_CACHE_SIZE", "lots")
+		t.Setenv("LOG_LEVEL", "loud")
+		t.Setenv("DB_TLS_CERT", "/certs/client.pem")
+		file := writeFile(t, "propsdb.yaml", "prot: 3000\n")
+
+		_, err := config.Load("--config", file)
+		if err == nil {
+			t.Fatal("Expected configuration errors")
+		}
+		for _, want := range []string{"DB_USER is required", `APP_CACHE_SIZE: invalid integer "lots"`, "LOG_LEVEL must be", "DB_TLS_CERT and DB_TLS_KEY must be set together", "DB_TLS_CA and DB_TLS_CERT require", "prot in " + file + ": unknown setting"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected %q in %v", want, err)
+			}
+		}
+	})
+
+	t.Run("server settings", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("LISTEN", ":8443,unix:/run/propsdb.sock")
+		t.Setenv("SECURITY_HEADERS", "true")
+		t.Setenv("HSTS_MAX_AGE", "31536000")
+
+		cfg, err := config.Load("--hsts-preload", "1")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if len(cfg.Listen) != 2 || cfg.Listen[1] != "unix:/run/propsdb.sock" {
+			t.Errorf("Expected listen addresses, got %v", cfg.Listen)
+		}
+		if !cfg.SecurityHeaders || cfg.HSTSMaxAge != 31536000 || !cfg.HSTSPreload {
+			t.Errorf("Expected security headers with preloaded HSTS, got %+v", cfg)
+		}
+
+		setRequiredEnv(t)
+		t.Setenv("HSTS_MAX_AGE", "600")
+		t.Setenv("TLS_KEY", "/certs/tls.key")
+		_, err = config.Load("--hsts-preload", "maybe")
+		for _, want := range []string{`--hsts-preload: invalid boolean "maybe"`, "HSTS_MAX_AGE requires SECURITY_HEADERS", "TLS_CERT and TLS_KEY must be set together"} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected %q in %v", want, err)
+			}
+		}
+	})
+
+	t.Run("print redacted", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_APP_PASSWORD", "s3cret")
+		t.Setenv("DB_APP_REPLICAS", "app:pw@tcp(replica:3306)/jam")
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := cfg.Print(&buf, true); err != nil {
+			t.Fatalf("Failed to print config: %v", err)
+		}
+		output := buf.String()
+		if strings.Contains(output, "s3cret") || strings.Contains(output, "pw@") {
+			t.Errorf("Expected secrets redacted:\n%s", output)
+		}
+		if !strings.Contains(output, "db_app_password: '[REDACTED]'") || !strings.Contains(output, "db_user: user") {
+			t.Errorf("Unexpected output:\n%s", output)
+		}
+
+		// The printed configuration loads as a config file
+		setRequiredEnv(t)
+		buf.Reset()
+		if err := cfg.Print(&buf, false); err != nil {
+			t.Fatalf("Failed to print config: %v", err)
+		}
+		reloaded, err := config.Load("--config", writeFile(t, "printed.yaml", buf.String()))
+		if err != nil {
+			t.Fatalf("Failed to load printed config: %v", err)
+		}
+		if reloaded.DBAppPassword != "s3cret" || len(reloaded.DBAppReplicas) != 1 {
+			t.Errorf("Expected printed values, got %+v", reloaded)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+// copy_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestCopyRenameAndTemplate tests the document copy, rename and template routes
+func TestCopyRenameAndTemplate(t *testing.T) {
+	store := services.NewMemoryStore()
+	_, _, _ = store.SetApplicationProperties(t.Context(), "defaults", 0, []services.CollectionInput{
+		{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}},
+	}, services.WriteOptions{})
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"id": "user-789"})
+		return c.Next()
+	})
+	appHandler := &handlers.AppDataHandler{Store: store}
+	userHandler := &handlers.UserDataHandler{Store: store}
+	app.Post("/api/data/app/:document/copy", appHandler.CopyAppDocument)
+	app.Post("/api/data/app/:document/rename", appHandler.RenameAppDocument)
+	app.Post("/api/data/user/:document/from-template/:appDocument", userHandler.CreateUserDocumentFromTemplate)
+	app.Get("/api/data/user/:document", userHandler.GetUserCollectionsAndProperties)
+
+	send := func(method, url, body, ifMatch string, status int) fiber.Map {
+		t.Helper()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		helpers.AssertStatus(t, resp, status)
+		var result fiber.Map
+		helpers.ParseJSON(t, resp, &result)
+		return result
+	}
+
+	result := send("POST", "/api/data/app/defaults/copy", `{"version":"1","target":"page"}`, "", 200)
+	if result["newVersion"] != "1" {
+		t.Errorf("Expected the copy at version 1, got %v", result)
+	}
+	send("POST", "/api/data/app/defaults/copy", `{"version":"1","target":"page"}`, "", 409)
+	send("POST", "/api/data/app/defaults/copy", `{"version":"1"}`, "", 400)
+	send("POST", "/api/data/app/page/rename?target=landing", "", `"2"`, 412)
+	result = send("POST", "/api/data/app/page/rename?target=landing", "", `"1"`, 200)
+	if result["newVersion"] != "2" {
+		t.Errorf("Expected the renamed document at version 2, got %v", result)
+	}
+
+	send("POST", "/api/data/user/prefs/from-template/missing", "", "", 404)
+	result = send("POST", "/api/data/user/prefs/from-template/landing", "", "", 200)
+	if result["newVersion"] != "1" {
+		t.Errorf("Expected the user document at version 1, got %v", result)
+	}
+	send("POST", "/api/data/user/prefs/from-template/landing", "", "", 409)
+
+	result = send("GET", "/api/data/user/prefs", "", "", 200)
+	prefs, _ := result["prefs"].(map[string]interface{})
+	if settings, _ := prefs["settings"].(map[string]interface{}); settings["theme"] != "dark" {
+		t.Errorf("Expected the template settings, got %v", result)
+	}
+}
@@ -0,0 +1,219 @@
+// errors_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestServiceErrors tests that service errors classify with errors.Is
+func TestServiceErrors(t *testing.T) {
+	db := setupTestDB(t)
+
+	stores := map[string]services.Store{
+		"gorm":   services.GormStore{DB: db},
+		"memory": services.NewMemoryStore(),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			collections := []services.CollectionInput{{Collection: "main", Properties: map[string]interface{}{"a": "1"}}}
+
+			_, err := store.GetApplicationProperties(t.Context(), "missing", "main", services.ReadOptions{})
+			if !errors.Is(err, services.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound on get, got %v", err)
+			}
+
+			_, _, err = store.DeleteApplicationProperties(t.Context(), "missing", 0, nil, true, services.WriteOptions{})
+			if !errors.Is(err, services.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound on delete, got %v", err)
+			}
+
+			_, _, err = store.SetApplicationProperties(t.Context(), "errdoc", 0, []services.CollectionInput{{Collection: ""}}, services.WriteOptions{})
+			if !errors.Is(err, services.ErrValidation) {
+				t.Errorf("Expected ErrValidation, got %v", err)
+			}
+
+			if _, _, err = store.SetApplicationProperties(t.Context(), "errdoc", 0, collections, services.WriteOptions{}); err != nil {
+				t.Fatalf("Failed to set properties: %v", err)
+			}
+			_, _, err = store.SetApplicationProperties(t.Context(), "errdoc", 0, collections, services.WriteOptions{})
+			if !errors.Is(err, services.ErrVersionConflict) || !strings.HasPrefix(err.Error(), "E_VERSION") {
+				t.Errorf("Expected ErrVersionConflict with legacy message, got %v", err)
+			}
+		})
+	}
+}
+
+// TestProblemResponses tests the opt-in problem+json shape against the legacy error shape
+func TestProblemResponses(t *testing.T) {
+	db := setupTestDB(t)
+
+	helpers.CreateTestDocument(t, db, "problemdoc", 2)
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db}
+	app.Get("/api/data/app/:document/:collection", handler.GetAppProperties)
+	app.Delete("/api/data/app/:document", handler.DeleteAppProperties)
+	app.Get("/api/data/user/:document", (&handlers.UserDataHandler{DB: db}).GetUserCollectionsAndProperties)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		body         string
+		accept       string
+		status       int
+		problem      bool
+		problemType  string
+		errorType    string
+		versionError bool
+	}{
+		{name: "legacy not found", method: "GET", url: "/api/data/app/missing/main", status: 404},
+		{name: "problem not found", method: "GET", url: "/api/data/app/missing/main", accept: "application/problem+json", status: 404, problem: true, problemType: "about:blank"},
+		{name: "user route without a user", method: "GET", url: "/api/data/user/prefs", status: 403, errorType: "data.authorization"},
+		{name: "delete missing document", method: "DELETE", url: "/api/data/app/missing", body: `{"version":"0","deleteDocument":true}`, status: 404},
+		{name: "legacy version conflict", method: "DELETE", url: "/api/data/app/problemdoc", body: `{"version":"1","deleteDocument":true}`, status: 409, versionError: true},
+		{name: "problem version conflict", method: "DELETE", url: "/api/data/app/problemdoc", body: `{"version":"1","deleteDocument":true}`, accept: "application/problem+json, application/json", status: 409, problem: true, problemType: "urn:propsdb:problem:version", versionError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to execute request: %v", err)
+			}
+			helpers.AssertStatus(t, resp, tt.status)
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			contentType := resp.Header.Get("Content-Type")
+			if tt.problem {
+				if !strings.HasPrefix(contentType, "application/problem+json") {
+					t.Errorf("Expected problem+json content type, got %q", contentType)
+				}
+				if body["type"] != tt.problemType || body["instance"] != tt.url || body["status"] != float64(tt.status) {
+					t.Errorf("Unexpected problem details: %v", body)
+				}
+				if body["title"] == "" || body["detail"] == "" {
+					t.Errorf("Expected title and detail, got %v", body)
+				}
+			} else {
+				if !strings.HasPrefix(contentType, "application/json") {
+					t.Errorf("Expected json content type, got %q", contentType)
+				}
+				if body["ok"] != false || body["message"] == nil {
+					t.Errorf("Expected legacy error shape, got %v", body)
+				}
+				if tt.errorType != "" && body["type"] != tt.errorType {
+					t.Errorf("Expected type %s, got %v", tt.errorType, body["type"])
+				}
+			}
+
+			if tt.versionError && body["versionError"] != true {
+				t.Errorf("Expected versionError, got %v", body)
+			}
+		})
+	}
+}
+
+// TestSetUserProperties_ConflictDetails tests the opt-in server state on version conflicts
+func TestSetUserProperties_ConflictDetails(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"id": "user-409"})
+		return c.Next()
+	})
+
+	handler := &handlers.UserDataHandler{Store: services.NewMemoryStore()}
+	app.Post("/api/data/user/:document", handler.SetUserProperties)
+
+	post := func(body string) map[string]interface{} {
+		req := httptest.NewRequest("POST", "/api/data/user/prefs", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+
+		var result map[string]interface{}
+		helpers.ParseJSON(t, resp, &result)
+		result["httpStatus"] = resp.StatusCode
+		return result
+	}
+
+	post(`{"version":"0","collections":[{"collection":"settings","properties":{"theme":"dark"}},{"collection":"other","properties":{"a":"1"}}]}`)
+	post(`{"version":"1","collections":[{"collection":"settings","properties":{"theme":"light"}}]}`)
+
+	result := post(`{"version":"1","collections":[{"collection":"settings","properties":{"theme":"blue"}}]}`)
+	if result["httpStatus"] != 409 || result["versionError"] != true {
+		t.Fatalf("Expected version conflict, got %v", result)
+	}
+	if _, ok := result["currentVersion"]; ok {
+		t.Errorf("Expected no conflict details without opting in, got %v", result)
+	}
+
+	result = post(`{"version":"1","conflictDetails":true,"collections":[{"collection":"settings","properties":{"theme":"blue"}},{"collection":"missing"}]}`)
+	if result["httpStatus"] != 409 || result["message"] == nil {
+		t.Fatalf("Expected version conflict, got %v", result)
+	}
+	if result["currentVersion"] != "2" {
+		t.Errorf("Expected current version 2, got %v", result["currentVersion"])
+	}
+	expected := map[string]interface{}{"settings": map[string]interface{}{"theme": "light"}}
+	if !reflect.DeepEqual(result["collections"], expected) {
+		t.Errorf("Expected affected collections %v, got %v", expected, result["collections"])
+	}
+	changes := []interface{}{map[string]interface{}{
+		"collection": "settings", "property": "theme", "version": "2", "modifiedBy": "user-409",
+	}}
+	if !reflect.DeepEqual(result["changes"], changes) {
+		t.Errorf("Expected changes %v, got %v", changes, result["changes"])
+	}
+
+	result = post(`{"version":"2","mergeStrategy":"bogus","collections":[{"collection":"settings","properties":{"theme":"blue"}}]}`)
+	if result["httpStatus"] != 400 {
+		t.Errorf("Expected 400 for an unknown merge strategy, got %v", result)
+	}
+
+	result = post(`{"version":"0","conflictDetails":true,"collections":[{"collection":"settings","properties":{"theme":"blue"}}]}`)
+	if result["currentVersion"] != "2" {
+		t.Errorf("Expected current version 2 for a stale create, got %v", result)
+	}
+}
@@ -0,0 +1,114 @@
+// fields_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestGetAppProperties_Fields tests property and JSON path projection with the fields parameter
+func TestGetAppProperties_Fields(t *testing.T) {
+	db := setupTestDB(t)
+
+	helpers.CreateTestDocument(t, db, "fieldsdoc", 1)
+	helpers.CreateTestCollection(t, db, "fieldsdoc", "settings", map[string]interface{}{
+		"theme": "dark",
+		"locale": map[string]interface{}{
+			"lang":   "en",
+			"region": "US",
+		},
+		"layout": []interface{}{"header", "footer"},
+	})
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db}
+	app.Get("/api/data/app/:document/:collection", handler.GetAppProperties)
+	app.Get("/api/data/app/:document", handler.GetAppCollectionsAndProperties)
+
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		expected map[string]interface{}
+	}{
+		{
+			name:   "whole properties",
+			url:    "/api/data/app/fieldsdoc/settings?fields=theme,layout",
+			status: 200,
+			expected: map[string]interface{}{
+				"theme":  "dark",
+				"layout": []interface{}{"header", "footer"},
+			},
+		},
+		{
+			name:   "json paths",
+			url:    "/api/data/app/fieldsdoc?fields=locale.lang&fields=layout.1",
+			status: 200,
+			expected: map[string]interface{}{
+				"locale": map[string]interface{}{"lang": "en"},
+				"layout": map[string]interface{}{"1": "footer"},
+			},
+		},
+		{
+			name:   "whole property wins over path",
+			url:    "/api/data/app/fieldsdoc/settings?fields=locale.lang,locale",
+			status: 200,
+			expected: map[string]interface{}{
+				"locale": map[string]interface{}{"lang": "en", "region": "US"},
+			},
+		},
+		{
+			name:   "nothing selected",
+			url:    "/api/data/app/fieldsdoc/settings?fields=missing,locale.missing",
+			status: 204,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to execute request: %v", err)
+			}
+
+			helpers.AssertStatus(t, resp, tt.status)
+			if tt.expected == nil {
+				helpers.AssertNoContent(t, resp)
+				return
+			}
+
+			var result map[string]map[string]interface{}
+			helpers.ParseJSON(t, resp, &result)
+
+			if got := result["fieldsdoc"]["settings"]; !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+			if result["fieldsdoc"]["__version"] != "1" {
+				t.Errorf("Expected __version 1, got %v", result["fieldsdoc"]["__version"])
+			}
+		})
+	}
+}
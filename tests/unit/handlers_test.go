@@ -47,6 +47,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&models.ApplicationDocument{},
 		&models.ApplicationCollection{},
 		&models.ApplicationProperty{},
+		&models.ApplicationTrash{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
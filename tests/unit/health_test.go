@@ -0,0 +1,175 @@
+// health_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+	"gorm.io/gorm"
+)
+
+// TestHealthEndpoints tests liveness, readiness with pending migrations, result caching and detailed health
+func TestHealthEndpoints(t *testing.T) {
+	authorizer := httptest.NewServer(http.NotFoundHandler())
+	defer authorizer.Close()
+
+	// The in-memory database exists once per connection, keep a single one
+	db := setupTestDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to get underlying SQL DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	checker := &services.HealthChecker{
+		Config:     &config.Config{DBType: "sqlite", AuthzURL: authorizer.URL},
+		AppDB:      db,
+		UserDB:     db,
+		Timeout:    time.Second,
+		Migrations: true,
+	}
+	handler := &handlers.HealthHandler{Checker: checker}
+
+	app := fiber.New()
+	app.Get("/healthz", handler.Liveness)
+	app.Get("/readyz", handler.Readiness)
+	app.Get("/health", handler.Health)
+
+	get := func(path string) (*http.Response, map[string]interface{}) {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode %s response: %v", path, err)
+		}
+		return resp, body
+	}
+
+	resp, _ := get("/healthz")
+	helpers.AssertStatus(t, resp, 200)
+
+	// Only the app tables exist
+	resp, body := get("/readyz")
+	helpers.AssertStatus(t, resp, 503)
+	checks, _ := body["checks"].(map[string]interface{})
+	if checks["app-db"] != "ok" || checks["user-db"] != "ok" || checks["authorizer"] != "ok" {
+		t.Errorf("Expected dependencies ok, got %v", checks)
+	}
+	if migrations, _ := checks["schema"].(string); !strings.Contains(migrations, "user_documents") {
+		t.Errorf("Expected pending user tables, got %q", migrations)
+	}
+
+	resp, body = get("/health")
+	helpers.AssertStatus(t, resp, 503)
+	if body["migrations"] != "pending" || body["database"] != "ok" {
+		t.Errorf("Unexpected health %v", body)
+	}
+
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	resp, body = get("/readyz")
+	helpers.AssertStatus(t, resp, 200)
+	if body["ready"] != true {
+		t.Errorf("Expected ready, got %v", body)
+	}
+
+	// Cached results are served until they expire, though the dependencies changed
+	checker.CacheTTL = time.Hour
+	authorizer.Close()
+	resp, _ = get("/readyz")
+	helpers.AssertStatus(t, resp, 200)
+	resp, body = get("/health")
+	helpers.AssertStatus(t, resp, 503)
+	if body["migrations"] != "pending" || body["authorizer"] != "ok" {
+		t.Errorf("Expected the cached health, got %v", body)
+	}
+
+	checker.CacheTTL = 0
+	resp, body = get("/readyz")
+	helpers.AssertStatus(t, resp, 503)
+	if checks, _ := body["checks"].(map[string]interface{}); checks["authorizer"] == "ok" || checks["schema"] != "ok" {
+		t.Errorf("Expected only the authorizer failing, got %v", checks)
+	}
+}
+
+// TestHealthComponents tests limiting the checks to selected components
+func TestHealthComponents(t *testing.T) {
+	db := setupTestDB(t)
+	checker := &services.HealthChecker{
+		Config:     &config.Config{DBType: "sqlite", AuthzURL: "http://127.0.0.1:1"},
+		AppDB:      db,
+		Timeout:    time.Second,
+		Components: []string{services.ComponentAppDatabase},
+	}
+
+	result := checker.Health(context.Background())
+	if result.Status != "healthy" || result.Database != "ok" || result.Authorizer != "" || result.Migrations != "" {
+		t.Errorf("Expected only the app database checked, got %+v", result)
+	}
+
+	// A selected component without a pool fails
+	checker.Components = []string{services.ComponentAppDatabase, services.ComponentUserDatabase}
+	ready := checker.Ready(context.Background())
+	if ready.Ready || ready.Checks[services.ComponentUserDatabase] != "not configured" || len(ready.Checks) != 2 {
+		t.Errorf("Expected the user database not configured, got %+v", ready)
+	}
+}
+
+// TestReadinessDegraded tests that ejected read replicas keep the service ready but report it degraded
+func TestReadinessDegraded(t *testing.T) {
+	replica := setupTestDB(t)
+	replicas := database.NewReplicaSet("app", setupTestDB(t), []*gorm.DB{replica}, time.Second)
+	checker := &services.HealthChecker{
+		Config:     &config.Config{DBType: "sqlite"},
+		AppDB:      replicas.Primary(),
+		Replicas:   []*database.ReplicaSet{replicas},
+		Timeout:    time.Second,
+		Components: []string{services.ComponentAppDatabase, services.ComponentReplicas},
+	}
+
+	result := checker.Ready(t.Context())
+	if !result.Ready || result.Degraded || result.Checks[services.ComponentReplicas] != "ok" {
+		t.Errorf("Expected ready with healthy replicas, got %+v", result)
+	}
+
+	if err := database.Close(replica); err != nil {
+		t.Fatalf("Failed to close replica: %v", err)
+	}
+	replicas.Check()
+	result = checker.Ready(t.Context())
+	if !result.Ready || !result.Degraded || result.Checks[services.ComponentReplicas] != "ejected: app-replica-0" {
+		t.Errorf("Expected ready and degraded with an ejected replica, got %+v", result)
+	}
+}
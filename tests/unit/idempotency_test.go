@@ -0,0 +1,213 @@
+// idempotency_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/localnerve/jam-build-propsdb/internal/cache"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/middleware"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestIdempotency tests replay, mismatch rejection and per-user scoping of Idempotency-Key
+func TestIdempotency(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		// Copy the header, which the memory store keeps as a key after fasthttp reuses its buffer
+		c.Locals("user", map[string]interface{}{"id": utils.CopyString(c.Get("X-Test-User", "user-1"))})
+		return c.Next()
+	})
+	app.Use(middleware.Idempotency(middleware.IdempotencyConfig{
+		Cache: cache.NewLRU(100),
+		TTL:   time.Minute,
+	}))
+
+	handler := &handlers.UserDataHandler{Store: services.NewMemoryStore()}
+	app.Post("/api/data/user/:document", handler.SetUserProperties)
+
+	post := func(user, key, body string) (*http.Response, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/api/data/user/prefs", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+
+		var result map[string]interface{}
+		helpers.ParseJSON(t, resp, &result)
+		return resp, result
+	}
+
+	body := `{"version":"0","collections":[{"collection":"settings","properties":{"theme":"dark"}}]}`
+
+	resp, result := post("user-1", "key-1", body)
+	helpers.AssertStatus(t, resp, 200)
+	if result["newVersion"] != "1" || resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("Expected a first execution at version 1, got %v", result)
+	}
+
+	// The retry replays instead of failing with E_VERSION
+	resp, result = post("user-1", "key-1", body)
+	helpers.AssertStatus(t, resp, 200)
+	if result["newVersion"] != "1" || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected the stored response to replay, got %v", result)
+	}
+
+	resp, result = post("user-1", "key-1", `{"version":"1","collections":[{"collection":"settings","properties":{"theme":"light"}}]}`)
+	helpers.AssertStatus(t, resp, 422)
+	if result["type"] != "data.idempotency" {
+		t.Errorf("Expected an idempotency error, got %v", result)
+	}
+
+	// The version precondition is part of the request
+	req := httptest.NewRequest("POST", "/api/data/user/prefs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "key-1")
+	req.Header.Set("If-Match", `"1"`)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 422)
+
+	// Keys are per user
+	resp, result = post("user-2", "key-1", body)
+	helpers.AssertStatus(t, resp, 200)
+	if resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("Expected another user's key to execute, got %v", result)
+	}
+
+	// Failures are not stored, so the retry executes again
+	stale := `{"version":"0","collections":[{"collection":"settings","properties":{"theme":"blue"}}]}`
+	for i := 0; i < 2; i++ {
+		resp, result = post("user-1", "key-2", stale)
+		helpers.AssertStatus(t, resp, 409)
+		if result["versionError"] != true {
+			t.Errorf("Expected a version error, got %v", result)
+		}
+	}
+
+	// Without a key every request executes
+	resp, _ = post("user-1", "", body)
+	helpers.AssertStatus(t, resp, 409)
+}
+
+// TestIdempotency_InProgress tests that a retry during the first request is rejected
+func TestIdempotency_InProgress(t *testing.T) {
+	store := cache.NewLRU(100)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	app := fiber.New()
+	app.Use(middleware.Idempotency(middleware.IdempotencyConfig{Cache: store, TTL: time.Minute}))
+	app.Post("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.JSON(fiber.Map{"ok": true})
+	})
+
+	request := func() *http.Request {
+		req := httptest.NewRequest("POST", "/slow", strings.NewReader("{}"))
+		req.Header.Set("Idempotency-Key", "slow-1")
+		return req
+	}
+
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := app.Test(request(), -1)
+		if err != nil {
+			t.Errorf("Failed to execute request: %v", err)
+		}
+		done <- resp
+	}()
+	<-started
+
+	resp, err := app.Test(request())
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 409)
+
+	close(release)
+	helpers.AssertStatus(t, <-done, 200)
+
+	resp, err = app.Test(request())
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 200)
+	if resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected the completed response to replay")
+	}
+}
+
+// cancelAwareCache fails deletes with a done context, as a network cache would
+type cancelAwareCache struct {
+	*cache.LRU
+}
+
+func (c cancelAwareCache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.LRU.Delete(ctx, key)
+}
+
+// TestIdempotency_CanceledRelease tests that a request canceled while running still releases its key
+func TestIdempotency_CanceledRelease(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.Idempotency(middleware.IdempotencyConfig{Cache: cancelAwareCache{cache.NewLRU(100)}, TTL: time.Minute}))
+
+	calls := 0
+	app.Post("/canceled", func(c *fiber.Ctx) error {
+		calls++
+		ctx, cancel := context.WithCancel(c.UserContext())
+		cancel()
+		c.SetUserContext(ctx)
+		return c.Status(fiber.StatusServiceUnavailable).SendString("request canceled")
+	})
+
+	for range 2 {
+		req := httptest.NewRequest("POST", "/canceled", strings.NewReader("{}"))
+		req.Header.Set("Idempotency-Key", "canceled-1")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		helpers.AssertStatus(t, resp, 503)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the retry to execute after the canceled request released its key, got %d calls", calls)
+	}
+}
@@ -0,0 +1,159 @@
+// layer_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestLayerDocument tests deep merging a user document over app defaults
+func TestLayerDocument(t *testing.T) {
+	app := services.DocumentResult{"settings": map[string]interface{}{
+		"__version": "3",
+		"display":   map[string]interface{}{"theme": "light", "font": map[string]interface{}{"size": "12", "family": "sans"}},
+		"locale":    map[string]interface{}{"lang": "en", "region": "us"},
+		"flags":     map[string]interface{}{"beta": "off"},
+	}}
+	user := services.DocumentResult{"settings": map[string]interface{}{
+		"__version": "5",
+		"display":   map[string]interface{}{"font": map[string]interface{}{"size": "14"}},
+		"locale":    map[string]interface{}{"lang": "fr"},
+	}}
+
+	opts, err := services.ParseLayerRules([]string{"locale:replace"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	opts.Provenance = true
+
+	result := services.LayerDocument("settings", app, user, opts)
+	expected := services.DocumentResult{"settings": map[string]interface{}{
+		"__version":            "5",
+		services.AppVersionKey: "3",
+		"display":              map[string]interface{}{"theme": "light", "font": map[string]interface{}{"size": "14", "family": "sans"}},
+		"locale":               map[string]interface{}{"lang": "fr"},
+		"flags":                map[string]interface{}{"beta": "off"},
+		services.ProvenanceKey: map[string]map[string]string{
+			"display": {"theme": "app", "font": "merged"},
+			"locale":  {"lang": "user"},
+			"flags":   {"beta": "app"},
+		},
+	}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	// Without a user document, the app defaults are returned at user version 0
+	result = services.LayerDocument("settings", app, nil, services.LayerOptions{})
+	docMap := result["settings"].(map[string]interface{})
+	if docMap["__version"] != "0" || docMap[services.ProvenanceKey] != nil || !reflect.DeepEqual(docMap["flags"], map[string]interface{}{"beta": "off"}) {
+		t.Errorf("Unexpected app only layer %v", docMap)
+	}
+
+	if _, err := services.ParseLayerRules([]string{"locale:overwrite"}); err == nil {
+		t.Error("Expected an invalid merge rule to fail")
+	}
+}
+
+// TestUserHandlers_Layer tests GET /api/data/user/:document?layer=app
+func TestUserHandlers_Layer(t *testing.T) {
+	store := services.NewMemoryStore()
+	_, _, _ = store.SetApplicationProperties(t.Context(), "settings", 0, []services.CollectionInput{
+		{Collection: "display", Properties: map[string]interface{}{"theme": "light", "density": "normal"}},
+	}, services.WriteOptions{Actor: "admin-1"})
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"id": "user-789"})
+		return c.Next()
+	})
+	handler := &handlers.UserDataHandler{Store: store}
+	app.Get("/api/data/user/:document", handler.GetUserCollectionsAndProperties)
+	app.Get("/api/data/app/:document", (&handlers.AppDataHandler{Store: store}).GetAppCollectionsAndProperties)
+
+	get := func(url string, status int) map[string]interface{} {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		helpers.AssertStatus(t, resp, status)
+		var result map[string]interface{}
+		if status == 200 {
+			helpers.ParseJSON(t, resp, &result)
+		}
+		return result
+	}
+
+	get("/api/data/user/settings", 404)
+	get("/api/data/user/settings?layer=user", 400)
+	get("/api/data/user/settings?layer=app&layerMerge=display:bogus", 400)
+	get("/api/data/user/missing?layer=app", 404)
+
+	result := get("/api/data/user/settings?layer=app", 200)
+	docMap := result["settings"].(map[string]interface{})
+	if docMap["__version"] != "0" || docMap[services.AppVersionKey] != "1" {
+		t.Errorf("Unexpected versions %v", docMap)
+	}
+
+	_, _, _ = store.SetUserProperties(t.Context(), "user-789", "settings", 0, []services.CollectionInput{
+		{Collection: "display", Properties: map[string]interface{}{"theme": "dark"}},
+	}, services.WriteOptions{Actor: "user-789"})
+
+	result = get("/api/data/user/settings?layer=app&include=provenance", 200)
+	docMap = result["settings"].(map[string]interface{})
+	if !reflect.DeepEqual(docMap["display"], map[string]interface{}{"theme": "dark", "density": "normal"}) {
+		t.Errorf("Unexpected merged display %v", docMap["display"])
+	}
+	provenance := docMap[services.ProvenanceKey].(map[string]interface{})
+	if !reflect.DeepEqual(provenance["display"], map[string]interface{}{"theme": "user", "density": "app"}) {
+		t.Errorf("Unexpected provenance %v", provenance)
+	}
+
+	result = get("/api/data/user/settings?layer=app&layerMerge=*:replace", 200)
+	docMap = result["settings"].(map[string]interface{})
+	if !reflect.DeepEqual(docMap["display"], map[string]interface{}{"theme": "dark"}) {
+		t.Errorf("Expected the user collection to replace the default, got %v", docMap["display"])
+	}
+
+	// App metadata is public, so it leaves out the admin ids, while the user's own stay
+	result = get("/api/data/user/settings?layer=app&include=meta", 200)
+	meta := result["settings"].(map[string]interface{})[services.MetaKey].(map[string]interface{})["display"].(map[string]interface{})
+	if theme := meta["theme"].(map[string]interface{}); theme["modifiedBy"] != "user-789" {
+		t.Errorf("Expected the user's id on a user value, got %v", theme)
+	}
+	if density := meta["density"].(map[string]interface{}); density["modifiedBy"] != nil || density["version"] != "1" {
+		t.Errorf("Expected no admin id on an app default, got %v", density)
+	}
+	result = get("/api/data/app/settings?include=meta", 200)
+	meta = result["settings"].(map[string]interface{})[services.MetaKey].(map[string]interface{})["display"].(map[string]interface{})
+	for name, prop := range meta {
+		if prop.(map[string]interface{})["modifiedBy"] != nil {
+			t.Errorf("Expected no admin id on %s, got %v", name, prop)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// logging_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/logging"
+	"github.com/localnerve/jam-build-propsdb/internal/middleware"
+	"github.com/localnerve/jam-build-propsdb/internal/models"
+	"gorm.io/gorm"
+)
+
+// captureLogs installs a debug JSON logger writing to the returned buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelDebug, "json"))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines decodes the JSON log lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %s", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// TestLogging tests request IDs, user IDs and redaction in log lines
+func TestLogging(t *testing.T) {
+	t.Run("request lines", func(t *testing.T) {
+		buf := captureLogs(t)
+
+		app := fiber.New()
+		app.Use(middleware.RequestLogger())
+		app.Get("/fail", func(c *fiber.Ctx) error {
+			logging.SetUser(c.UserContext(), "user-1")
+			slog.InfoContext(c.UserContext(), "Handling", "password", "hunter2")
+			return fmt.Errorf("dial failed for app:s3cret@tcp(db:3306)/jam")
+		})
+
+		req := httptest.NewRequest("GET", "/fail", nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		if got := resp.Header.Get("X-Request-ID"); got != "req-123" {
+			t.Errorf("Expected request ID echoed, got %q", got)
+		}
+
+		lines := logLines(t, buf)
+		if len(lines) != 2 {
+			t.Fatalf("Expected a handler and an access line, got %d: %s", len(lines), buf.String())
+		}
+		for _, line := range lines {
+			if line["request_id"] != "req-123" || line["user_id"] != "user-1" {
+				t.Errorf("Expected request and user IDs on %v", line)
+			}
+		}
+		if lines[0]["password"] != logging.Redacted {
+			t.Errorf("Expected password redacted, got %v", lines[0]["password"])
+		}
+		access := lines[1]
+		if access["msg"] != "Request completed" || access["status"] != float64(500) || access["level"] != "ERROR" {
+			t.Errorf("Unexpected access line %v", access)
+		}
+		if text := fmt.Sprint(access["error"]); strings.Contains(text, "s3cret") || !strings.Contains(text, "app:"+logging.Redacted+"@tcp(") {
+			t.Errorf("Expected DSN password redacted, got %q", text)
+		}
+	})
+
+	t.Run("generated request id", func(t *testing.T) {
+		captureLogs(t)
+
+		app := fiber.New()
+		app.Use(middleware.RequestLogger())
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString(logging.RequestID(c.UserContext()))
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "has spaces")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		id := resp.Header.Get("X-Request-ID")
+		if id == "" || id == "has spaces" {
+			t.Errorf("Expected a generated request ID, got %q", id)
+		}
+	})
+
+	t.Run("session redaction", func(t *testing.T) {
+		session := "abc+def/ghi=="
+		err := logging.RedactError(fmt.Errorf("invalid cookie %s (%s): %w", session, "abc%2Bdef%2Fghi%3D%3D", gorm.ErrInvalidData), session)
+		if strings.Contains(err.Error(), "abc") {
+			t.Errorf("Expected session redacted, got %q", err.Error())
+		}
+		if !errors.Is(err, gorm.ErrInvalidData) {
+			t.Error("Expected redacted error to unwrap")
+		}
+	})
+
+	t.Run("gorm statements", func(t *testing.T) {
+		db := setupTestDB(t)
+		buf := captureLogs(t)
+
+		quiet := db.Session(&gorm.Session{Logger: logging.NewGorm(slog.LevelInfo, time.Hour)})
+		var documents []models.ApplicationDocument
+		quiet.Where("document_name = ?", "private-value").Find(&documents)
+		if buf.Len() != 0 {
+			t.Errorf("Expected no statement lines at info level, got %s", buf.String())
+		}
+
+		verbose := db.Session(&gorm.Session{Logger: logging.NewGorm(slog.LevelDebug, 0)})
+		verbose.Where("document_name = ?", "private-value").Find(&documents)
+		lines := logLines(t, buf)
+		if len(lines) != 1 || lines[0]["msg"] != "Database statement" || lines[0]["level"] != "DEBUG" {
+			t.Fatalf("Expected one debug statement line, got %s", buf.String())
+		}
+		if strings.Contains(fmt.Sprint(lines[0]["sql"]), "private-value") {
+			t.Errorf("Expected bound values left out, got %v", lines[0]["sql"])
+		}
+
+		buf.Reset()
+		slow := db.Session(&gorm.Session{Logger: logging.NewGorm(slog.LevelInfo, time.Nanosecond)})
+		slow.Find(&documents)
+		lines = logLines(t, buf)
+		if len(lines) != 1 || lines[0]["msg"] != "Slow database statement" || lines[0]["level"] != "WARN" {
+			t.Errorf("Expected one slow statement warning, got %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// metrics_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestDataMetrics tests the domain metrics recorded by committed and conflicting writes
+func TestDataMetrics(t *testing.T) {
+	store := services.GormStore{DB: setupTestDB(t)}
+
+	metrics := []struct {
+		name   string
+		labels map[string]string
+		delta  float64
+	}{
+		{"propsdb_data_changes_total", map[string]string{"scope": "app", "entity": "document", "action": "created"}, 1},
+		{"propsdb_data_changes_total", map[string]string{"scope": "app", "entity": "document", "action": "updated"}, 2},
+		{"propsdb_data_changes_total", map[string]string{"scope": "app", "entity": "collection", "action": "created"}, 1},
+		{"propsdb_data_changes_total", map[string]string{"scope": "app", "entity": "collection", "action": "deleted"}, 1},
+		{"propsdb_data_changes_total", map[string]string{"scope": "app", "entity": "property", "action": "created"}, 2},
+		{"propsdb_data_changes_total", map[string]string{"scope": "app", "entity": "property", "action": "updated"}, 1},
+		{"propsdb_orphans_removed_total", map[string]string{"scope": "app", "entity": "property"}, 2},
+		{"propsdb_version_conflicts_total", map[string]string{"scope": "app"}, 1},
+		{"propsdb_transaction_duration_seconds", map[string]string{"scope": "app", "operation": "set", "outcome": "committed"}, 2},
+		{"propsdb_transaction_duration_seconds", map[string]string{"scope": "app", "operation": "set", "outcome": "conflict"}, 1},
+		{"propsdb_lock_wait_seconds", map[string]string{"scope": "app"}, 4},
+	}
+	before := make([]float64, len(metrics))
+	for i, m := range metrics {
+		before[i] = metricValue(t, m.name, m.labels)
+	}
+
+	version, affected, err := store.SetApplicationProperties(t.Context(), "metrics", 0, []services.CollectionInput{{Collection: "settings", Properties: map[string]interface{}{"a": 1, "b": 2}}}, services.WriteOptions{})
+	expectMutation(t, version, affected, err, 1, 1)
+	_, _, err = store.SetApplicationProperties(t.Context(), "metrics", 0, []services.CollectionInput{{Collection: "settings", Properties: map[string]interface{}{"a": 2}}}, services.WriteOptions{})
+	expectError(t, err, "E_VERSION")
+	version, affected, err = store.SetApplicationProperties(t.Context(), "metrics", 1, []services.CollectionInput{{Collection: "settings", Properties: map[string]interface{}{"a": 3}}}, services.WriteOptions{})
+	expectMutation(t, version, affected, err, 2, 1)
+	version, affected, err = store.DeleteApplicationCollection(t.Context(), "metrics", 2, "settings", services.WriteOptions{})
+	expectMutation(t, version, affected, err, 3, 1)
+
+	for i, m := range metrics {
+		if delta := metricValue(t, m.name, m.labels) - before[i]; delta != m.delta {
+			t.Errorf("Expected %s%v to change by %v, got %v", m.name, m.labels, m.delta, delta)
+		}
+	}
+}
+
+// metricValue returns a counter's value, or a histogram's sample count, from the default registry
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matched := 0
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] == label.GetValue() {
+					matched++
+				}
+			}
+			if matched != len(labels) {
+				continue
+			}
+			if histogram := metric.GetHistogram(); histogram != nil {
+				return float64(histogram.GetSampleCount())
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
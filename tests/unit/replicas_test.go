@@ -0,0 +1,85 @@
+// replicas_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+	"gorm.io/gorm"
+)
+
+// TestReplicaRouting tests replica reads, read-your-writes stickiness and ejection
+func TestReplicaRouting(t *testing.T) {
+	primary := setupTestDB(t)
+	replica := setupTestDB(t)
+
+	// Only the replica has the document, so the status shows which pool served the read
+	helpers.CreateTestDocument(t, replica, "replicadoc", 1)
+	helpers.CreateTestCollection(t, replica, "replicadoc", "settings", map[string]interface{}{
+		"theme": "dark",
+	})
+
+	replicas := database.NewReplicaSet("app", primary, []*gorm.DB{replica}, 50*time.Millisecond)
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: primary, Replicas: replicas}
+	app.Get("/api/data/app/:document/:collection", handler.GetAppProperties)
+
+	get := func() int {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/replicadoc/settings", nil))
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := get(); status != 200 {
+		t.Fatalf("Expected read from replica (200), got %d", status)
+	}
+
+	replicas.MarkWrite("")
+	if status := get(); status != 404 {
+		t.Errorf("Expected read from primary inside sticky window (404), got %d", status)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if status := get(); status != 200 {
+		t.Errorf("Expected read from replica after sticky window (200), got %d", status)
+	}
+
+	// A replica that fails its health check is ejected
+	if err := database.Close(replica); err != nil {
+		t.Fatalf("Failed to close replica: %v", err)
+	}
+	replicas.Check()
+	if status := get(); status != 404 {
+		t.Errorf("Expected read from primary after ejection (404), got %d", status)
+	}
+
+	statuses := replicas.Status()
+	if len(statuses) != 1 || statuses[0].Name != "app-replica-0" || statuses[0].Healthy || statuses[0].Error == "" {
+		t.Errorf("Expected app-replica-0 ejected with an error, got %+v", statuses)
+	}
+}
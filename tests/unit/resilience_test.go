@@ -0,0 +1,291 @@
+// resilience_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/middleware"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/internal/types"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+	"gorm.io/gorm"
+)
+
+// failingQueries makes the queries of db fail as unreachable while failing is set, counting those that run
+func failingQueries(t *testing.T, db *gorm.DB, failing *atomic.Bool, ran *atomic.Int32) {
+	query := db.Callback().Query().Get("gorm:query")
+	err := db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		ran.Add(1)
+		if failing.Load() {
+			tx.AddError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+			return
+		}
+		query(tx)
+	})
+	if err != nil {
+		t.Fatalf("Failed to replace query callback: %v", err)
+	}
+}
+
+// TestCircuitBreaker tests that a pool's breaker opens on connection failures, fails fast and recovers
+func TestCircuitBreaker(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.Use(database.NewBreaker("app", 2, 50*time.Millisecond)); err != nil {
+		t.Fatalf("Failed to install breaker: %v", err)
+	}
+	var failing atomic.Bool
+	var ran atomic.Int32
+	failingQueries(t, db, &failing, &ran)
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db}
+	app.Get("/api/data/app/:document", handler.GetAppCollectionsAndProperties)
+	get := func() int {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/breakerdoc", nil))
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := get(); status != 404 {
+		t.Fatalf("Expected 404 while the database answers, got %d", status)
+	}
+
+	failing.Store(true)
+	for range 2 {
+		if status := get(); status != 503 {
+			t.Errorf("Expected 503 for a connection failure, got %d", status)
+		}
+	}
+	if database.Available(db) == nil {
+		t.Fatal("Expected the breaker open after 2 connection failures")
+	}
+
+	ran.Store(0)
+	if status := get(); status != 503 {
+		t.Errorf("Expected 503 while the breaker is open, got %d", status)
+	}
+	store := services.GormStore{DB: db}
+	if _, err := store.GetApplicationDocumentsCollectionsAndProperties(t.Context(), services.ReadOptions{}); !errors.Is(err, database.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+	if ran.Load() != 0 {
+		t.Errorf("Expected no queries while the breaker is open, %d ran", ran.Load())
+	}
+
+	// A failed probe opens the breaker for another cooldown
+	time.Sleep(60 * time.Millisecond)
+	if status := get(); status != 503 || ran.Load() != 1 {
+		t.Errorf("Expected one probe failing with 503, got %d after %d queries", status, ran.Load())
+	}
+	if status := get(); status != 503 {
+		t.Errorf("Expected 503 after a failed probe, got %d", status)
+	}
+
+	// A successful probe closes it
+	failing.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	if status := get(); status != 404 {
+		t.Errorf("Expected the probe to reach the database, got %d", status)
+	}
+	if database.Available(db) != nil {
+		t.Error("Expected the breaker closed after a successful probe")
+	}
+}
+
+// TestQueryTimeout tests that store calls are bounded by the store timeout and answered with 503,
+// without opening the circuit breaker of a database that is slow but reachable
+func TestQueryTimeout(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.Use(database.NewBreaker("app", 1, time.Minute)); err != nil {
+		t.Fatalf("Failed to install breaker: %v", err)
+	}
+	slowQueries(t, db)
+
+	app := fiber.New()
+	handler := &handlers.AppDataHandler{DB: db, Timeout: 20 * time.Millisecond}
+	app.Get("/api/data/app/:document", handler.GetAppCollectionsAndProperties)
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/slowdoc", nil))
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Expected 503 for a timed out query, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the query timeout to apply, took %s", elapsed)
+	}
+
+	// Drivers may report the caller's deadline as a network error
+	err = db.Callback().Query().Before("gorm:query").Register("test:driver_timeout", func(tx *gorm.DB) {
+		tx.AddError(&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded})
+	})
+	if err != nil {
+		t.Fatalf("Failed to register driver timeout callback: %v", err)
+	}
+	if resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/slowdoc", nil)); err != nil || resp.StatusCode != 503 {
+		t.Errorf("Expected 503 for a driver timeout, got %v %v", resp, err)
+	}
+
+	if database.Available(db) != nil {
+		t.Error("Expected timed out queries to leave the breaker closed")
+	}
+	if database.IsConnectionError(context.DeadlineExceeded) {
+		t.Error("Expected a deadline not to be a connection error")
+	}
+}
+
+// TestConnectWithRetry tests that startup connections are retried until DB_CONNECT_RETRY_SECONDS pass
+func TestConnectWithRetry(t *testing.T) {
+	unreachable := errors.New("connection refused")
+
+	attempts := 0
+	db, err := database.ConnectWithRetry(&config.Config{DBConnectRetrySeconds: 5}, func(*config.Config) (*gorm.DB, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, unreachable
+		}
+		return &gorm.DB{}, nil
+	})
+	if err != nil || db == nil || attempts != 3 {
+		t.Errorf("Expected a connection on the third attempt, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	_, err = database.ConnectWithRetry(&config.Config{}, func(*config.Config) (*gorm.DB, error) {
+		attempts++
+		return nil, unreachable
+	})
+	if !errors.Is(err, unreachable) || attempts != 1 {
+		t.Errorf("Expected one attempt without retries, got %v after %d", err, attempts)
+	}
+}
+
+// slowQueries makes the queries of db wait until their context is done
+func slowQueries(t *testing.T, db *gorm.DB) {
+	err := db.Callback().Query().Before("gorm:query").Register("test:slow", func(tx *gorm.DB) {
+		<-tx.Statement.Context.Done()
+	})
+	if err != nil {
+		t.Fatalf("Failed to register slow callback: %v", err)
+	}
+}
+
+// TestRequestContext tests that store calls follow the caller's context, route deadlines and shutdown draining
+func TestRequestContext(t *testing.T) {
+	t.Run("caller deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+
+		store := services.GormStore{DB: setupTestDB(t), Timeout: time.Minute}
+		if _, err := store.GetApplicationDocumentsCollectionsAndProperties(ctx, services.ReadOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the caller's deadline, got %v", err)
+		}
+	})
+
+	t.Run("route deadline", func(t *testing.T) {
+		db := setupTestDB(t)
+		slowQueries(t, db)
+
+		app := fiber.New()
+		handler := &handlers.AppDataHandler{DB: db}
+		app.Get("/api/data/app/:document", middleware.Deadline(20*time.Millisecond, time.Minute), handler.GetAppCollectionsAndProperties)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/slowdoc", nil), -1)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		var result map[string]interface{}
+		helpers.ParseJSON(t, resp, &result)
+		if resp.StatusCode != 503 || result["type"] != "data.timeout" {
+			t.Errorf("Expected a 503 timeout at the read deadline, got %d %v", resp.StatusCode, result)
+		}
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		db := setupTestDB(t)
+		slowQueries(t, db)
+
+		// Nothing in flight drains at once
+		if !middleware.NewDrainer().Drain(t.Context(), time.Second) {
+			t.Error("Expected an idle drainer to drain")
+		}
+
+		drainer := middleware.NewDrainer()
+		app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+			var customErr *types.CustomError
+			if errors.As(err, &customErr) {
+				return c.Status(customErr.Code).SendString(customErr.Type)
+			}
+			return fiber.DefaultErrorHandler(c, err)
+		}})
+		app.Use(drainer.Handler())
+		handler := &handlers.AppDataHandler{DB: db}
+		app.Get("/api/data/app/:document", handler.GetAppCollectionsAndProperties)
+
+		status := make(chan int, 1)
+		go func() {
+			resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/slowdoc", nil), -1)
+			if err != nil {
+				status <- 0
+				return
+			}
+			status <- resp.StatusCode
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		if drainer.Drain(ctx, time.Second) {
+			t.Error("Expected the request still in flight at the drain timeout")
+		}
+		if code := <-status; code != 503 {
+			t.Errorf("Expected the canceled request to answer 503, got %d", code)
+		}
+
+		// Requests arriving after draining started are refused and their connection closed
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/data/app/slowdoc", nil), -1)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != 503 || string(body) != "data.unavailable" || !resp.Close {
+			t.Errorf("Expected a closed 503 after draining started, got %d %s close %v", resp.StatusCode, body, resp.Close)
+		}
+	})
+}
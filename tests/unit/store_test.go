@@ -0,0 +1,898 @@
+// store_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/database"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+	"gorm.io/gorm"
+)
+
+// TestGormStore runs the Store conformance suite against GORM and SQLite
+func TestGormStore(t *testing.T) {
+	runStoreConformance(t, func(t *testing.T) services.Store {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("Failed to create test database: %v", err)
+		}
+		// Every connection to :memory: is a new database, so keep to one
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf("Failed to get underlying SQL DB: %v", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+
+		if err := database.AutoMigrate(db); err != nil {
+			t.Fatalf("Failed to migrate test database: %v", err)
+		}
+		return services.GormStore{DB: db}
+	})
+}
+
+// TestMemoryStore runs the Store conformance suite against the in-memory store
+func TestMemoryStore(t *testing.T) {
+	runStoreConformance(t, func(t *testing.T) services.Store {
+		return services.NewMemoryStore()
+	})
+}
+
+// TestUserHandlers_MemoryStore tests user routes backed by the in-memory store, without a database
+func TestUserHandlers_MemoryStore(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"id": "user-789"})
+		return c.Next()
+	})
+
+	handler := &handlers.UserDataHandler{Store: services.NewMemoryStore()}
+	app.Get("/api/data/user/:document", handler.GetUserCollectionsAndProperties)
+	app.Post("/api/data/user/:document", handler.SetUserProperties)
+
+	req := httptest.NewRequest("POST", "/api/data/user/prefs", strings.NewReader(
+		`{"version":"0","collections":[{"collection":"settings","properties":{"theme":"dark"}}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 200)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/data/user/prefs", nil))
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 200)
+
+	var result map[string]map[string]interface{}
+	helpers.ParseJSON(t, resp, &result)
+	expected := map[string]interface{}{"__version": "1", "settings": map[string]interface{}{"theme": "dark"}}
+	if !reflect.DeepEqual(result["prefs"], expected) {
+		t.Errorf("Expected %v, got %v", expected, result["prefs"])
+	}
+}
+
+// runStoreConformance verifies the behavior every Store implementation must share
+func runStoreConformance(t *testing.T, newStore func(t *testing.T) services.Store) {
+	settings := func(props map[string]interface{}) []services.CollectionInput {
+		return []services.CollectionInput{{Collection: "settings", Properties: props}}
+	}
+
+	t.Run("app set and get", func(t *testing.T) {
+		store := newStore(t)
+
+		version, affected, err := store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{
+			"theme": "dark",
+			"tags":  []interface{}{"a", "b"},
+		}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+
+		result, err := store.GetApplicationProperties(t.Context(), "home", "settings", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{
+				"__version": "1",
+				"settings":  map[string]interface{}{"theme": "dark", "tags": []interface{}{"a", "b"}},
+			},
+		})
+
+		// Unchanged values leave the version alone
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 0)
+
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "light"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "blue"}), services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.SetApplicationProperties(t.Context(), "other", 3, settings(map[string]interface{}{"theme": "blue"}), services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+	})
+
+	t.Run("app reads", func(t *testing.T) {
+		store := newStore(t)
+
+		_, err := store.GetApplicationDocumentsCollectionsAndProperties(t.Context(), services.ReadOptions{})
+		expectError(t, err, "not found")
+
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark", "size": "large"}},
+			{Collection: "content", Properties: map[string]interface{}{"title": "Home"}},
+		}, services.WriteOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, err = store.GetApplicationProperties(t.Context(), "missing", "settings", services.ReadOptions{})
+		expectError(t, err, "not found")
+		_, err = store.GetApplicationProperties(t.Context(), "home", "missing", services.ReadOptions{})
+		expectError(t, err, "not found")
+		_, err = store.GetApplicationCollectionsAndProperties(t.Context(), "home", []string{"missing"}, services.ReadOptions{})
+		expectError(t, err, "not found")
+
+		result, err := store.GetApplicationCollectionsAndProperties(t.Context(), "home", []string{"content", "missing"}, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{
+				"__version": "1",
+				"content":   map[string]interface{}{"title": "Home"},
+			},
+		})
+
+		result, err = store.GetApplicationDocumentsCollectionsAndProperties(t.Context(), services.ReadOptions{
+			Fields: services.ParseProjection([]string{"theme", "title"}),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{
+				"__version": "1",
+				"settings":  map[string]interface{}{"theme": "dark"},
+				"content":   map[string]interface{}{"title": "Home"},
+			},
+		})
+	})
+
+	t.Run("app collections are shared by name", func(t *testing.T) {
+		store := newStore(t)
+
+		if _, _, err := store.SetApplicationProperties(t.Context(), "first", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, _, err := store.SetApplicationProperties(t.Context(), "second", 0, settings(map[string]interface{}{"size": "large"}), services.WriteOptions{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result, err := store.GetApplicationProperties(t.Context(), "first", "settings", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"first": map[string]interface{}{
+				"__version": "1",
+				"settings":  map[string]interface{}{"theme": "dark", "size": "large"},
+			},
+		})
+
+		// Property versions written through another document are not conflicts
+		for version := uint64(1); version < 4; version++ {
+			_, _, err := store.SetApplicationProperties(t.Context(), "first", version, settings(map[string]interface{}{"theme": fmt.Sprint("theme-", version)}), services.WriteOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		version, affected, err := store.SetApplicationProperties(t.Context(), "third", 0, settings(map[string]interface{}{"theme": "light"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.SetApplicationProperties(t.Context(), "third", 1, settings(map[string]interface{}{"theme": "blue"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+		merge := services.WriteOptions{MergeStrategy: services.MergeProperties}
+		_, _, err = store.SetApplicationProperties(t.Context(), "first", 4, settings(map[string]interface{}{"theme": "red"}), services.WriteOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		version, affected, err = store.SetApplicationProperties(t.Context(), "third", 1, settings(map[string]interface{}{"theme": "green"}), merge)
+		expectMutation(t, version, affected, err, 3, 1)
+	})
+
+	t.Run("app deletes", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, err := store.SetApplicationProperties(t.Context(), "home", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark", "size": "large"}},
+			{Collection: "content", Properties: map[string]interface{}{"title": "Home"}},
+			{Collection: "extra", Properties: map[string]interface{}{"flag": "on"}},
+		}, services.WriteOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, _, err = store.DeleteApplicationCollection(t.Context(), "home", 0, "extra", services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.DeleteApplicationCollection(t.Context(), "home", 1, "missing", services.WriteOptions{})
+		expectError(t, err, "collection not found")
+
+		version, affected, err := store.DeleteApplicationCollection(t.Context(), "home", 1, "extra", services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+		_, err = store.GetApplicationProperties(t.Context(), "home", "extra", services.ReadOptions{})
+		expectError(t, err, "not found")
+
+		// Nothing to delete leaves the version alone
+		version, affected, err = store.DeleteApplicationProperties(t.Context(), "home", 2, []services.DeleteCollectionInput{
+			{Collection: "settings", Properties: []string{"missing"}},
+			{Collection: "missing"},
+		}, false, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 0)
+
+		version, affected, err = store.DeleteApplicationProperties(t.Context(), "home", 2, []services.DeleteCollectionInput{
+			{Collection: "settings", Properties: []string{"size"}},
+			{Collection: "content"},
+		}, false, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 3, 1)
+
+		result, err := store.GetApplicationCollectionsAndProperties(t.Context(), "home", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{
+				"__version": "3",
+				"settings":  map[string]interface{}{"theme": "dark"},
+			},
+		})
+
+		version, affected, err = store.DeleteApplicationProperties(t.Context(), "home", 3, nil, true, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 0, 1)
+		_, err = store.GetApplicationCollectionsAndProperties(t.Context(), "home", nil, services.ReadOptions{})
+		expectError(t, err, "not found")
+		_, _, err = store.DeleteApplicationProperties(t.Context(), "home", 3, nil, true, services.WriteOptions{})
+		expectError(t, err, "not found")
+	})
+
+	t.Run("user documents are isolated", func(t *testing.T) {
+		store := newStore(t)
+
+		version, affected, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.SetUserProperties(t.Context(), "user-2", "prefs", 0, settings(map[string]interface{}{"size": "large"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+
+		result, err := store.GetUserProperties(t.Context(), "user-1", "prefs", "settings", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{
+				"__version": "1",
+				"settings":  map[string]interface{}{"theme": "dark"},
+			},
+		})
+
+		_, err = store.GetUserDocumentsCollectionsAndProperties(t.Context(), "user-3", services.ReadOptions{})
+		expectError(t, err, "not found")
+		_, err = store.GetUserCollectionsAndProperties(t.Context(), "user-1", "prefs", []string{"missing"}, services.ReadOptions{})
+		expectError(t, err, "not found")
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 0, settings(map[string]interface{}{"theme": "light"}), services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+	})
+
+	t.Run("merge strategy", func(t *testing.T) {
+		store := newStore(t)
+		merge := services.WriteOptions{MergeStrategy: services.MergeProperties}
+
+		version, affected, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, settings(map[string]interface{}{
+			"theme": "dark",
+			"size":  "large",
+		}), merge)
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"theme": "light"}), merge)
+		expectMutation(t, version, affected, err, 2, 1)
+
+		// Stale base, but size is unchanged since version 1
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"size": "small"}), merge)
+		expectMutation(t, version, affected, err, 3, 1)
+
+		// Stale base, and theme changed at version 2
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"theme": "blue"}), merge)
+		expectError(t, err, "E_VERSION")
+
+		// Writing the current value is not a conflict
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"theme": "light"}), merge)
+		expectMutation(t, version, affected, err, 3, 0)
+
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"size": "medium"}), services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 4, settings(map[string]interface{}{"size": "medium"}), merge)
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "missing", 2, settings(map[string]interface{}{"size": "medium"}), merge)
+		expectError(t, err, "E_VERSION")
+
+		result, err := store.GetUserCollectionsAndProperties(t.Context(), "user-1", "prefs", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{
+				"__version": "3",
+				"settings":  map[string]interface{}{"theme": "light", "size": "small"},
+			},
+		})
+	})
+
+	t.Run("any version", func(t *testing.T) {
+		store := newStore(t)
+		anyVersion := services.WriteOptions{AnyVersion: true}
+
+		// Any version needs an existing document
+		_, _, err := store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"theme": "dark"}), anyVersion)
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.DeleteApplicationProperties(t.Context(), "home", 0, nil, true, anyVersion)
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.RenameApplicationDocument(t.Context(), "home", 0, "page", anyVersion)
+		expectError(t, err, "E_VERSION")
+
+		_, _, _ = store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		_, _, _ = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "light"}), services.WriteOptions{})
+
+		version, affected, err := store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"size": "large"}), anyVersion)
+		expectMutation(t, version, affected, err, 3, 1)
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"theme": "blue"}), services.WriteOptions{AnyVersion: true, MergeStrategy: services.MergeProperties})
+		expectMutation(t, version, affected, err, 4, 1)
+		version, affected, err = store.CopyApplicationDocument(t.Context(), "home", 0, "page", anyVersion)
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.DeleteApplicationCollection(t.Context(), "page", 0, "settings", anyVersion)
+		expectMutation(t, version, affected, err, 2, 1)
+		version, affected, err = store.RenameApplicationDocument(t.Context(), "page", 0, "landing", anyVersion)
+		expectMutation(t, version, affected, err, 3, 1)
+
+		_, _, _ = store.SetUserProperties(t.Context(), "user-1", "prefs", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		version, affected, err = store.DeleteUserProperties(t.Context(), "user-1", "prefs", 0, []services.DeleteCollectionInput{
+			{Collection: "settings", Properties: []string{"theme"}},
+		}, false, anyVersion)
+		expectMutation(t, version, affected, err, 2, 1)
+		_, _, err = store.DeleteUserCollection(t.Context(), "user-1", "missing", 0, "settings", anyVersion)
+		expectError(t, err, "E_VERSION")
+	})
+
+	t.Run("property metadata", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, settings(map[string]interface{}{
+			"theme": "dark",
+			"size":  "large",
+		}), services.WriteOptions{Actor: "user-1"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"theme": "light"}), services.WriteOptions{Actor: "admin-1"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result, err := store.GetUserProperties(t.Context(), "user-1", "prefs", "settings", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := result["prefs"].(map[string]interface{})[services.MetaKey]; ok {
+			t.Errorf("Expected no metadata unless requested, got %v", result)
+		}
+
+		result, err = store.GetUserProperties(t.Context(), "user-1", "prefs", "settings", services.ReadOptions{
+			Fields:      services.ParseProjection([]string{"theme"}),
+			IncludeMeta: true,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		meta, ok := result["prefs"].(map[string]interface{})[services.MetaKey].(services.DocumentMeta)
+		if !ok {
+			t.Fatalf("Expected document metadata, got %v", result)
+		}
+		if len(meta["settings"]) != 1 {
+			t.Errorf("Expected metadata for the projected property only, got %v", meta)
+		}
+		theme := meta["settings"]["theme"]
+		if theme.Version != "2" || theme.ModifiedBy != "admin-1" || theme.CreatedAt.IsZero() || theme.UpdatedAt.Before(theme.CreatedAt) {
+			t.Errorf("Unexpected theme metadata: %+v", theme)
+		}
+
+		result, err = store.GetUserCollectionsAndProperties(t.Context(), "user-1", "prefs", nil, services.ReadOptions{IncludeMeta: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		size := result["prefs"].(map[string]interface{})[services.MetaKey].(services.DocumentMeta)["settings"]["size"]
+		if size.Version != "1" || size.ModifiedBy != "user-1" {
+			t.Errorf("Unexpected size metadata: %+v", size)
+		}
+	})
+
+	t.Run("user deletes", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark", "size": "large"}},
+			{Collection: "extra", Properties: map[string]interface{}{"flag": "on"}},
+		}, services.WriteOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, _, err = store.DeleteUserCollection(t.Context(), "user-2", "prefs", 1, "extra", services.WriteOptions{})
+		expectError(t, err, "not found")
+
+		version, affected, err := store.DeleteUserCollection(t.Context(), "user-1", "prefs", 1, "extra", services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+
+		version, affected, err = store.DeleteUserProperties(t.Context(), "user-1", "prefs", 2, []services.DeleteCollectionInput{
+			{Collection: "settings", Properties: []string{"size"}},
+		}, false, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 3, 1)
+
+		result, err := store.GetUserDocumentsCollectionsAndProperties(t.Context(), "user-1", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{
+				"__version": "3",
+				"settings":  map[string]interface{}{"theme": "dark"},
+			},
+		})
+
+		version, affected, err = store.DeleteUserProperties(t.Context(), "user-1", "prefs", 3, nil, true, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 0, 1)
+		_, err = store.GetUserDocumentsCollectionsAndProperties(t.Context(), "user-1", services.ReadOptions{})
+		expectError(t, err, "not found")
+	})
+
+	t.Run("trash and restore", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}},
+			{Collection: "extra", Properties: map[string]interface{}{"flag": "on"}},
+		}, services.WriteOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		version, affected, err := store.DeleteUserCollection(t.Context(), "user-1", "prefs", 1, "extra", services.WriteOptions{Actor: "user-1"})
+		expectMutation(t, version, affected, err, 2, 1)
+		version, affected, err = store.DeleteUserProperties(t.Context(), "user-1", "prefs", 2, nil, true, services.WriteOptions{Actor: "user-1"})
+		expectMutation(t, version, affected, err, 0, 1)
+
+		trash, err := store.GetUserTrash(t.Context(), "user-1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(trash) != 2 {
+			t.Fatalf("Expected 2 trash entries, got %+v", trash)
+		}
+		if trash[0].Collection != "" || trash[0].Version != "2" || trash[0].DeletedBy != "user-1" ||
+			!reflect.DeepEqual(trash[0].Collections, map[string]map[string]interface{}{"settings": {"theme": "dark"}}) {
+			t.Errorf("Unexpected document trash entry %+v", trash[0])
+		}
+		if trash[1].Collection != "extra" || trash[1].Version != "1" ||
+			!reflect.DeepEqual(trash[1].Collections, map[string]map[string]interface{}{"extra": {"flag": "on"}}) {
+			t.Errorf("Unexpected collection trash entry %+v", trash[1])
+		}
+		if other, _ := store.GetUserTrash(t.Context(), "user-2"); len(other) != 0 {
+			t.Errorf("Expected no trash for another user, got %+v", other)
+		}
+
+		_, _, err = store.RestoreUserTrash(t.Context(), "user-2", "prefs", "", services.WriteOptions{})
+		expectError(t, err, "not found")
+
+		// Restores continue past the deleted version
+		version, affected, err = store.RestoreUserTrash(t.Context(), "user-1", "prefs", "", services.WriteOptions{Actor: "user-1"})
+		expectMutation(t, version, affected, err, 3, 1)
+		version, affected, err = store.RestoreUserTrash(t.Context(), "user-1", "prefs", "extra", services.WriteOptions{Actor: "user-1"})
+		expectMutation(t, version, affected, err, 4, 1)
+
+		result, err := store.GetUserDocumentsCollectionsAndProperties(t.Context(), "user-1", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{
+				"__version": "4",
+				"settings":  map[string]interface{}{"theme": "dark"},
+				"extra":     map[string]interface{}{"flag": "on"},
+			},
+		})
+		if trash, _ := store.GetUserTrash(t.Context(), "user-1"); len(trash) != 0 {
+			t.Errorf("Expected restored entries to leave the trash, got %+v", trash)
+		}
+
+		// Restores never overwrite existing data
+		_, _, _ = store.DeleteUserCollection(t.Context(), "user-1", "prefs", 4, "extra", services.WriteOptions{})
+		_, _, _ = store.SetUserProperties(t.Context(), "user-1", "prefs", 5, []services.CollectionInput{
+			{Collection: "extra", Properties: map[string]interface{}{"flag": "off"}},
+		}, services.WriteOptions{})
+		_, _, err = store.RestoreUserTrash(t.Context(), "user-1", "prefs", "extra", services.WriteOptions{})
+		expectError(t, err, "already exists")
+
+		// A write based on a version before the delete still conflicts after the restore
+		for version := uint64(0); version < 4; version++ {
+			_, _, _ = store.SetApplicationProperties(t.Context(), "home", version, settings(map[string]interface{}{"theme": fmt.Sprint("theme-", version)}), services.WriteOptions{})
+		}
+		_, _, _ = store.DeleteApplicationProperties(t.Context(), "home", 4, nil, true, services.WriteOptions{})
+		version, affected, err = store.RestoreApplicationTrash(t.Context(), "home", "", services.WriteOptions{})
+		expectMutation(t, version, affected, err, 5, 1)
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "stale"}), services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+
+		// App documents go to the app trash, and purging removes entries deleted before the cutoff
+		_, _, _ = store.DeleteApplicationProperties(t.Context(), "home", 5, nil, true, services.WriteOptions{Actor: "admin"})
+		appTrash, err := store.GetApplicationTrash(t.Context())
+		if err != nil || len(appTrash) != 1 || appTrash[0].Document != "home" || appTrash[0].DeletedBy != "admin" {
+			t.Fatalf("Unexpected app trash %+v, error %v", appTrash, err)
+		}
+
+		purged, err := store.PurgeTrash(t.Context(), time.Now().Add(-time.Hour))
+		if err != nil || purged != 0 {
+			t.Errorf("Expected nothing purged before the cutoff, got %d, error %v", purged, err)
+		}
+		purged, err = store.PurgeTrash(t.Context(), time.Now().Add(time.Hour))
+		if err != nil || purged != 2 {
+			t.Errorf("Expected 2 entries purged, got %d, error %v", purged, err)
+		}
+		_, _, err = store.RestoreApplicationTrash(t.Context(), "home", "", services.WriteOptions{})
+		expectError(t, err, "not found")
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}, Expiry: services.Expiry{TTL: -1}},
+		}, services.WriteOptions{})
+		expectError(t, err, "invalid input")
+		_, _, err = store.SetUserProperties(t.Context(), "user-1", "prefs", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}, PropertyExpiry: map[string]services.Expiry{"token": {TTL: 1}}},
+		}, services.WriteOptions{})
+		expectError(t, err, "invalid input")
+
+		version, affected, err := store.SetUserProperties(t.Context(), "user-1", "prefs", 0, []services.CollectionInput{
+			{
+				Collection:     "settings",
+				Properties:     map[string]interface{}{"theme": "dark", "token": "abc"},
+				PropertyExpiry: map[string]services.Expiry{"token": {TTL: 1}},
+			},
+			{Collection: "session", Properties: map[string]interface{}{"id": "s1"}, Expiry: services.Expiry{TTL: 1}},
+		}, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "temp", 0, settings(map[string]interface{}{"draft": "x"}), services.WriteOptions{Expiry: services.Expiry{TTL: 1}})
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 0, []services.CollectionInput{
+			{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}},
+			{Collection: "banner", Properties: map[string]interface{}{"text": "sale"}, Expiry: services.Expiry{TTL: 1}},
+		}, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+
+		if swept, err := store.SweepExpired(t.Context(), time.Now()); err != nil || swept != 0 {
+			t.Errorf("Expected nothing swept before expiry, got %d, error %v", swept, err)
+		}
+
+		time.Sleep(1100 * time.Millisecond)
+
+		// Expired data is hidden before the sweeper runs
+		result, err := store.GetUserDocumentsCollectionsAndProperties(t.Context(), "user-1", services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{
+				"__version": "1",
+				"settings":  map[string]interface{}{"theme": "dark"},
+			},
+		})
+		_, err = store.GetUserProperties(t.Context(), "user-1", "prefs", "session", services.ReadOptions{})
+		expectError(t, err, "not found")
+		result, err = store.GetApplicationCollectionsAndProperties(t.Context(), "home", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{
+				"__version": "1",
+				"settings":  map[string]interface{}{"theme": "dark"},
+			},
+		})
+
+		var events []services.MutationEvent
+		unsubscribe := services.OnMutation(func(event services.MutationEvent) {
+			events = append(events, event)
+		})
+		defer unsubscribe()
+
+		swept, err := store.SweepExpired(t.Context(), time.Now())
+		if err != nil || swept != 3 {
+			t.Fatalf("Expected 3 documents swept, got %d, error %v", swept, err)
+		}
+		sort.Slice(events, func(i, j int) bool { return events[i].Document < events[j].Document })
+		expected := []services.MutationEvent{
+			{Scope: services.ScopeApp, Document: "home", Version: 2},
+			{Scope: services.ScopeUser, UserID: "user-1", Document: "prefs", Version: 2},
+			{Scope: services.ScopeUser, UserID: "user-1", Document: "temp"},
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("Expected events %+v, got %+v", expected, events)
+		}
+
+		result, err = store.GetUserCollectionsAndProperties(t.Context(), "user-1", "prefs", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{
+				"__version": "2",
+				"settings":  map[string]interface{}{"theme": "dark"},
+			},
+		})
+
+		// An expired document is written anew
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "temp", 0, settings(map[string]interface{}{"draft": "y"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+	})
+
+	t.Run("copy, rename and template", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, _ = store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		_, _, _ = store.SetApplicationProperties(t.Context(), "about", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+
+		_, _, err := store.CopyApplicationDocument(t.Context(), "home", 0, "page", services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+		_, _, err = store.CopyApplicationDocument(t.Context(), "home", 1, "about", services.WriteOptions{})
+		expectError(t, err, "already exists")
+		_, _, err = store.CopyApplicationDocument(t.Context(), "home", 1, "home", services.WriteOptions{})
+		expectError(t, err, "invalid input")
+		_, _, err = store.CopyApplicationDocument(t.Context(), "missing", 1, "page", services.WriteOptions{})
+		expectError(t, err, "not found")
+
+		version, affected, err := store.CopyApplicationDocument(t.Context(), "home", 1, "page", services.WriteOptions{Actor: "admin"})
+		expectMutation(t, version, affected, err, 1, 1)
+
+		version, affected, err = store.RenameApplicationDocument(t.Context(), "page", 1, "landing", services.WriteOptions{Actor: "admin"})
+		expectMutation(t, version, affected, err, 2, 1)
+		_, err = store.GetApplicationCollectionsAndProperties(t.Context(), "page", nil, services.ReadOptions{})
+		expectError(t, err, "not found")
+		_, _, err = store.RenameApplicationDocument(t.Context(), "landing", 2, "home", services.WriteOptions{})
+		expectError(t, err, "already exists")
+
+		result, err := store.GetApplicationDocumentsCollectionsAndProperties(t.Context(), services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		settingsMap := map[string]interface{}{"theme": "dark"}
+		expectResult(t, result, services.DocumentResult{
+			"home":    map[string]interface{}{"__version": "1", "settings": settingsMap},
+			"about":   map[string]interface{}{"__version": "1", "settings": settingsMap},
+			"landing": map[string]interface{}{"__version": "2", "settings": settingsMap},
+		})
+
+		version, affected, err = store.CreateUserDocumentFromTemplate(t.Context(), "user-1", "prefs", "landing", services.WriteOptions{Actor: "user-1"})
+		expectMutation(t, version, affected, err, 1, 1)
+		_, _, err = store.CreateUserDocumentFromTemplate(t.Context(), "user-1", "prefs", "landing", services.WriteOptions{})
+		expectError(t, err, "already exists")
+		_, _, err = store.CreateUserDocumentFromTemplate(t.Context(), "user-1", "other", "missing", services.WriteOptions{})
+		expectError(t, err, "not found")
+
+		// The user document is independent of its template
+		_, _, _ = store.SetUserProperties(t.Context(), "user-1", "prefs", 1, settings(map[string]interface{}{"theme": "light"}), services.WriteOptions{})
+		result, err = store.GetApplicationCollectionsAndProperties(t.Context(), "landing", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"landing": map[string]interface{}{"__version": "2", "settings": settingsMap},
+		})
+		result, err = store.GetUserCollectionsAndProperties(t.Context(), "user-1", "prefs", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"prefs": map[string]interface{}{"__version": "2", "settings": map[string]interface{}{"theme": "light"}},
+		})
+	})
+
+	t.Run("copies are independent", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _, _ = store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		version, affected, err := store.CopyApplicationDocument(t.Context(), "home", 1, "page", services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+
+		version, affected, err = store.SetApplicationProperties(t.Context(), "page", 1, settings(map[string]interface{}{"theme": "light", "size": "large"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+		version, affected, err = store.DeleteApplicationProperties(t.Context(), "page", 2, []services.DeleteCollectionInput{
+			{Collection: "settings", Properties: []string{"size"}},
+		}, false, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 3, 1)
+
+		result, err := store.GetApplicationDocumentsCollectionsAndProperties(t.Context(), services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{"__version": "1", "settings": map[string]interface{}{"theme": "dark"}},
+			"page": map[string]interface{}{"__version": "3", "settings": map[string]interface{}{"theme": "light"}},
+		})
+
+		// The source is written at its own version
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "blue"}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+	})
+
+	t.Run("atomic operations", func(t *testing.T) {
+		store := newStore(t)
+		atomic := services.WriteOptions{MergeStrategy: services.MergeAtomic}
+		counters := func(ops services.Operations) []services.CollectionInput {
+			return []services.CollectionInput{{Collection: "counters", Operations: ops}}
+		}
+
+		version, affected, err := store.SetUserProperties(t.Context(), "user-1", "stats", 0, []services.CollectionInput{{
+			Collection: "counters",
+			Properties: map[string]interface{}{
+				"visits": 1,
+				"daily":  map[string]interface{}{"max": 3},
+				"tags":   []interface{}{"a"},
+				"old":    true,
+			},
+		}}, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+
+		// A stale base is fine, operations apply to the current values
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "stats", 0, counters(services.Operations{
+			"$inc":      {"visits": 2, "daily.count": 1},
+			"$max":      {"daily.max": 5},
+			"$push":     {"tags": map[string]interface{}{"$each": []interface{}{"b", "c"}}},
+			"$addToSet": {"daily.seen": "x"},
+			"$unset":    {"old": true},
+		}), atomic)
+		expectMutation(t, version, affected, err, 2, 1)
+		version, affected, err = store.SetUserProperties(t.Context(), "user-1", "stats", 1, counters(services.Operations{
+			"$inc":      {"visits": 1},
+			"$pull":     {"tags": "b"},
+			"$min":      {"daily.max": 1},
+			"$addToSet": {"daily.seen": "x"},
+		}), atomic)
+		expectMutation(t, version, affected, err, 3, 1)
+
+		result, err := store.GetUserCollectionsAndProperties(t.Context(), "user-1", "stats", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"stats": map[string]interface{}{
+				"__version": "3",
+				"counters": map[string]interface{}{
+					"visits": float64(4),
+					"daily":  map[string]interface{}{"max": float64(1), "count": float64(1), "seen": []interface{}{"x"}},
+					"tags":   []interface{}{"a", "c"},
+				},
+			},
+		})
+
+		// Operations with plain properties need the exact version outside the atomic strategy
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 0, []services.CollectionInput{{
+			Collection: "counters",
+			Properties: map[string]interface{}{"label": "home"},
+			Operations: services.Operations{"$inc": {"visits": 1.5}},
+		}}, services.WriteOptions{})
+		expectMutation(t, version, affected, err, 1, 1)
+		version, affected, err = store.SetApplicationProperties(t.Context(), "home", 1, counters(services.Operations{"$inc": {"visits": 1}}), services.WriteOptions{})
+		expectMutation(t, version, affected, err, 2, 1)
+		result, err = store.GetApplicationCollectionsAndProperties(t.Context(), "home", nil, services.ReadOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectResult(t, result, services.DocumentResult{
+			"home": map[string]interface{}{
+				"__version": "2",
+				"counters":  map[string]interface{}{"label": "home", "visits": 2.5},
+			},
+		})
+
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 2, []services.CollectionInput{{
+			Collection: "counters",
+			Properties: map[string]interface{}{"label": "about"},
+			Operations: services.Operations{"$inc": {"visits": 1}},
+		}}, atomic)
+		expectError(t, err, "invalid input")
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 2, []services.CollectionInput{{
+			Collection: "counters",
+			Properties: map[string]interface{}{"visits": 1},
+			Operations: services.Operations{"$inc": {"visits": 1}},
+		}}, services.WriteOptions{})
+		expectError(t, err, "invalid input")
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 2, counters(services.Operations{"$inc": {"label": 1}}), atomic)
+		expectError(t, err, "invalid input")
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 2, counters(services.Operations{"$mul": {"visits": 2}}), atomic)
+		expectError(t, err, "invalid input")
+		_, _, err = store.SetApplicationProperties(t.Context(), "home", 1, counters(services.Operations{"$inc": {"visits": 1}}), services.WriteOptions{})
+		expectError(t, err, "E_VERSION")
+	})
+
+	t.Run("mutation events", func(t *testing.T) {
+		store := newStore(t)
+
+		var events []services.MutationEvent
+		unsubscribe := services.OnMutation(func(event services.MutationEvent) {
+			events = append(events, event)
+		})
+		defer unsubscribe()
+
+		_, _, _ = store.SetApplicationProperties(t.Context(), "home", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		_, _, _ = store.SetApplicationProperties(t.Context(), "home", 1, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+		_, _, _ = store.SetUserProperties(t.Context(), "user-1", "prefs", 0, settings(map[string]interface{}{"theme": "dark"}), services.WriteOptions{})
+
+		expected := []services.MutationEvent{
+			{Scope: services.ScopeApp, Document: "home", Version: 1},
+			{Scope: services.ScopeUser, UserID: "user-1", Document: "prefs", Version: 1},
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("Expected events %+v, got %+v", expected, events)
+		}
+	})
+}
+
+// expectMutation checks the results of a Store mutation
+func expectMutation(t *testing.T, version uint64, affected int64, err error, expectedVersion uint64, expectedAffected int64) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if version != expectedVersion || affected != expectedAffected {
+		t.Errorf("Expected version %d affecting %d, got version %d affecting %d", expectedVersion, expectedAffected, version, affected)
+	}
+}
+
+// expectError checks that err contains the expected message
+func expectError(t *testing.T, err error, expected string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error containing %q, got %v", expected, err)
+	}
+}
+
+// expectResult compares a Store read result
+func expectResult(t *testing.T, result, expected services.DocumentResult) {
+	t.Helper()
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,196 @@
+// tls_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/config"
+	"github.com/localnerve/jam-build-propsdb/internal/middleware"
+	"github.com/localnerve/jam-build-propsdb/internal/server"
+	"github.com/localnerve/jam-build-propsdb/internal/types"
+)
+
+// writeKeyPair writes a certificate and key as PEM files
+func writeKeyPair(t *testing.T, certFile, keyFile string, raw []byte, key *ecdsa.PrivateKey) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+// TestServerTLS tests certificate reloading and client certificate verification over a TLS listener
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	ca, caKey, caRaw := writeCert(t, "Test CA", nil, nil)
+	_, key, raw := writeCert(t, "localhost", ca, caKey)
+	writeKeyPair(t, certFile, keyFile, raw, key)
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caRaw}), 0600); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+
+	certs, err := server.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	defer certs.Close()
+	tlsConfig, err := server.TLSConfig(&config.Config{TLSClientCA: caFile}, certs)
+	if err != nil {
+		t.Fatalf("Failed to configure TLS: %v", err)
+	}
+	listeners, err := server.Listen([]string{"127.0.0.1:0"}, tlsConfig)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Protocol() + " " + strconv.Itoa(len(c.Context().TLSConnectionState().VerifiedChains)))
+	})
+	go app.Listener(listeners[0])
+	defer app.Shutdown()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(clientCerts []tls.Certificate) (string, *x509.Certificate) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: clientCerts},
+		}}
+		resp, err := client.Get("https://" + listeners[0].Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("Failed to request over TLS: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.TLS.PeerCertificates[0]
+	}
+
+	body, served := get(nil)
+	if body != "https 0" || served.Subject.CommonName != "localhost" {
+		t.Errorf("Expected an unverified https request, got %q from %s", body, served.Subject.CommonName)
+	}
+
+	_, clientKey, clientRaw := writeCert(t, "admin", ca, caKey)
+	clientCert := tls.Certificate{Certificate: [][]byte{clientRaw}, PrivateKey: clientKey}
+	if body, _ := get([]tls.Certificate{clientCert}); body != "https 1" {
+		t.Errorf("Expected a verified client certificate, got %q", body)
+	}
+
+	// Rotate the certificate and wait for the reload
+	rotated, rotatedKey, rotatedRaw := writeCert(t, "localhost", ca, caKey)
+	writeKeyPair(t, certFile, keyFile, rotatedRaw, rotatedKey)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, served = get(nil); served.Equal(rotated) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the rotated certificate to be served")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A broken pair keeps the rotated certificate
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, served = get(nil); !served.Equal(rotated) {
+		t.Error("Expected the previous certificate after a failed reload")
+	}
+}
+
+// TestAdminClientCertificate tests admin routes require a verified client certificate with TLS_CLIENT_CA
+func TestAdminClientCertificate(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		var customErr *types.CustomError
+		if errors.As(err, &customErr) {
+			return c.Status(customErr.Code).SendString(customErr.Message)
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}})
+	app.Get("/health", middleware.AuthAdmin(&config.Config{TLSClientCA: "/certs/ca.crt"}), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/health", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusForbidden || string(body) != "A verified client certificate is required" {
+		t.Errorf("Expected 403 without a client certificate, got %d %s", resp.StatusCode, body)
+	}
+}
+
+// TestUnixSocketListener tests Unix socket permissions and stale socket replacement
+func TestUnixSocketListener(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "propsdb.sock")
+
+	// A socket left by a previous run
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listeners, err := server.Listen([]string{"unix:" + path}, nil)
+	if err != nil {
+		t.Fatalf("Failed to replace the stale socket: %v", err)
+	}
+	defer listeners[0].Close()
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0660 {
+		t.Errorf("Expected socket mode 0660, got %v", info.Mode().Perm())
+	}
+
+	if _, err := server.Listen([]string{"unix:" + path}, nil); err == nil {
+		t.Error("Expected a socket in use to be refused")
+	}
+
+	file := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := server.Listen([]string{"unix:" + file}, nil); err == nil {
+		t.Error("Expected a regular file not to be replaced")
+	}
+}
@@ -0,0 +1,121 @@
+// trash_test.go
+//
+// A scalable, high performance drop-in replacement for the jam-build nodejs data service
+// Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC
+//
+// This file is part of jam-build-propsdb.
+// jam-build-propsdb is free software: you can redistribute it and/or modify it
+// under the terms of the GNU Affero General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later version.
+// jam-build-propsdb is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero General Public License for more details.
+// You should have received a copy of the GNU Affero General Public License along with jam-build-propsdb.
+// If not, see <https://www.gnu.org/licenses/>.
+// Additional terms under GNU AGPL version 3 section 7:
+// a) The reasonable legal notice of original copyright and author attribution must be preserved
+//    by including the string: "Copyright (c) 2026 Alex Grant <info@localnerve.com> (https://www.localnerve.com), LocalNerve LLC"
+//    in this material, copies, or source code of derived works.
+
+package handlers_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/localnerve/jam-build-propsdb/internal/handlers"
+	"github.com/localnerve/jam-build-propsdb/internal/services"
+	"github.com/localnerve/jam-build-propsdb/tests/helpers"
+)
+
+// TestUserTrash tests listing and restoring deleted user documents through the trash routes
+func TestUserTrash(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"id": "user-789"})
+		return c.Next()
+	})
+
+	handler := &handlers.UserDataHandler{Store: services.NewMemoryStore()}
+	app.Get("/api/data/user/_trash", handler.GetUserTrash)
+	app.Post("/api/data/user/_trash/:document/restore", handler.RestoreUserTrash)
+	app.Get("/api/data/user/:document", handler.GetUserCollectionsAndProperties)
+	app.Post("/api/data/user/:document", handler.SetUserProperties)
+	app.Delete("/api/data/user/:document", handler.DeleteUserProperties)
+
+	send := func(method, url, body string) *fiber.Map {
+		t.Helper()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to execute request: %v", err)
+		}
+		helpers.AssertStatus(t, resp, 200)
+		var result fiber.Map
+		helpers.ParseJSON(t, resp, &result)
+		return &result
+	}
+
+	send("POST", "/api/data/user/prefs", `{"version":"0","collections":[{"collection":"settings","properties":{"theme":"dark"}}]}`)
+	send("DELETE", "/api/data/user/prefs", `{"version":"1","deleteDocument":true}`)
+
+	trash := send("GET", "/api/data/user/_trash", "")
+	entries, _ := (*trash)["trash"].([]interface{})
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 trash entry, got %v", *trash)
+	}
+	entry := entries[0].(map[string]interface{})
+	if entry["document"] != "prefs" || entry["version"] != "1" || entry["deletedBy"] != "user-789" {
+		t.Errorf("Unexpected trash entry %v", entry)
+	}
+
+	result := send("POST", "/api/data/user/_trash/prefs/restore", "")
+	if (*result)["newVersion"] != "2" {
+		t.Errorf("Expected restored document at version 2, got %v", *result)
+	}
+	send("GET", "/api/data/user/prefs", "")
+
+	// The document exists again, and the trash is empty
+	req := httptest.NewRequest("POST", "/api/data/user/_trash/prefs/restore", strings.NewReader(`{"collection":"settings"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 404)
+}
+
+// TestUserTrash_RestoreExisting tests that a restore never overwrites an existing document
+func TestUserTrash_RestoreExisting(t *testing.T) {
+	store := services.NewMemoryStore()
+	_, _, _ = store.SetUserProperties(t.Context(), "user-789", "prefs", 0, []services.CollectionInput{
+		{Collection: "settings", Properties: map[string]interface{}{"theme": "dark"}},
+	}, services.WriteOptions{})
+	_, _, _ = store.DeleteUserProperties(t.Context(), "user-789", "prefs", 1, nil, true, services.WriteOptions{})
+	_, _, _ = store.SetUserProperties(t.Context(), "user-789", "prefs", 0, []services.CollectionInput{
+		{Collection: "settings", Properties: map[string]interface{}{"theme": "light"}},
+	}, services.WriteOptions{})
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"id": "user-789"})
+		return c.Next()
+	})
+	handler := &handlers.UserDataHandler{Store: store}
+	app.Post("/api/data/user/_trash/:document/restore", handler.RestoreUserTrash)
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/api/data/user/_trash/prefs/restore", nil))
+	if err != nil {
+		t.Fatalf("Failed to execute request: %v", err)
+	}
+	helpers.AssertStatus(t, resp, 409)
+
+	var result map[string]interface{}
+	helpers.ParseJSON(t, resp, &result)
+	if result["type"] != "data.exists" || result["versionError"] == true {
+		t.Errorf("Expected a data.exists conflict, got %v", result)
+	}
+}
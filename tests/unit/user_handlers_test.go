@@ -42,6 +42,7 @@ func setupUserTestDB(t *testing.T) *gorm.DB {
 		&models.UserDocument{},
 		&models.UserCollection{},
 		&models.UserProperty{},
+		&models.UserTrash{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)